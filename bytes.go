@@ -0,0 +1,495 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/reflect"
+)
+
+// MatchBytes is a convenient method calling MatchBytes on the defaultMatcher
+func MatchBytes(md pref.MessageDescriptor, b []byte, f filters.FieldFilterer) (bool, error) {
+	return defaultMatcher.MatchBytes(md, b, f)
+}
+
+// MatchBytes matches the wire-format encoded message described by md
+// without unmarshalling it: the conditions are evaluated on the values of
+// the fields they reference, decoded from the wire, and their paths are
+// resolved only when they are reached, as with Match. As the later
+// occurrences of a field override or merge into the previous ones, the
+// whole message is scanned for each field.
+// The paths reaching google.protobuf.Any, Struct, Value, ListValue, map
+// and group fields are matched on the messages decoded with these fields.
+func (m *matcher) MatchBytes(md pref.MessageDescriptor, b []byte, f filters.FieldFilterer) (bool, error) {
+	if md == nil {
+		return false, errors.New("message descriptor is null")
+	}
+	if f == nil || f.Expr() == nil {
+		return true, nil
+	}
+	if err := m.limits.Check(f); err != nil {
+		return false, err
+	}
+	return m.matchBytesExpression(newMatchState(context.Background(), m.limits), md, b, f.Expr())
+}
+
+func (m *matcher) matchBytesExpression(s *matchState, md pref.MessageDescriptor, b []byte, expr *filters.Expression) (bool, error) {
+	ok, err := m.matchBytesFilter(s, md, b, expr.Condition)
+	if err != nil {
+		return false, err
+	}
+	if !ok && len(expr.OrExprs) == 0 {
+		return false, nil
+	}
+	if ok {
+		for _, v := range expr.AndExprs {
+			ok, err = m.matchBytesExpression(s, md, b, v)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+	if ok {
+		return true, nil
+	}
+	for _, v := range expr.OrExprs {
+		ok, err = m.matchBytesExpression(s, md, b, v)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *matcher) matchBytesFilter(s *matchState, md pref.MessageDescriptor, b []byte, ff *filters.FieldFilter) (bool, error) {
+	if ff == nil {
+		return true, nil
+	}
+	l, err := m.lookup(md, ff.Field)
+	if err != nil {
+		return false, reflect.WithPath(err, ff.Field)
+	}
+	if !wireMatchable(l) {
+		return m.matchDecoded(s, md, b, l, ff)
+	}
+	for _, fds := range l.paths {
+		// the wildcards only match the fields accepting the filter
+		if l.pattern && !reflect.Accepts(fds[len(fds)-1], ff.Filter) {
+			continue
+		}
+		ok, err := m.matchWire(s, md, b, ff.Filter, fds)
+		if err != nil || ok {
+			return ok, reflect.WithPath(err, ff.Field)
+		}
+	}
+	return false, nil
+}
+
+// wireMatchable reports whether the resolved path is matched on the values
+// decoded from the wire
+func wireMatchable(l *lookup) bool {
+	if l.unpack != nil || l.keys != nil || l.recursive != "" {
+		return false
+	}
+	for _, fds := range l.paths {
+		for _, fd := range fds {
+			if fd.IsMap() || fd.Kind() == pref.GroupKind {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchDecoded matches the condition on the message decoded with only the
+// fields of its path
+func (m *matcher) matchDecoded(s *matchState, md pref.MessageDescriptor, b []byte, l *lookup, ff *filters.FieldFilter) (bool, error) {
+	// the recursive wildcards are expanded in the whole message
+	sel := &selection{all: len(l.paths) == 0 || l.recursive != ""}
+	for _, fds := range l.paths {
+		sel.add(fds)
+	}
+	if !sel.all {
+		var err error
+		if b, err = sel.filter(nil, md, b); err != nil {
+			return false, err
+		}
+	}
+	msg := m.newMessage(md)
+	if err := (proto.UnmarshalOptions{}).Unmarshal(b, msg.Interface()); err != nil {
+		return false, err
+	}
+	return m.matchFilter(s, msg.Interface(), ff.Field, ff.Filter)
+}
+
+// matchWire matches the filter on the values of the fields path in the
+// wire-format encoded message b, as doMatch does on the decoded message
+func (m *matcher) matchWire(s *matchState, md pref.MessageDescriptor, b []byte, filter *filters.Filter, fds []pref.FieldDescriptor) (bool, error) {
+	fd := fds[0]
+	fds = fds[1:]
+	vs, set, err := wireValues(md, b, fd)
+	if err != nil {
+		return false, err
+	}
+	if o := fd.ContainingOneof(); o != nil && !o.IsSynthetic() && !set {
+		return false, nil
+	}
+	if len(fds) != 0 && fd.Kind() == pref.MessageKind {
+		if !fd.IsList() {
+			return m.matchWire(s, fd.Message(), merged(vs), filter, fds)
+		}
+		for _, v := range vs {
+			if err := s.scan(); err != nil {
+				return false, err
+			}
+			ok, err := m.matchWire(s, fd.Message(), v.b, filter, fds)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if fd.IsList() {
+		var match bool
+		err := m.eachWireValue(fd, vs, func(v pref.Value) (bool, error) {
+			if err := s.scan(); err != nil {
+				return false, err
+			}
+			ok, err := reflect.Match(v, fd, filter)
+			if err != nil {
+				return false, err
+			}
+			if ok != filter.GetNot() {
+				match = ok
+				return false, nil
+			}
+			return true, nil
+		})
+		return match, err
+	}
+	var v pref.Value
+	switch {
+	case !set && fd.HasOptionalKeyword():
+	case !set && fd.Kind() == pref.MessageKind:
+		v = pref.ValueOfMessage(m.newMessage(fd.Message()).Type().Zero())
+	case !set:
+		v = fd.Default()
+	case fd.Kind() == pref.MessageKind:
+		if v, err = m.wireMessage(fd, merged(vs)); err != nil {
+			return false, err
+		}
+	default:
+		last := vs[len(vs)-1]
+		if v, err = wireScalar(fd, last.typ, last.b); err != nil {
+			return false, err
+		}
+	}
+	return reflect.Match(v, fd, filter)
+}
+
+// wireValue is an occurrence of a field: the content of the length
+// delimited values, the encoded value of the others
+type wireValue struct {
+	typ protowire.Type
+	b   []byte
+}
+
+// wireValues returns the occurrences of the field fd in the message b, and
+// whether it is set: the occurrences of the other fields of its oneof clear
+// the previous ones. As the unmarshalling does, the occurrences with an
+// invalid wire type are ignored.
+func wireValues(md pref.MessageDescriptor, b []byte, fd pref.FieldDescriptor) ([]wireValue, bool, error) {
+	var vs []wireValue
+	set := false
+	o := fd.ContainingOneof()
+	if o != nil && o.IsSynthetic() {
+		o = nil
+	}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, false, fmt.Errorf("%s: %w", md.FullName(), protowire.ParseError(n))
+		}
+		l := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if l < 0 {
+			return nil, false, fmt.Errorf("%s: %w", md.FullName(), protowire.ParseError(l))
+		}
+		v := b[n : n+l]
+		b = b[n+l:]
+		if num != fd.Number() {
+			if o != nil {
+				if other := o.Fields().ByNumber(num); other != nil {
+					vs, set = vs[:0], false
+				}
+			}
+			continue
+		}
+		want := wireType(fd.Kind())
+		if typ != want && (!fd.IsList() || typ != protowire.BytesType) {
+			continue
+		}
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		vs = append(vs, wireValue{typ: typ, b: v})
+		set = true
+	}
+	return vs, set, nil
+}
+
+// merged returns the occurrences of a message field merged
+func merged(vs []wireValue) []byte {
+	switch len(vs) {
+	case 0:
+		return nil
+	case 1:
+		return vs[0].b
+	}
+	var out []byte
+	for _, v := range vs {
+		out = append(out, v.b...)
+	}
+	return out
+}
+
+// eachWireValue calls fn with the list elements, unpacking the packed ones,
+// until fn returns false or an error
+func (m *matcher) eachWireValue(fd pref.FieldDescriptor, vs []wireValue, fn func(v pref.Value) (bool, error)) error {
+	for _, w := range vs {
+		if fd.Kind() == pref.MessageKind {
+			v, err := m.wireMessage(fd, w.b)
+			if err != nil {
+				return err
+			}
+			if ok, err := fn(v); err != nil || !ok {
+				return err
+			}
+			continue
+		}
+		if w.typ != protowire.BytesType || fd.Kind() == pref.StringKind || fd.Kind() == pref.BytesKind {
+			v, err := wireScalar(fd, w.typ, w.b)
+			if err != nil {
+				return err
+			}
+			if ok, err := fn(v); err != nil || !ok {
+				return err
+			}
+			continue
+		}
+		// packed values
+		typ, b := wireType(fd.Kind()), w.b
+		for len(b) > 0 {
+			n := protowire.ConsumeFieldValue(fd.Number(), typ, b)
+			if n < 0 {
+				return fmt.Errorf("%s: %w", fd.FullName(), protowire.ParseError(n))
+			}
+			v, err := wireScalar(fd, typ, b[:n])
+			if err != nil {
+				return err
+			}
+			if ok, err := fn(v); err != nil || !ok {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// wireMessage returns the message field value decoded from b
+func (m *matcher) wireMessage(fd pref.FieldDescriptor, b []byte) (pref.Value, error) {
+	msg := m.newMessage(fd.Message())
+	if err := (proto.UnmarshalOptions{}).Unmarshal(b, msg.Interface()); err != nil {
+		return pref.Value{}, fmt.Errorf("%s: %w", fd.FullName(), err)
+	}
+	return pref.ValueOfMessage(msg), nil
+}
+
+// wireType returns the wire type of the non packed values of the kind
+func wireType(k pref.Kind) protowire.Type {
+	switch k {
+	case pref.Fixed32Kind, pref.Sfixed32Kind, pref.FloatKind:
+		return protowire.Fixed32Type
+	case pref.Fixed64Kind, pref.Sfixed64Kind, pref.DoubleKind:
+		return protowire.Fixed64Type
+	case pref.StringKind, pref.BytesKind, pref.MessageKind:
+		return protowire.BytesType
+	case pref.GroupKind:
+		return protowire.StartGroupType
+	default:
+		return protowire.VarintType
+	}
+}
+
+// wireScalar decodes the scalar value b of the wire type typ
+func wireScalar(fd pref.FieldDescriptor, typ protowire.Type, b []byte) (pref.Value, error) {
+	var (
+		v uint64
+		n int
+	)
+	switch typ {
+	case protowire.VarintType:
+		v, n = protowire.ConsumeVarint(b)
+	case protowire.Fixed32Type:
+		var v32 uint32
+		v32, n = protowire.ConsumeFixed32(b)
+		v = uint64(v32)
+	case protowire.Fixed64Type:
+		v, n = protowire.ConsumeFixed64(b)
+	case protowire.BytesType:
+		if fd.Kind() == pref.StringKind {
+			return pref.ValueOfString(string(b)), nil
+		}
+		return pref.ValueOfBytes(b), nil
+	}
+	if n < 0 {
+		return pref.Value{}, fmt.Errorf("%s: %w", fd.FullName(), protowire.ParseError(n))
+	}
+	switch fd.Kind() {
+	case pref.BoolKind:
+		return pref.ValueOfBool(v != 0), nil
+	case pref.EnumKind:
+		return pref.ValueOfEnum(pref.EnumNumber(int32(v))), nil
+	case pref.Int32Kind, pref.Sfixed32Kind:
+		return pref.ValueOfInt32(int32(v)), nil
+	case pref.Sint32Kind:
+		return pref.ValueOfInt32(int32(protowire.DecodeZigZag(v & math.MaxUint32))), nil
+	case pref.Uint32Kind, pref.Fixed32Kind:
+		return pref.ValueOfUint32(uint32(v)), nil
+	case pref.Int64Kind, pref.Sfixed64Kind:
+		return pref.ValueOfInt64(int64(v)), nil
+	case pref.Sint64Kind:
+		return pref.ValueOfInt64(protowire.DecodeZigZag(v)), nil
+	case pref.Uint64Kind, pref.Fixed64Kind:
+		return pref.ValueOfUint64(v), nil
+	case pref.FloatKind:
+		return pref.ValueOfFloat32(math.Float32frombits(uint32(v))), nil
+	case pref.DoubleKind:
+		return pref.ValueOfFloat64(math.Float64frombits(v)), nil
+	}
+	return pref.Value{}, fmt.Errorf("%s: unexpected kind %v", fd.FullName(), fd.Kind())
+}
+
+// newMessage returns a new message described by md, of its registered type
+// if any, so that its generated matcher is used
+func (m *matcher) newMessage(md pref.MessageDescriptor) pref.Message {
+	if typ, err := m.resolver.FindMessageByName(md.FullName()); err == nil && typ.Descriptor() == md {
+		return typ.New()
+	}
+	return dynamicpb.NewMessage(md)
+}
+
+// selection is the set of the fields of a message to decode
+type selection struct {
+	// all selects all the fields
+	all    bool
+	fields map[protowire.Number]*selection
+	// oneofs are the oneofs of the selected fields: their other fields are
+	// kept, so that the last set one is still known
+	oneofs map[pref.FullName]struct{}
+}
+
+// add selects the fields path, and all the fields of the last field if it
+// is a message
+func (s *selection) add(fds []pref.FieldDescriptor) {
+	for _, fd := range fds {
+		if s.all {
+			return
+		}
+		if s.fields == nil {
+			s.fields = make(map[protowire.Number]*selection)
+			s.oneofs = make(map[pref.FullName]struct{})
+		}
+		if o := fd.ContainingOneof(); o != nil && !o.IsSynthetic() {
+			s.oneofs[o.FullName()] = struct{}{}
+		}
+		next, ok := s.fields[fd.Number()]
+		if !ok {
+			next = &selection{}
+			s.fields[fd.Number()] = next
+		}
+		s = next
+	}
+	s.all = true
+}
+
+// filter appends to out the selected fields of the wire-format encoded
+// message b, described by md
+func (s *selection) filter(out []byte, md pref.MessageDescriptor, b []byte) ([]byte, error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("%s: %w", md.FullName(), protowire.ParseError(n))
+		}
+		l := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if l < 0 {
+			return nil, fmt.Errorf("%s: %w", md.FullName(), protowire.ParseError(l))
+		}
+		field, value := b[:n+l], b[n:n+l]
+		b = b[n+l:]
+		fd := md.Fields().ByNumber(num)
+		if fd == nil {
+			continue
+		}
+		next, ok := s.fields[num]
+		if !ok {
+			o := fd.ContainingOneof()
+			if o == nil || o.IsSynthetic() {
+				continue
+			}
+			if _, ok := s.oneofs[o.FullName()]; !ok {
+				continue
+			}
+			// keep the other oneof fields presence
+			if fd.Kind() == pref.MessageKind && typ == protowire.BytesType {
+				out = protowire.AppendTag(out, num, protowire.BytesType)
+				out = protowire.AppendVarint(out, 0)
+			} else {
+				out = append(out, field...)
+			}
+			continue
+		}
+		if next.all || fd.Kind() != pref.MessageKind || typ != protowire.BytesType {
+			out = append(out, field...)
+			continue
+		}
+		v, _ := protowire.ConsumeBytes(value)
+		sub, err := next.filter(nil, fd.Message(), v)
+		if err != nil {
+			return nil, err
+		}
+		out = protowire.AppendTag(out, num, protowire.BytesType)
+		out = protowire.AppendBytes(out, sub)
+	}
+	return out, nil
+}
@@ -0,0 +1,152 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestMatchBytes(t *testing.T) {
+	now := time.Now()
+	msgs := []*test.Test{
+		{},
+		{
+			StringField:          "whatever",
+			NumberField:          42,
+			EnumField:            test.Test_ONE,
+			MessageField:         &test.Test{StringField: "nested", MessageField: &test.Test{BoolField: true}},
+			RepeatedStringField:  []string{"one", "two"},
+			RepeatedMessageField: []*test.Test{{StringField: "one"}, {NumberField: 2}},
+			StringValueField:     wrapperspb.String("whatever"),
+			TimeValueField:       timestamppb.New(now),
+			OptionalNumberField:  proto.Int64(0),
+			Choice:               &test.Test_OneofMessageField{OneofMessageField: &test.Test{StringField: "oneof"}},
+		},
+		{Choice: &test.Test_OneofStringField{OneofStringField: "oneof"}},
+		{
+			NumberValueField:     wrapperspb.Int64(0),
+			BoolValueField:       wrapperspb.Bool(true),
+			DurationValueField:   durationpb.New(time.Second),
+			OptionalEnumField:    test.Test_ONE.Enum(),
+			RepeatedMessageField: []*test.Test{{RepeatedStringField: []string{"a", ""}}},
+			Choice:               &test.Test_OneofNumberField{OneofNumberField: 2},
+		},
+	}
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringEquals("whatever"),
+		filters.Where("number_field").NumberSup(10).OrWhere("bool_field").True(),
+		filters.Where("enum_field").StringEquals("ONE"),
+		filters.Where("message_field").Null(),
+		filters.Where("message_field.string_field").StringEquals("nested"),
+		filters.Where("message_field.message_field.bool_field").True(),
+		filters.Where("message_field.string_field").StringEquals("nested").AndWhere("message_field.number_field").NumberEquals(0),
+		filters.Where("repeated_string_field").StringEquals("two"),
+		filters.Where("repeated_message_field.number_field").NumberEquals(2),
+		filters.Where("string_value_field").StringNotEquals("whatever"),
+		filters.Where("time_value_field").TimeEquals(now),
+		filters.Where("optional_number_field").Null(),
+		filters.Where("oneof_string_field").StringEquals("oneof"),
+		filters.Where("oneof_message_field.string_field").StringEquals("oneof"),
+		filters.Where("*.string_field").StringEquals("nested"),
+		filters.Where("**.bool_field").True(),
+		filters.Where("number_value_field").NumberEquals(0),
+		filters.Where("bool_value_field").False(),
+		filters.Where("duration_value_field").DurationSup(0),
+		filters.Where("optional_enum_field").StringEquals("ONE"),
+		filters.Where("oneof_number_field").NumberIN(2, 3),
+		filters.Where("repeated_message_field.repeated_string_field").StringEquals(""),
+		filters.Where("repeated_string_field").StringNotEquals("one"),
+	}
+	for _, msg := range msgs {
+		b, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		for _, f := range fs {
+			for _, f := range []filters.FieldFilterer{f, filters.Not(f)} {
+				want, err := Match(msg, f)
+				require.NoError(t, err)
+				got, err := MatchBytes(msg.ProtoReflect().Descriptor(), b, f)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "%v: %s", msg, f.Expr().Format())
+			}
+		}
+	}
+	md := (&test.Test{}).ProtoReflect().Descriptor()
+
+	// the last set oneof field wins
+	b, err := proto.Marshal(&test.Test{Choice: &test.Test_OneofStringField{OneofStringField: "a"}})
+	require.NoError(t, err)
+	b = protowire.AppendTag(b, 18, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	ok, err := MatchBytes(md, b, filters.Where("oneof_string_field").StringEquals("a"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// the later occurrences of a message field are merged
+	b, err = proto.Marshal(&test.Test{MessageField: &test.Test{StringField: "a"}})
+	require.NoError(t, err)
+	b2, err := proto.Marshal(&test.Test{MessageField: &test.Test{NumberField: 1}})
+	require.NoError(t, err)
+	ok, err = MatchBytes(md, append(b, b2...), filters.Where("message_field.string_field").StringEquals("a").AndWhere("message_field.number_field").NumberEquals(1))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = MatchBytes(md, b, filters.Where("unknown").StringEquals("a"))
+	assert.Error(t, err)
+	// the paths are resolved when reached
+	ok, err = MatchBytes(md, b, filters.Where("string_field").StringEquals("whatever").AndWhere("unknown").True())
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = MatchBytes(md, b[:len(b)-1], filters.Where("string_field").StringEquals("a"))
+	assert.Error(t, err)
+
+	// dynamic messages, with a new matcher as the lookups are cached by
+	// message name
+	md = envelope(t)
+	m := dynamicpb.NewMessage(md)
+	a, err := anypb.New(&test.Test{StringField: "a"})
+	require.NoError(t, err)
+	m.Set(md.Fields().ByName("payload"), pref.ValueOfMessage(a.ProtoReflect()))
+	s, err := structpb.NewStruct(map[string]any{"region": "eu"})
+	require.NoError(t, err)
+	m.Set(md.Fields().ByName("metadata"), pref.ValueOfMessage(s.ProtoReflect()))
+	b, err = proto.Marshal(m)
+	require.NoError(t, err)
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("payload.string_field").StringEquals("a"),
+		filters.Where("metadata.region").StringEquals("eu"),
+	} {
+		ok, err := NewMatcher().MatchBytes(md, b, f)
+		require.NoError(t, err)
+		assert.True(t, ok, f.Expr().Format())
+	}
+}
@@ -0,0 +1,632 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package cel converts filters from and to CEL (Common Expression Language)
+// expressions, so that the same filter can be evaluated by protofilters or
+// by cel-go.
+//
+// The message is either bound to a variable, e.g. msg.string_field == "x",
+// or, when the variable name is empty, its fields are declared as top-level
+// variables as done by cel.DeclareContextProto, e.g. string_field == "x".
+package cel
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	celgo "github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// NewEnv creates a CEL environment where the message described by md is
+// bound to the variable name, or its fields are top-level variables if name
+// is empty.
+func NewEnv(md protoreflect.MessageDescriptor, name string, opts ...celgo.EnvOption) (*celgo.Env, error) {
+	if name == "" {
+		return celgo.NewEnv(append([]celgo.EnvOption{celgo.DeclareContextProto(md)}, opts...)...)
+	}
+	return celgo.NewEnv(append([]celgo.EnvOption{
+		celgo.TypeDescs(md.ParentFile()),
+		celgo.Variable(name, celgo.ObjectType(string(md.FullName()))),
+	}, opts...)...)
+}
+
+// Parse compiles the CEL expression in the environment created by NewEnv
+// and converts it to an Expression.
+func Parse(md protoreflect.MessageDescriptor, name, expr string) (*filters.Expression, error) {
+	env, err := NewEnv(md, name)
+	if err != nil {
+		return nil, err
+	}
+	a, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("cel: %w", iss.Err())
+	}
+	return FromAST(md, name, a)
+}
+
+// FromAST converts the CEL abstract syntax tree to an Expression.
+// The message described by md is either bound to the variable name, or its
+// fields are top-level variables if name is empty.
+// The supported constructs are the logical operators, the comparisons of
+// fields with literals, in lists, startsWith, endsWith, contains, matches,
+// has, and the exists macro over repeated fields.
+// Checked ASTs may also reference enum constants.
+func FromAST(md protoreflect.MessageDescriptor, name string, a *celgo.Ast) (*filters.Expression, error) {
+	c := &converter{md: md, name: name, refs: a.NativeRep().ReferenceMap()}
+	return c.convert(a.NativeRep().Expr(), nil, false)
+}
+
+type converter struct {
+	md   protoreflect.MessageDescriptor
+	name string
+	refs map[int64]*ast.ReferenceInfo
+}
+
+// scope binds the comprehension variables to the repeated field they iterate
+type scope struct {
+	parent *scope
+	name   string
+	path   string
+	fds    []protoreflect.FieldDescriptor
+}
+
+func (s *scope) lookup(name string) *scope {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// field is a resolved field path
+type field struct {
+	path string
+	fds  []protoreflect.FieldDescriptor
+	// free are the repeated fields of the path not bound to a comprehension
+	// variable
+	free []protoreflect.FieldDescriptor
+}
+
+func (f field) leaf() protoreflect.FieldDescriptor {
+	return f.fds[len(f.fds)-1]
+}
+
+func (c *converter) errorf(e ast.Expr, format string, args ...any) error {
+	return fmt.Errorf("cel: %s (expression %d)", fmt.Sprintf(format, args...), e.ID())
+}
+
+// convert converts the expression, negated if neg is true.
+// s is the comprehension scope, if any.
+func (c *converter) convert(e ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	switch e.Kind() {
+	case ast.CallKind:
+		return c.convertCall(e, s, neg)
+	case ast.IdentKind, ast.SelectKind:
+		if e.Kind() == ast.SelectKind && e.AsSelect().IsTestOnly() {
+			return c.convertHas(e, s, neg)
+		}
+		f, err := c.field(e, s)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkFree(e, f, false); err != nil {
+			return nil, err
+		}
+		if kindOf(f.leaf()) != boolKind {
+			return nil, c.errorf(e, "%s is not a boolean", f.path)
+		}
+		return leaf(f.path, &filters.Filter{Match: &filters.Filter_Bool{Bool: &filters.BoolFilter{Equals: true}}}, neg), nil
+	case ast.ComprehensionKind:
+		return c.convertExists(e, s, neg)
+	case ast.LiteralKind:
+		return nil, c.errorf(e, "constant expressions are not supported")
+	}
+	return nil, c.errorf(e, "unsupported expression")
+}
+
+func (c *converter) convertCall(e ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	call := e.AsCall()
+	args := call.Args()
+	switch fn := call.FunctionName(); fn {
+	case operators.LogicalNot:
+		return c.convert(args[0], s, !neg)
+	case operators.LogicalAnd, operators.LogicalOr:
+		if s != nil && fn == operators.LogicalAnd != neg {
+			return nil, c.errorf(e, "conjunctions are not supported in comprehensions")
+		}
+		var exprs []filters.FieldFilterer
+		for _, v := range args {
+			x, err := c.convert(v, s, neg)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, x)
+		}
+		// not (a and b) == not a or not b
+		if fn == operators.LogicalAnd != neg {
+			return filters.And(exprs...), nil
+		}
+		return filters.Or(exprs...), nil
+	case operators.Equals, operators.NotEquals, operators.Less, operators.LessEquals, operators.Greater, operators.GreaterEquals:
+		return c.convertComparison(e, fn, args, s, neg)
+	case operators.In:
+		return c.convertIn(e, args, s, neg)
+	case overloads.StartsWith, overloads.EndsWith, overloads.Contains, overloads.Matches:
+		target := call.Target()
+		if !call.IsMemberFunction() {
+			if len(args) != 2 {
+				return nil, c.errorf(e, "invalid %s call", fn)
+			}
+			target, args = args[0], args[1:]
+		}
+		f, err := c.field(target, s)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkFree(e, f, false); err != nil {
+			return nil, err
+		}
+		if kindOf(f.leaf()) != stringKind {
+			return nil, c.errorf(e, "%s is not a string", f.path)
+		}
+		v, err := c.literal(args[0])
+		if err != nil {
+			return nil, err
+		}
+		str, ok := v.(string)
+		if !ok {
+			return nil, c.errorf(args[0], "expected a string")
+		}
+		cond := &filters.StringFilter{}
+		switch fn {
+		case overloads.StartsWith:
+			cond.Condition = &filters.StringFilter_HasPrefix{HasPrefix: str}
+		case overloads.EndsWith:
+			cond.Condition = &filters.StringFilter_HasSuffix{HasSuffix: str}
+		case overloads.Contains:
+			cond.Condition = &filters.StringFilter_Contains{Contains: str}
+		default:
+			if _, err := regexp.Compile(str); err != nil {
+				return nil, c.errorf(args[0], "invalid regex: %v", err)
+			}
+			cond.Condition = &filters.StringFilter_Regex{Regex: str}
+		}
+		return leaf(f.path, &filters.Filter{Match: &filters.Filter_String_{String_: cond}}, neg), nil
+	default:
+		return nil, c.errorf(e, "unsupported function %s", fn)
+	}
+}
+
+// mirror returns the comparison operator with swapped operands
+var mirror = map[string]string{
+	operators.Equals:        operators.Equals,
+	operators.NotEquals:     operators.NotEquals,
+	operators.Less:          operators.Greater,
+	operators.LessEquals:    operators.GreaterEquals,
+	operators.Greater:       operators.Less,
+	operators.GreaterEquals: operators.LessEquals,
+}
+
+func (c *converter) convertComparison(e ast.Expr, op string, args []ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	lhs, rhs := args[0], args[1]
+	f, err := c.field(lhs, s)
+	if err != nil {
+		var err2 error
+		if f, err2 = c.field(rhs, s); err2 != nil {
+			return nil, err
+		}
+		lhs, rhs, op = rhs, lhs, mirror[op]
+	}
+	if err := c.checkFree(e, f, false); err != nil {
+		return nil, err
+	}
+	v, err := c.literal(rhs)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := c.filter(rhs, f, op, v)
+	if err != nil {
+		return nil, err
+	}
+	return leaf(f.path, filter, neg), nil
+}
+
+func (c *converter) convertIn(e ast.Expr, args []ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	// value in repeated_field
+	if f, err := c.field(args[1], s); err == nil {
+		if err := c.checkFree(e, f, true); err != nil {
+			return nil, err
+		}
+		if neg {
+			return nil, c.errorf(e, "negated conditions on repeated fields are not supported")
+		}
+		v, err := c.literal(args[0])
+		if err != nil {
+			return nil, err
+		}
+		filter, err := c.filter(args[0], f, operators.Equals, v)
+		if err != nil {
+			return nil, err
+		}
+		return leaf(f.path, filter, false), nil
+	}
+	// field in [values]
+	f, err := c.field(args[0], s)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkFree(e, f, false); err != nil {
+		return nil, err
+	}
+	if args[1].Kind() != ast.ListKind {
+		return nil, c.errorf(args[1], "expected a list of literals")
+	}
+	var strs []string
+	var nums []float64
+	var exprs []filters.FieldFilterer
+	for _, v := range args[1].AsList().Elements() {
+		lit, err := c.literal(v)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := c.filter(v, f, operators.Equals, lit)
+		if err != nil {
+			return nil, err
+		}
+		switch m := filter.Match.(type) {
+		case *filters.Filter_String_:
+			strs = append(strs, m.String_.GetEquals())
+		case *filters.Filter_Number:
+			nums = append(nums, m.Number.GetEquals())
+		default:
+			exprs = append(exprs, leaf(f.path, filter, neg))
+		}
+	}
+	switch {
+	case len(exprs) != 0:
+		if neg {
+			return filters.And(exprs...), nil
+		}
+		return filters.Or(exprs...), nil
+	case len(strs) != 0:
+		return leaf(f.path, filters.StringIN(strs...), neg), nil
+	case len(nums) != 0:
+		return leaf(f.path, filters.NumberIN(nums...), neg), nil
+	}
+	return nil, c.errorf(args[1], "empty list")
+}
+
+func (c *converter) convertHas(e ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	f, err := c.field(e, s)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkFree(e, f, false); err != nil {
+		return nil, err
+	}
+	if fd := f.leaf(); fd.IsList() || fd.Message() == nil && !fd.HasOptionalKeyword() {
+		return nil, c.errorf(e, "has is only supported on singular message and optional fields")
+	}
+	return leaf(f.path, filters.NotNull(), neg), nil
+}
+
+// convertExists converts the comprehension generated by the exists macro
+func (c *converter) convertExists(e ast.Expr, s *scope, neg bool) (*filters.Expression, error) {
+	comp := e.AsComprehension()
+	init, step := comp.AccuInit(), comp.LoopStep()
+	if comp.HasIterVar2() || init.Kind() != ast.LiteralKind || init.AsLiteral() != types.False ||
+		step.Kind() != ast.CallKind || step.AsCall().FunctionName() != operators.LogicalOr ||
+		step.AsCall().Args()[0].Kind() != ast.IdentKind || step.AsCall().Args()[0].AsIdent() != comp.AccuVar() {
+		return nil, c.errorf(e, "unsupported comprehension: only exists is supported")
+	}
+	if neg {
+		return nil, c.errorf(e, "negated conditions on repeated fields are not supported")
+	}
+	f, err := c.field(comp.IterRange(), s)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkFree(e, f, true); err != nil {
+		return nil, err
+	}
+	return c.convert(step.AsCall().Args()[1], &scope{parent: s, name: comp.IterVar(), path: f.path, fds: f.fds}, false)
+}
+
+// checkFree checks that the repeated fields of the path are iterated, but the
+// leaf one if it is the right operand of an in or the range of an exists.
+func (c *converter) checkFree(e ast.Expr, f field, leafList bool) error {
+	free := f.free
+	if leafList {
+		if len(free) == 0 || free[len(free)-1] != f.leaf() {
+			return c.errorf(e, "%s is not a repeated field", f.path)
+		}
+		free = free[:len(free)-1]
+	}
+	if len(free) != 0 {
+		return c.errorf(e, "repeated field %s must be used with exists or in", free[0].Name())
+	}
+	return nil
+}
+
+// field resolves the field path selected by the expression
+func (c *converter) field(e ast.Expr, s *scope) (field, error) {
+	var names []string
+	for e.Kind() == ast.SelectKind {
+		if _, ok := c.refs[e.ID()]; ok && c.refs[e.ID()].Value != nil {
+			return field{}, c.errorf(e, "expected a field")
+		}
+		names = append([]string{e.AsSelect().FieldName()}, names...)
+		e = e.AsSelect().Operand()
+	}
+	if e.Kind() != ast.IdentKind {
+		return field{}, c.errorf(e, "expected a field")
+	}
+	if r, ok := c.refs[e.ID()]; ok && r.Value != nil {
+		return field{}, c.errorf(e, "expected a field")
+	}
+	var f field
+	md := c.md
+	id := e.AsIdent()
+	v := s.lookup(id)
+	switch {
+	case v != nil:
+		f.path, f.fds = v.path, v.fds
+		md = v.fds[len(v.fds)-1].Message()
+	case c.name == "":
+		names = append([]string{id}, names...)
+	case id != c.name:
+		return field{}, c.errorf(e, "unknown variable %s", id)
+	case len(names) == 0:
+		return field{}, c.errorf(e, "expected a field of %s", id)
+	}
+	bound := len(f.fds)
+	for _, n := range names {
+		if md == nil {
+			return field{}, c.errorf(e, "%s is not a message", f.path)
+		}
+		fd := md.Fields().ByName(protoreflect.Name(n))
+		if fd == nil {
+			return field{}, c.errorf(e, "%s does not contain %s", md.FullName(), n)
+		}
+		if fd.IsMap() {
+			return field{}, c.errorf(e, "map fields are not supported")
+		}
+		if f.path != "" {
+			f.path += "."
+		}
+		f.path += n
+		f.fds = append(f.fds[:len(f.fds):len(f.fds)], fd)
+		md = fd.Message()
+	}
+	for _, fd := range f.fds[bound:] {
+		if fd.IsList() {
+			f.free = append(f.free, fd)
+		}
+	}
+	return f, nil
+}
+
+// literal returns the Go value of the literal expression
+func (c *converter) literal(e ast.Expr) (any, error) {
+	var v ref.Val
+	switch e.Kind() {
+	case ast.LiteralKind:
+		v = e.AsLiteral()
+	case ast.IdentKind, ast.SelectKind:
+		r, ok := c.refs[e.ID()]
+		if !ok || r.Value == nil {
+			return nil, c.errorf(e, "expected a literal")
+		}
+		v = r.Value
+	case ast.CallKind:
+		call := e.AsCall()
+		fn := call.FunctionName()
+		if fn != overloads.TypeConvertTimestamp && fn != overloads.TypeConvertDuration || len(call.Args()) != 1 {
+			return nil, c.errorf(e, "expected a literal")
+		}
+		arg, err := c.literal(call.Args()[0])
+		if err != nil {
+			return nil, err
+		}
+		s, ok := arg.(string)
+		if !ok {
+			return nil, c.errorf(e, "%s expects a string", fn)
+		}
+		if fn == overloads.TypeConvertTimestamp {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, c.errorf(e, "invalid timestamp %q", s)
+			}
+			return t, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, c.errorf(e, "invalid duration %q", s)
+		}
+		return d, nil
+	default:
+		return nil, c.errorf(e, "expected a literal")
+	}
+	switch v := v.(type) {
+	case types.String:
+		return string(v), nil
+	case types.Int:
+		return float64(v), nil
+	case types.Uint:
+		return float64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Null:
+		return nil, nil
+	case types.Timestamp:
+		return v.Time, nil
+	case types.Duration:
+		return v.Duration, nil
+	}
+	return nil, c.errorf(e, "unsupported literal %v", v)
+}
+
+// filter builds the filter comparing the field with the literal value.
+func (c *converter) filter(e ast.Expr, f field, op string, v any) (*filters.Filter, error) {
+	fd := f.leaf()
+	kind := kindOf(fd)
+	if v == nil {
+		if kind != messageKind && !fd.HasOptionalKeyword() {
+			return nil, c.errorf(e, "%s cannot be null", f.path)
+		}
+		switch op {
+		case operators.Equals:
+			return filters.Null(), nil
+		case operators.NotEquals:
+			return filters.NotNull(), nil
+		}
+		return nil, c.errorf(e, "null can only be compared for equality")
+	}
+	negated := op == operators.NotEquals || op == operators.LessEquals || op == operators.GreaterEquals
+	var out *filters.Filter
+	switch v := v.(type) {
+	case string:
+		if kind != stringKind {
+			return nil, c.errorf(e, "cannot compare %s with a string", f.path)
+		}
+		switch op {
+		case operators.Equals, operators.NotEquals:
+			out = filters.StringEquals(v)
+		case operators.Less, operators.GreaterEquals:
+			out = filters.StringInf(v)
+		default:
+			out = filters.StringSup(v)
+		}
+	case float64:
+		if kind != numberKind && kind != enumKind {
+			return nil, c.errorf(e, "cannot compare %s with a number", f.path)
+		}
+		switch op {
+		case operators.Equals, operators.NotEquals:
+			out = filters.NumberEquals(v)
+		case operators.Less, operators.GreaterEquals:
+			out = filters.NumberInf(v)
+		default:
+			out = filters.NumberSup(v)
+		}
+	case bool:
+		if kind != boolKind {
+			return nil, c.errorf(e, "cannot compare %s with a boolean", f.path)
+		}
+		if op != operators.Equals && op != operators.NotEquals {
+			return nil, c.errorf(e, "booleans can only be compared for equality")
+		}
+		out = filters.True()
+		if !v {
+			out = filters.False()
+		}
+	case time.Time:
+		if kind != timestampKind {
+			return nil, c.errorf(e, "cannot compare %s with a timestamp", f.path)
+		}
+		ts := timestamppb.New(v)
+		switch op {
+		case operators.Equals, operators.NotEquals:
+			out = &filters.Filter{Match: &filters.Filter_Time{Time: &filters.TimeFilter{Condition: &filters.TimeFilter_Equals{Equals: ts}}}}
+		case operators.Less, operators.GreaterEquals:
+			out = &filters.Filter{Match: &filters.Filter_Time{Time: &filters.TimeFilter{Condition: &filters.TimeFilter_Before{Before: ts}}}}
+		default:
+			out = &filters.Filter{Match: &filters.Filter_Time{Time: &filters.TimeFilter{Condition: &filters.TimeFilter_After{After: ts}}}}
+		}
+	case time.Duration:
+		if kind != durationKind {
+			return nil, c.errorf(e, "cannot compare %s with a duration", f.path)
+		}
+		d := durationpb.New(v)
+		switch op {
+		case operators.Equals, operators.NotEquals:
+			out = &filters.Filter{Match: &filters.Filter_Duration{Duration: &filters.DurationFilter{Condition: &filters.DurationFilter_Equals{Equals: d}}}}
+		case operators.Less, operators.GreaterEquals:
+			out = &filters.Filter{Match: &filters.Filter_Duration{Duration: &filters.DurationFilter{Condition: &filters.DurationFilter_Inf{Inf: d}}}}
+		default:
+			out = &filters.Filter{Match: &filters.Filter_Duration{Duration: &filters.DurationFilter{Condition: &filters.DurationFilter_Sup{Sup: d}}}}
+		}
+	}
+	out.Not = negated
+	return out, nil
+}
+
+// leaf returns the expression holding the filter, negated if neg is true.
+func leaf(path string, f *filters.Filter, neg bool) *filters.Expression {
+	if neg {
+		f.Not = !f.Not
+	}
+	return &filters.Expression{Condition: &filters.FieldFilter{Field: path, Filter: f}}
+}
+
+type kind int
+
+const (
+	unsupportedKind kind = iota
+	stringKind
+	enumKind
+	numberKind
+	boolKind
+	timestampKind
+	durationKind
+	messageKind
+)
+
+func kindOf(fd protoreflect.FieldDescriptor) kind {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return stringKind
+	case protoreflect.EnumKind:
+		return enumKind
+	case protoreflect.BoolKind:
+		return boolKind
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind,
+		protoreflect.Sfixed32Kind, protoreflect.Fixed32Kind, protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.FloatKind, protoreflect.DoubleKind:
+		return numberKind
+	case protoreflect.MessageKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.StringValue":
+			return stringKind
+		case "google.protobuf.BoolValue":
+			return boolKind
+		case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+			"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+			"google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+			return numberKind
+		case "google.protobuf.Timestamp":
+			return timestampKind
+		case "google.protobuf.Duration":
+			return durationKind
+		}
+		return messageKind
+	}
+	return unsupportedKind
+}
@@ -0,0 +1,247 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package cel_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/cel"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+var md = (&test.Test{}).ProtoReflect().Descriptor()
+
+var msgs = []*test.Test{
+	{},
+	{StringField: "abc", NumberField: 1, EnumField: test.Test_ONE},
+	{StringField: "ABD", NumberField: 2, BoolField: true, OptionalStringField: proto.String("")},
+	{StringField: "xyz", NumberField: 3, RepeatedStringField: []string{"a", "b"}, NumberValueField: wrapperspb.Int64(3)},
+	{
+		MessageField:         &test.Test{StringField: "abc"},
+		RepeatedMessageField: []*test.Test{{NumberField: 1, RepeatedStringField: []string{"x"}}, {NumberField: 4}},
+		TimeValueField:       timestamppb.New(time.Unix(10, 0)),
+		DurationValueField:   durationpb.New(1500 * time.Millisecond),
+		OptionalNumberField:  proto.Int64(-1),
+		Choice:               &test.Test_OneofStringField{OneofStringField: "abc"},
+	},
+	{EnumField: test.Test_TWO, OptionalEnumField: test.Test_ONE.Enum(), Choice: &test.Test_OneofNumberField{OneofNumberField: 2}},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `m.string_field == "a"`, want: "string_field eq 'a'"},
+		{input: `"a" != m.string_field`, want: "string_field not eq 'a'"},
+		{input: `m.number_field > 3`, want: "number_field sup 3"},
+		{input: `3 > m.number_field`, want: "number_field inf 3"},
+		{input: `m.number_field <= 3`, want: "number_field not sup 3"},
+		{input: `m.string_field.startsWith("a")`, want: "string_field has_prefix 'a'"},
+		{input: `!m.string_field.endsWith("a")`, want: "string_field not has_suffix 'a'"},
+		{input: `m.string_field.matches("^a+$")`, want: "string_field matches '^a+$'"},
+		{input: `m.string_field in ["a", "b"]`, want: "string_field in ('a', 'b')"},
+		{input: `"a" in m.repeated_string_field`, want: "repeated_string_field eq 'a'"},
+		{input: `m.repeated_string_field.exists(x, x.contains("a"))`, want: "repeated_string_field contains 'a'"},
+		{input: `m.repeated_message_field.exists(x, x.number_field == 1)`, want: "repeated_message_field.number_field eq 1"},
+		{input: `m.bool_field`, want: "bool_field is true"},
+		{input: `!m.bool_field`, want: "bool_field not is true"},
+		{input: `m.enum_field == linka.cloud.test.Test.Type.ONE`, want: "enum_field eq 1"},
+		{input: `has(m.message_field)`, want: "message_field not is null"},
+		{input: `!has(m.optional_string_field)`, want: "optional_string_field is null"},
+		{input: `m.time_value_field > timestamp("1970-01-01T00:00:10Z")`, want: "time_value_field after 1970-01-01T00:00:10Z"},
+		{input: `m.duration_value_field < duration("1.5s")`, want: "duration_value_field inf 1.5s"},
+		{input: `m.message_field.string_field == "a"`, want: "message_field.string_field eq 'a'"},
+		{input: `m.string_field == "a" && m.number_field == 1`, want: "string_field eq 'a' and number_field eq 1"},
+		{input: `m.string_field == "a" || m.number_field == 1`, want: "string_field eq 'a' or number_field eq 1"},
+		{input: `!(m.string_field == "a" || m.number_field == 1)`, want: "string_field not eq 'a' and number_field not eq 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := cel.Parse(md, "m", tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.Format())
+		})
+	}
+	expr, err := cel.Parse(md, "", `string_field == "a" && number_field > 1`)
+	require.NoError(t, err)
+	assert.Equal(t, "string_field eq 'a' and number_field sup 1", expr.Format())
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: `m.string_field == m.string_field`, err: "literal"},
+		{input: `m.string_field + "a" == "ab"`, err: "expected a field"},
+		{input: `size(m.string_field) > 1`, err: "expected a field"},
+		{input: `m.repeated_string_field == ["a"]`, err: "repeated"},
+		{input: `!m.repeated_string_field.exists(x, x == "a")`, err: "negated"},
+		{input: `m.repeated_message_field.all(x, x.number_field == 1)`, err: "unsupported"},
+		{input: `m.repeated_message_field.exists(x, x.number_field == 1 && x.string_field == "a")`, err: "conjunctions"},
+		{input: `m.string_field == "a" ? true : false`, err: "unsupported"},
+		{input: `m.number_field`, err: "not a bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := cel.Parse(md, "m", tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+	_, err := cel.Parse(md, "m", `m.unknown == 1`)
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		f    filters.FieldFilterer
+		want string
+	}{
+		{f: filters.Where("string_field").StringEquals(`a "b"`), want: `m.string_field == "a \"b\""`},
+		{f: filters.Where("string_field").StringNotHasPrefix("a"), want: `!m.string_field.startsWith("a")`},
+		{f: filters.Where("string_field").StringIEquals("a.b"), want: `m.string_field.matches("(?i)^a\\.b$")`},
+		{f: filters.Where("string_field").StringNotIN("a", "b"), want: `!(m.string_field in ["a", "b"])`},
+		{f: filters.Not(filters.Where("string_field").StringInf("a")), want: `m.string_field >= "a"`},
+		{f: filters.Where("number_field").NumberSup(1), want: `m.number_field > 1`},
+		{f: filters.Where("number_field").NumberEquals(1.5), want: `double(m.number_field) == 1.5`},
+		{f: filters.Where("number_value_field").NumberIN(1, 2), want: `(has(m.number_value_field) ? m.number_value_field : 0) in [1, 2]`},
+		{f: filters.Where("enum_field").StringEquals("ONE"), want: `m.enum_field == 1`},
+		{f: filters.Where("bool_field").False(), want: `m.bool_field == false`},
+		{f: filters.Where("message_field").NotNull(), want: `has(m.message_field)`},
+		{f: filters.Where("optional_string_field").StringEquals("a"), want: `has(m.optional_string_field) && m.optional_string_field == "a"`},
+		{f: filters.Where("optional_string_field").StringNotEquals("a"), want: `!has(m.optional_string_field) || m.optional_string_field != "a"`},
+		{f: filters.Where("oneof_number_field").NumberNotEquals(1), want: `has(m.oneof_number_field) && m.oneof_number_field != 1`},
+		{f: filters.Where("repeated_string_field").StringEquals("a"), want: `m.repeated_string_field.exists(x0, x0 == "a")`},
+		{
+			f:    filters.Where("repeated_message_field.repeated_string_field").StringEquals("a"),
+			want: `m.repeated_message_field.exists(x0, x0.repeated_string_field.exists(x1, x1 == "a"))`,
+		},
+		{f: filters.Where("time_value_field").TimeBefore(time.Unix(1, 5).UTC()), want: `m.time_value_field < timestamp("1970-01-01T00:00:01.000000005Z")`},
+		{f: filters.Where("duration_value_field").DurationSup(-1500 * time.Millisecond), want: `m.duration_value_field > duration("-1.5s")`},
+		{
+			f:    filters.Where("string_field").StringEquals("a").AndWhere("number_field").NumberEquals(1).OrWhere("bool_field").True(),
+			want: `m.string_field == "a" && m.number_field == 1 || m.bool_field == true`,
+		},
+		{
+			f:    filters.Where("string_field").StringEquals("a").And(filters.Where("number_field").NumberEquals(1).OrWhere("bool_field").True()),
+			want: `m.string_field == "a" && (m.number_field == 1 || m.bool_field == true)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := cel.Format(md, "m", tt.f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("repeated_string_field").StringNotEquals("a"),
+		filters.Where("string_field").StringIInf("a"),
+		filters.Where("enum_field").StringEquals("THREE"),
+		filters.Where("unknown").StringEquals("a"),
+		filters.Where("string_field").NumberEquals(1),
+	} {
+		_, err := cel.Format(md, "m", f)
+		assert.Error(t, err, f.Expr().Format())
+	}
+	_, err := cel.Format(md, "", filters.Where("message_field").Null())
+	assert.Error(t, err)
+}
+
+// TestEval checks that the CEL expressions and their conversions match
+// the same messages
+func TestEval(t *testing.T) {
+	exprs := []string{
+		`m.string_field == "abc" && m.number_field == 1 || m.bool_field`,
+		`!(m.string_field.startsWith("a") || m.number_field >= 3)`,
+		`m.string_field in ["abc", "xyz"]`,
+		`"b" in m.repeated_string_field`,
+		`m.repeated_message_field.exists(x, x.number_field > 3)`,
+		`m.repeated_message_field.exists(x, x.repeated_string_field.exists(y, y == "x"))`,
+		`has(m.message_field) && m.message_field.string_field == "abc"`,
+		`has(m.optional_string_field)`,
+		`m.number_value_field == 3`,
+		`m.enum_field == linka.cloud.test.Test.Type.TWO`,
+		`m.time_value_field > timestamp("1970-01-01T00:00:05Z")`,
+		`m.duration_value_field <= duration("1s")`,
+	}
+	for _, v := range exprs {
+		t.Run(v, func(t *testing.T) {
+			expr, err := cel.Parse(md, "m", v)
+			require.NoError(t, err)
+			for _, m := range msgs {
+				ok, err := protofilters.Match(m, expr)
+				require.NoError(t, err)
+				assert.Equal(t, eval(t, v, m), ok, "%v", m)
+			}
+		})
+	}
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringIHasPrefix("ab"),
+		filters.Where("string_field").StringNotIN("abc", "xyz"),
+		filters.Where("number_field").NumberNotEquals(1.5),
+		filters.Where("number_value_field").NumberInf(4),
+		filters.Where("enum_field").StringNotEquals("ONE"),
+		filters.Where("optional_string_field").StringNotEquals("a"),
+		filters.Where("optional_number_field").NumberInf(0),
+		filters.Where("optional_enum_field").StringEquals("ONE"),
+		filters.Where("oneof_string_field").StringNotEquals("x"),
+		filters.Where("oneof_number_field").NumberSup(1),
+		filters.Where("message_field").Null(),
+		filters.Where("message_field.string_field").StringEquals("abc"),
+		filters.Where("repeated_message_field.number_field").NumberIN(4, 5),
+		filters.Where("repeated_message_field.repeated_string_field").StringEquals("x"),
+		filters.Not(filters.Where("time_value_field").TimeAfter(time.Unix(5, 0))),
+		filters.Not(filters.Where("duration_value_field").DurationInf(time.Second)),
+		filters.Not(filters.Where("string_field").StringEquals("abc").OrWhere("bool_field").True()),
+	}
+	for _, f := range fs {
+		t.Run(f.Expr().Format(), func(t *testing.T) {
+			s, err := cel.Format(md, "m", f)
+			require.NoError(t, err)
+			for _, m := range msgs {
+				ok, err := protofilters.Match(m, f)
+				require.NoError(t, err)
+				assert.Equal(t, ok, eval(t, s, m), "%s: %v", s, m)
+			}
+		})
+	}
+}
+
+func eval(t *testing.T, expr string, m *test.Test) bool {
+	env, err := cel.NewEnv(md, "m")
+	require.NoError(t, err)
+	a, iss := env.Compile(expr)
+	require.NoError(t, iss.Err())
+	prg, err := env.Program(a)
+	require.NoError(t, err)
+	out, _, err := prg.Eval(map[string]any{"m": m})
+	require.NoError(t, err)
+	return out.Value() == true
+}
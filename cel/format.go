@@ -0,0 +1,466 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package cel
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// Format formats the filter as a CEL expression over the message described
+// by md, bound to the variable name or whose fields are top-level variables
+// if name is empty.
+// Repeated fields are tested with the exists macro, and the oneof and
+// optional fields presence is checked so that the expression evaluates as
+// the filter would.
+// Unset wrapper fields are compared as their zero value, except when the
+// fields are top-level variables where they are null.
+func Format(md protoreflect.MessageDescriptor, name string, f filters.FieldFilterer) (string, error) {
+	if f == nil || f.Expr() == nil {
+		return "", nil
+	}
+	fm := &formatter{md: md, name: name}
+	s, _, err := fm.expr(f.Expr())
+	return s, err
+}
+
+type formatter struct {
+	md   protoreflect.MessageDescriptor
+	name string
+}
+
+// precedence is the lowest precedence operator of a formatted expression
+type precedence int
+
+const (
+	atomic precedence = iota
+	conjunction
+	disjunction
+)
+
+// and formats s as an operand of a conjunction
+func and(s string, p precedence) string {
+	if p == disjunction {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (fm *formatter) expr(e *filters.Expression) (string, precedence, error) {
+	var conj []string
+	var ps []precedence
+	if e.Condition != nil {
+		s, p, err := fm.fieldFilter(e.Condition)
+		if err != nil {
+			return "", atomic, err
+		}
+		conj, ps = append(conj, s), append(ps, p)
+	}
+	for _, v := range e.AndExprs {
+		s, p, err := fm.expr(v)
+		if err != nil {
+			return "", atomic, err
+		}
+		conj, ps = append(conj, s), append(ps, p)
+	}
+	if len(conj) == 1 && len(e.OrExprs) == 0 {
+		return conj[0], ps[0], nil
+	}
+	for i := range conj {
+		conj[i] = and(conj[i], ps[i])
+	}
+	if len(e.OrExprs) == 0 {
+		return strings.Join(conj, " && "), conjunction, nil
+	}
+	// && binds tighter than ||, so that the operands never need parenthesis
+	disj := []string{strings.Join(conj, " && ")}
+	for _, v := range e.OrExprs {
+		s, _, err := fm.expr(v)
+		if err != nil {
+			return "", atomic, err
+		}
+		disj = append(disj, s)
+	}
+	return strings.Join(disj, " || "), disjunction, nil
+}
+
+func (fm *formatter) fieldFilter(ff *filters.FieldFilter) (string, precedence, error) {
+	var fds []protoreflect.FieldDescriptor
+	md := fm.md
+	for _, v := range strings.Split(ff.GetField(), ".") {
+		if md == nil {
+			return "", atomic, fmt.Errorf("cel: %s: not a message path", ff.GetField())
+		}
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			return "", atomic, fmt.Errorf("cel: %w", &filters.UnknownFieldError{Message: string(md.FullName()), Path: v})
+		}
+		if fd.IsMap() {
+			return "", atomic, fmt.Errorf("cel: %s: map fields are not supported", ff.GetField())
+		}
+		fds = append(fds, fd)
+		md = fd.Message()
+	}
+	s, p, err := fm.path(fm.name, fds, ff.GetFilter(), 0)
+	if err != nil {
+		return "", atomic, fmt.Errorf("cel: %s: %w", ff.GetField(), err)
+	}
+	return s, p, nil
+}
+
+// path formats the filter applied to the fields path selected from ref
+func (fm *formatter) path(ref string, fds []protoreflect.FieldDescriptor, f *filters.Filter, depth int) (string, precedence, error) {
+	fd := fds[0]
+	r := string(fd.Name())
+	if ref != "" {
+		r = ref + "." + r
+	}
+	if fd.IsList() {
+		if len(fds) == 1 && f.GetNot() {
+			return "", atomic, fmt.Errorf("negated filters on repeated fields are not supported")
+		}
+		v := "x" + strconv.Itoa(depth)
+		var s string
+		var err error
+		if len(fds) == 1 {
+			s, err = fm.condition(v, fd, f)
+		} else {
+			s, _, err = fm.path(v, fds[1:], f, depth+1)
+		}
+		if err != nil {
+			return "", atomic, err
+		}
+		return r + ".exists(" + v + ", " + s + ")", atomic, nil
+	}
+	_, null := f.GetMatch().(*filters.Filter_Null)
+	var s string
+	p := atomic
+	var err error
+	if len(fds) == 1 {
+		v := r
+		// unset wrappers are null in CEL, but are matched as their zero value
+		if z, ok := wrapperZero(fd); ok && ref != "" && !null {
+			v = "(has(" + r + ") ? " + r + " : " + z + ")"
+		}
+		s, err = fm.condition(v, fd, f)
+	} else {
+		s, p, err = fm.path(r, fds[1:], f, depth)
+	}
+	if err != nil {
+		return "", atomic, err
+	}
+	switch {
+	case fd.ContainingOneof() != nil && !fd.ContainingOneof().IsSynthetic():
+		// the filters never match unset oneof fields
+		if ref == "" {
+			return "", atomic, fmt.Errorf("presence of %s cannot be tested without variable name", fd.Name())
+		}
+		return "has(" + r + ") && " + and(s, p), conjunction, nil
+	case fd.HasOptionalKeyword() && len(fds) == 1 && !null:
+		// only the negated filters match unset optional fields
+		if ref == "" {
+			return "", atomic, fmt.Errorf("presence of %s cannot be tested without variable name", fd.Name())
+		}
+		if f.GetNot() {
+			return "!has(" + r + ") || " + s, disjunction, nil
+		}
+		return "has(" + r + ") && " + s, conjunction, nil
+	}
+	return s, p, nil
+}
+
+// condition formats the filter applied to the value ref of the field fd
+func (fm *formatter) condition(ref string, fd protoreflect.FieldDescriptor, f *filters.Filter) (string, error) {
+	not := f.GetNot()
+	// op returns the operator, or the negated one if the filter is negated
+	op := func(op, negated string) string {
+		if not {
+			return negated
+		}
+		return op
+	}
+	neg := func(s string) string {
+		if not {
+			return "!" + s
+		}
+		return s
+	}
+	negGroup := func(s string) string {
+		if not {
+			return "!(" + s + ")"
+		}
+		return s
+	}
+	kind := kindOf(fd)
+	switch m := f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		if !strings.Contains(ref, ".") {
+			return "", fmt.Errorf("presence of %s cannot be tested without variable name", ref)
+		}
+		if kind != messageKind && !fd.HasOptionalKeyword() {
+			return "", fmt.Errorf("%s cannot be null", fd.Name())
+		}
+		if not {
+			return "has(" + ref + ")", nil
+		}
+		return "!has(" + ref + ")", nil
+	case *filters.Filter_Bool:
+		if kind != boolKind {
+			return "", &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "bool"}
+		}
+		return ref + " " + op("==", "!=") + " " + strconv.FormatBool(m.Bool.GetEquals()), nil
+	case *filters.Filter_String_:
+		sf := m.String_
+		if kind == enumKind {
+			return fm.enumCondition(ref, fd, sf, not)
+		}
+		if kind != stringKind {
+			return "", &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "string"}
+		}
+		if sf.GetCaseInsensitive() {
+			var re string
+			switch c := sf.GetCondition().(type) {
+			case *filters.StringFilter_Equals:
+				re = "(?i)^" + regexp.QuoteMeta(c.Equals) + "$"
+			case *filters.StringFilter_HasPrefix:
+				re = "(?i)^" + regexp.QuoteMeta(c.HasPrefix)
+			case *filters.StringFilter_HasSuffix:
+				re = "(?i)" + regexp.QuoteMeta(c.HasSuffix) + "$"
+			case *filters.StringFilter_Contains:
+				re = "(?i)" + regexp.QuoteMeta(c.Contains)
+			case *filters.StringFilter_In_:
+				var alts []string
+				for _, v := range c.In.GetValues() {
+					alts = append(alts, regexp.QuoteMeta(v))
+				}
+				re = "(?i)^(?:" + strings.Join(alts, "|") + ")$"
+			case *filters.StringFilter_Regex:
+				re = c.Regex
+			default:
+				return "", fmt.Errorf("case insensitive string ordering is not supported")
+			}
+			return neg(ref + ".matches(" + strconv.Quote(re) + ")"), nil
+		}
+		switch c := sf.GetCondition().(type) {
+		case *filters.StringFilter_Equals:
+			return ref + " " + op("==", "!=") + " " + strconv.Quote(c.Equals), nil
+		case *filters.StringFilter_HasPrefix:
+			return neg(ref + ".startsWith(" + strconv.Quote(c.HasPrefix) + ")"), nil
+		case *filters.StringFilter_HasSuffix:
+			return neg(ref + ".endsWith(" + strconv.Quote(c.HasSuffix) + ")"), nil
+		case *filters.StringFilter_Contains:
+			return neg(ref + ".contains(" + strconv.Quote(c.Contains) + ")"), nil
+		case *filters.StringFilter_Regex:
+			return neg(ref + ".matches(" + strconv.Quote(c.Regex) + ")"), nil
+		case *filters.StringFilter_Inf:
+			return ref + " " + op("<", ">=") + " " + strconv.Quote(c.Inf), nil
+		case *filters.StringFilter_Sup:
+			return ref + " " + op(">", "<=") + " " + strconv.Quote(c.Sup), nil
+		case *filters.StringFilter_In_:
+			var values []string
+			for _, v := range c.In.GetValues() {
+				values = append(values, strconv.Quote(v))
+			}
+			return negGroup(ref + " in [" + strings.Join(values, ", ") + "]"), nil
+		}
+	case *filters.Filter_Number:
+		if kind != numberKind && kind != enumKind {
+			return "", &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "number"}
+		}
+		switch c := m.Number.GetCondition().(type) {
+		case *filters.NumberFilter_Equals:
+			ref, v := numberOperands(ref, fd, c.Equals)
+			return ref + " " + op("==", "!=") + " " + v[0], nil
+		case *filters.NumberFilter_Inf:
+			ref, v := numberOperands(ref, fd, c.Inf)
+			return ref + " " + op("<", ">=") + " " + v[0], nil
+		case *filters.NumberFilter_Sup:
+			ref, v := numberOperands(ref, fd, c.Sup)
+			return ref + " " + op(">", "<=") + " " + v[0], nil
+		case *filters.NumberFilter_In_:
+			ref, v := numberOperands(ref, fd, c.In.GetValues()...)
+			return negGroup(ref + " in [" + strings.Join(v, ", ") + "]"), nil
+		}
+	case *filters.Filter_Time:
+		if kind != timestampKind {
+			return "", &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "time"}
+		}
+		t := func(v interface{ AsTime() time.Time }) string {
+			return "timestamp(" + strconv.Quote(v.AsTime().Format(time.RFC3339Nano)) + ")"
+		}
+		switch c := m.Time.GetCondition().(type) {
+		case *filters.TimeFilter_Equals:
+			return ref + " " + op("==", "!=") + " " + t(c.Equals), nil
+		case *filters.TimeFilter_Before:
+			return ref + " " + op("<", ">=") + " " + t(c.Before), nil
+		case *filters.TimeFilter_After:
+			return ref + " " + op(">", "<=") + " " + t(c.After), nil
+		}
+	case *filters.Filter_Duration:
+		if kind != durationKind {
+			return "", &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "duration"}
+		}
+		switch c := m.Duration.GetCondition().(type) {
+		case *filters.DurationFilter_Equals:
+			return ref + " " + op("==", "!=") + " " + duration(c.Equals), nil
+		case *filters.DurationFilter_Inf:
+			return ref + " " + op("<", ">=") + " " + duration(c.Inf), nil
+		case *filters.DurationFilter_Sup:
+			return ref + " " + op(">", "<=") + " " + duration(c.Sup), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported filter %q", f.Format())
+}
+
+// wrapperZero returns the zero value literal of the wrapper field fd
+func wrapperZero(fd protoreflect.FieldDescriptor) (string, bool) {
+	if fd.Message() == nil {
+		return "", false
+	}
+	switch fd.Message().FullName() {
+	case "google.protobuf.Int64Value", "google.protobuf.Int32Value":
+		return "0", true
+	case "google.protobuf.UInt64Value", "google.protobuf.UInt32Value":
+		return "0u", true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue":
+		return "0.0", true
+	case "google.protobuf.StringValue":
+		return `""`, true
+	case "google.protobuf.BoolValue":
+		return "false", true
+	}
+	return "", false
+}
+
+// enumCondition formats the string filters on enum names as comparisons
+// of the enum numbers
+func (fm *formatter) enumCondition(ref string, fd protoreflect.FieldDescriptor, f *filters.StringFilter, not bool) (string, error) {
+	var names []string
+	switch c := f.GetCondition().(type) {
+	case *filters.StringFilter_Equals:
+		names = []string{c.Equals}
+	case *filters.StringFilter_In_:
+		names = c.In.GetValues()
+	default:
+		return "", fmt.Errorf("only equality is supported on enum names")
+	}
+	if f.GetCaseInsensitive() {
+		return "", fmt.Errorf("case insensitive equality is not supported on enum names")
+	}
+	var values []string
+	for _, v := range names {
+		ev := fd.Enum().Values().ByName(protoreflect.Name(v))
+		if ev == nil {
+			return "", fmt.Errorf("%s has no value %q", fd.Enum().FullName(), v)
+		}
+		values = append(values, strconv.Itoa(int(ev.Number())))
+	}
+	if len(values) == 1 {
+		if not {
+			return ref + " != " + values[0], nil
+		}
+		return ref + " == " + values[0], nil
+	}
+	s := ref + " in [" + strings.Join(values, ", ") + "]"
+	if not {
+		return "!(" + s + ")", nil
+	}
+	return s, nil
+}
+
+// numberOperands returns the field reference and the values literals typed
+// as the field, or converted to double if a value cannot be represented
+// with the field type
+func numberOperands(ref string, fd protoreflect.FieldDescriptor, values ...float64) (string, []string) {
+	var signed, unsigned bool
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind,
+		protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind, protoreflect.EnumKind:
+		signed = true
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		unsigned = true
+	case protoreflect.MessageKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.Int64Value", "google.protobuf.Int32Value":
+			signed = true
+		case "google.protobuf.UInt64Value", "google.protobuf.UInt32Value":
+			unsigned = true
+		}
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		switch {
+		case signed && v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64:
+			out[i] = strconv.FormatInt(int64(v), 10)
+		case unsigned && v == math.Trunc(v) && v >= 0 && v < math.MaxUint64:
+			out[i] = strconv.FormatUint(uint64(v), 10) + "u"
+		case signed || unsigned:
+			// fall back to a double comparison
+			return "double(" + ref + ")", doubles(values)
+		default:
+			out[i] = double(v)
+		}
+	}
+	return ref, out
+}
+
+func doubles(values []float64) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = double(v)
+	}
+	return out
+}
+
+func double(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return `double("NaN")`
+	case math.IsInf(v, 1):
+		return `double("Infinity")`
+	case math.IsInf(v, -1):
+		return `double("-Infinity")`
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// duration formats the duration as seconds, e.g. duration("1.5s")
+func duration(d *durationpb.Duration) string {
+	s, n := d.GetSeconds(), d.GetNanos()
+	sign := ""
+	if s < 0 || n < 0 {
+		sign, s, n = "-", -s, -n
+	}
+	v := strconv.FormatInt(s, 10)
+	if n != 0 {
+		v += "." + strings.TrimRight(fmt.Sprintf("%09d", n), "0")
+	}
+	return `duration("` + sign + v + `s")`
+}
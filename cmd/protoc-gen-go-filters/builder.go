@@ -0,0 +1,164 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/reflect"
+)
+
+// builderField is a field of the generated filter builder
+type builderField struct {
+	f *protogen.Field
+	// typ is the filters interface of the field, e.g. StringFilterer
+	typ string
+	// msg is the builder of the nested message fields
+	msg *protogen.Message
+}
+
+// generateBuilder generates the type-safe filter builder of the message,
+// exposing for each field only the operators valid for its kind
+func (g *generator) generateBuilder(gf *protogen.GeneratedFile, m *protogen.Message) {
+	name := m.GoIdent.GoName + "FilterBuilder"
+	ctor := "new" + name
+	var fields []builderField
+	null := true
+	for _, f := range m.Fields {
+		if f.GoName == "Null" {
+			null = false
+		}
+		if v, ok := g.builderField(f); ok {
+			fields = append(fields, v)
+		}
+	}
+	fieldFilter := filtersPackage.Ident("FieldFilter")
+
+	// the FilterBy suffix does not collide with the hand-written Filter helpers
+	root := m.GoIdent.GoName + "FilterBy"
+	gf.P("// ", root, " builds the filters on the ", m.Desc.FullName(), " fields.")
+	gf.P("var ", root, " = ", ctor, "(\"\")")
+	gf.P()
+	gf.P("// ", name, " builds the filters on the ", m.Desc.FullName(), " fields,")
+	gf.P("// exposing only the operators valid for each field.")
+	gf.P("type ", name, " struct {")
+	gf.P("field string")
+	for _, v := range fields {
+		if v.msg == nil {
+			gf.P(v.f.GoName, " ", filtersPackage.Ident(v.typ))
+		}
+	}
+	gf.P("}")
+	gf.P()
+	gf.P("func ", ctor, "(field string) ", name, " {")
+	gf.P("f := ", name, "{field: field}")
+	for _, v := range fields {
+		if v.msg == nil {
+			gf.P("f.", v.f.GoName, " = ", filtersPackage.Ident(fieldConstructor(v.typ)), "(f.path(", fmt.Sprintf("%q", v.f.Desc.Name()), "))")
+		}
+	}
+	gf.P("return f")
+	gf.P("}")
+	gf.P()
+	for _, v := range fields {
+		if v.msg == nil {
+			continue
+		}
+		if v.f.Desc.IsList() {
+			gf.P("// ", v.f.GoName, " builds the filters on the fields of the ", v.f.Desc.Name(), " elements,")
+			gf.P("// matching if one of them matches.")
+		} else {
+			gf.P("// ", v.f.GoName, " builds the filters on the ", v.f.Desc.Name(), " fields.")
+		}
+		gf.P("func (f ", name, ") ", v.f.GoName, "() ", v.msg.GoIdent.GoName, "FilterBuilder {")
+		gf.P("return new", v.msg.GoIdent.GoName, "FilterBuilder(f.path(", fmt.Sprintf("%q", v.f.Desc.Name()), "))")
+		gf.P("}")
+		gf.P()
+	}
+	if null {
+		gf.P("// Null matches if the message field is not set.")
+		gf.P("// It is not valid on ", root, ", which has no field.")
+		gf.P("func (f ", name, ") Null() *", fieldFilter, " {")
+		gf.P("return ", filtersPackage.Ident("NullField"), "(f.field).Null()")
+		gf.P("}")
+		gf.P()
+	}
+	gf.P("func (f ", name, ") path(name string) string {")
+	gf.P("if f.field == \"\" {")
+	gf.P("return name")
+	gf.P("}")
+	gf.P("return f.field + \".\" + name")
+	gf.P("}")
+	gf.P()
+}
+
+func (g *generator) builderField(f *protogen.Field) (builderField, bool) {
+	fd := f.Desc
+	v := builderField{f: f}
+	if fd.IsMap() || fd.IsWeak() || fd.Kind() == protoreflect.GroupKind || fd.Kind() == protoreflect.BytesKind {
+		return v, false
+	}
+	nullable := fd.HasOptionalKeyword()
+	if m := f.Message; m != nil {
+		// the builders of the other packages are not accessible
+		if g.generated[fd.Message().FullName()] && m.GoIdent.GoImportPath == f.Parent.GoIdent.GoImportPath {
+			v.msg = m
+			return v, true
+		}
+		if fd.IsList() {
+			return v, false
+		}
+		nullable = true
+		switch reflect.WKType(fd.Message().FullName()) {
+		case reflect.Timestamp:
+			v.typ = "TimeFilterer"
+		case reflect.Duration:
+			v.typ = "DurationFilterer"
+		case reflect.StringValue:
+			v.typ = "StringFilterer"
+		case reflect.BoolValue:
+			v.typ = "BoolFilterer"
+		case reflect.DoubleValue, reflect.FloatValue, reflect.Int64Value, reflect.Int32Value, reflect.UInt64Value, reflect.UInt32Value:
+			v.typ = "NumberFilterer"
+		default:
+			v.typ = "NullFilterer"
+			return v, true
+		}
+	} else {
+		switch fd.Kind() {
+		case protoreflect.StringKind, protoreflect.EnumKind:
+			v.typ = "StringFilterer"
+		case protoreflect.BoolKind:
+			v.typ = "BoolFilterer"
+		default:
+			v.typ = "NumberFilterer"
+		}
+	}
+	if nullable {
+		v.typ = "Nullable" + v.typ
+	}
+	return v, true
+}
+
+// fieldConstructor returns the filters constructor of the interface,
+// e.g. NullableStringField for NullableStringFilterer
+func fieldConstructor(typ string) string {
+	return typ[:len(typ)-len("Filterer")] + "Field"
+}
@@ -0,0 +1,356 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// protoc-gen-go-filters generates for each message a MatchFilter method
+// matching the message against a filters expression with direct fields
+// accesses, used by protofilters.Match instead of reflection.
+// The paths and filters not supported by the generated code are matched by
+// the reflective matcher.
+// It also generates for each message a type-safe filter builder, e.g.
+// TestFilterBy.NumberField.Sup(3), exposing only the operators valid for each
+// field.
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"go.linka.cloud/protofilters/reflect"
+)
+
+const (
+	filtersPackage      = protogen.GoImportPath("go.linka.cloud/protofilters/filters")
+	reflectPackage      = protogen.GoImportPath("go.linka.cloud/protofilters/reflect")
+	stringsPackage      = protogen.GoImportPath("strings")
+	protoreflectPackage = protogen.GoImportPath("google.golang.org/protobuf/reflect/protoreflect")
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		g := &generator{generated: make(map[protoreflect.FullName]bool)}
+		for _, f := range gen.Files {
+			if g.skip(f) {
+				continue
+			}
+			g.register(f.Messages)
+		}
+		for _, f := range gen.Files {
+			if g.skip(f) {
+				continue
+			}
+			g.generateFile(gen, f)
+		}
+		return nil
+	})
+}
+
+type generator struct {
+	// generated are the messages with a generated matcher
+	generated map[protoreflect.FullName]bool
+}
+
+// skip reports whether no matcher is generated for the file, as the
+// packages used by the generated code cannot import themselves
+func (g *generator) skip(f *protogen.File) bool {
+	return !f.Generate || f.GoImportPath == filtersPackage || f.GoImportPath == reflectPackage
+}
+
+func (g *generator) register(msgs []*protogen.Message) {
+	for _, m := range msgs {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+		g.generated[m.Desc.FullName()] = true
+		g.register(m.Messages)
+	}
+}
+
+func (g *generator) generateFile(gen *protogen.Plugin, f *protogen.File) {
+	if len(g.messages(f.Messages)) == 0 {
+		return
+	}
+	gf := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+".pb.filters.go", f.GoImportPath)
+	// copy the leading comments of the syntax statement, e.g. the licence
+	if loc := f.Desc.SourceLocations().ByPath(protoreflect.SourcePath{12}); len(loc.LeadingDetachedComments) != 0 {
+		for _, v := range loc.LeadingDetachedComments {
+			gf.P(protogen.Comments(v))
+		}
+	}
+	gf.P("// Code generated by protoc-gen-go-filters. DO NOT EDIT.")
+	gf.P("// source: ", f.Desc.Path())
+	gf.P()
+	gf.P("package ", f.GoPackageName)
+	gf.P()
+	for _, m := range g.messages(f.Messages) {
+		g.generateMessage(gf, m)
+	}
+}
+
+func (g *generator) messages(msgs []*protogen.Message) []*protogen.Message {
+	var out []*protogen.Message
+	for _, m := range msgs {
+		if m.Desc.IsMapEntry() {
+			continue
+		}
+		out = append(out, m)
+		out = append(out, g.messages(m.Messages)...)
+	}
+	return out
+}
+
+func (g *generator) generateMessage(gf *protogen.GeneratedFile, m *protogen.Message) {
+	expr := gf.QualifiedGoIdent(filtersPackage.Ident("Expression"))
+	filter := gf.QualifiedGoIdent(filtersPackage.Ident("Filter"))
+	unsupported := gf.QualifiedGoIdent(reflectPackage.Ident("ErrUnsupported"))
+
+	gf.P("// MatchFilter matches the message against the expression without reflection.")
+	gf.P("// It returns ", unsupported, " if the expression uses fields paths or")
+	gf.P("// filters which must be matched by the reflective matcher.")
+	gf.P("func (x *", m.GoIdent, ") MatchFilter(expr *", expr, ") (bool, error) {")
+	gf.P("return ", reflectPackage.Ident("MatchExpression"), "(x, expr)")
+	gf.P("}")
+	gf.P()
+
+	var cases []fieldCase
+	for _, f := range m.Fields {
+		if c, ok := g.fieldCase(gf, f); ok {
+			cases = append(cases, c)
+		}
+	}
+	gf.P("// MatchFieldFilter matches the field path of the message against the filter")
+	gf.P("// without reflection. It returns ", unsupported, " if the path or the filter")
+	gf.P("// must be matched by the reflective matcher.")
+	gf.P("func (x *", m.GoIdent, ") MatchFieldFilter(path string, f *", filter, ") (bool, error) {")
+	if len(cases) != 0 {
+		rest := "_"
+		for _, c := range cases {
+			if c.nested {
+				rest = "rest"
+			}
+		}
+		gf.P("name, ", rest, ", nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+		gf.P("switch name {")
+		for _, c := range cases {
+			gf.P("case ", fmt.Sprintf("%q", c.name), ":")
+			for _, v := range c.body {
+				gf.P(v...)
+			}
+		}
+		gf.P("}")
+	}
+	gf.P("return false, ", unsupported)
+	gf.P("}")
+	gf.P()
+
+	g.generateBuilder(gf, m)
+}
+
+// fieldCase is the switch case matching a field
+type fieldCase struct {
+	name string
+	// nested is set if the case matches the paths nested in the field
+	nested bool
+	body   [][]any
+}
+
+func (g *generator) fieldCase(gf *protogen.GeneratedFile, f *protogen.Field) (fieldCase, bool) {
+	c := fieldCase{name: string(f.Desc.Name())}
+	p := func(v ...any) {
+		c.body = append(c.body, v)
+	}
+	fd := f.Desc
+	if fd.IsMap() || fd.IsWeak() || fd.Kind() == protoreflect.GroupKind || fd.Kind() == protoreflect.BytesKind {
+		return c, false
+	}
+	msg := fd.Message() != nil
+	nested := msg && !fd.IsList() && g.generated[fd.Message().FullName()]
+	leafed := false
+	leaf := func() {
+		if !nested && !leafed {
+			leafed = true
+			p("if nested {")
+			p("break")
+			p("}")
+		}
+	}
+	// the unset real oneof fields never match
+	if o := f.Oneof; o != nil && !o.Desc.IsSynthetic() {
+		// the paths nested in the scalar fields are invalid even if unset
+		leaf()
+		p("if _, ok := x.Get", o.GoName, "().(*", f.GoIdent, "); !ok {")
+		if nested {
+			// the nested path must still be valid
+			p("if nested {")
+			p("if _, err := (*", f.Message.GoIdent, ")(nil).MatchFieldFilter(rest, f); ", errorsIs(gf), "(err, ", reflectPackage.Ident("ErrUnsupported"), ") {")
+			p("return false, err")
+			p("}")
+			p("}")
+		}
+		p("return false, nil")
+		p("}")
+	}
+	get := "x.Get" + f.GoName + "()"
+	if fd.IsList() {
+		if msg {
+			if !g.generated[fd.Message().FullName()] {
+				return c, false
+			}
+			c.nested = true
+			p("if !nested {")
+			p("break")
+			p("}")
+			p("for _, v := range ", get, " {")
+			p("if ok, err := v.MatchFieldFilter(rest, f); err != nil || ok {")
+			p("return ok, err")
+			p("}")
+			p("}")
+			// the nested path must still be valid without elements
+			p("if _, err := (*", f.Message.GoIdent, ")(nil).MatchFieldFilter(rest, f); ", errorsIs(gf), "(err, ", reflectPackage.Ident("ErrUnsupported"), ") {")
+			p("return false, err")
+			p("}")
+			p("return false, nil")
+			return c, true
+		}
+		match, ok := g.scalar(gf, fd, "v", "Implicit")
+		if !ok {
+			return c, false
+		}
+		leaf()
+		p("return ", reflectPackage.Ident("MatchRepeated"), "(", get, ", f, func(v ", g.goType(gf, f), ", f *", filtersPackage.Ident("Filter"), ") (bool, error) {")
+		p("return ", match)
+		p("})")
+		return c, true
+	}
+	if msg {
+		if fd.Message().FullName() == "google.protobuf.Any" || !nested && !isWKT(fd.Message().FullName()) {
+			// only the null filter is supported by the other messages
+			leaf()
+			p("return ", reflectPackage.Ident("MatchNull"), "(", get, " == nil, f)")
+			return c, true
+		}
+		if nested {
+			c.nested = true
+			p("if nested {")
+			p("return ", get, ".MatchFieldFilter(rest, f)")
+			p("}")
+			p("return ", reflectPackage.Ident("MatchNull"), "(", get, " == nil, f)")
+			return c, true
+		}
+		match, ok := g.wkt(fd, get)
+		if !ok {
+			return c, false
+		}
+		leaf()
+		p("if f.GetNull() != nil {")
+		p("return ", reflectPackage.Ident("MatchNull"), "(", get, " == nil, f)")
+		p("}")
+		p(append([]any{"return "}, match...)...)
+		return c, true
+	}
+	if fd.HasOptionalKeyword() {
+		absent, ok := g.scalar(gf, fd, get, "Absent")
+		if !ok {
+			return c, false
+		}
+		present, _ := g.scalar(gf, fd, get, "Present")
+		leaf()
+		p("if x == nil || x.", f.GoName, " == nil {")
+		p("return ", absent)
+		p("}")
+		p("return ", present)
+		return c, true
+	}
+	match, ok := g.scalar(gf, fd, get, "Implicit")
+	if !ok {
+		return c, false
+	}
+	leaf()
+	p("return ", match)
+	return c, true
+}
+
+// scalar returns the call matching the scalar value v
+func (g *generator) scalar(gf *protogen.GeneratedFile, fd protoreflect.FieldDescriptor, v string, presence string) (string, bool) {
+	p := gf.QualifiedGoIdent(reflectPackage.Ident(presence))
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return fmt.Sprintf("%s(%s, %s, f)", gf.QualifiedGoIdent(reflectPackage.Ident("MatchString")), v, p), true
+	case protoreflect.BoolKind:
+		return fmt.Sprintf("%s(%s, %s, f)", gf.QualifiedGoIdent(reflectPackage.Ident("MatchBool")), v, p), true
+	case protoreflect.EnumKind:
+		return fmt.Sprintf("%s(%s(%s), %s.Descriptor(), %s, f)", gf.QualifiedGoIdent(reflectPackage.Ident("MatchEnum")), gf.QualifiedGoIdent(protoreflectPackage.Ident("EnumNumber")), v, v, p), true
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind,
+		protoreflect.FloatKind, protoreflect.DoubleKind:
+		return fmt.Sprintf("%s(float64(%s), %s, f)", gf.QualifiedGoIdent(reflectPackage.Ident("MatchNumber")), v, p), true
+	}
+	return "", false
+}
+
+// wkt returns the call matching the well known type value v
+func (g *generator) wkt(fd protoreflect.FieldDescriptor, v string) ([]any, bool) {
+	implicit := reflectPackage.Ident("Implicit")
+	switch reflect.WKType(fd.Message().FullName()) {
+	case reflect.Timestamp:
+		return []any{reflectPackage.Ident("MatchTime"), "(", v, ".GetSeconds(), ", v, ".GetNanos(), f)"}, true
+	case reflect.Duration:
+		return []any{reflectPackage.Ident("MatchDuration"), "(", v, ".GetSeconds(), ", v, ".GetNanos(), f)"}, true
+	case reflect.StringValue:
+		return []any{reflectPackage.Ident("MatchString"), "(", v, ".GetValue(), ", implicit, ", f)"}, true
+	case reflect.BoolValue:
+		return []any{reflectPackage.Ident("MatchBool"), "(", v, ".GetValue(), ", implicit, ", f)"}, true
+	case reflect.DoubleValue, reflect.FloatValue, reflect.Int64Value, reflect.Int32Value, reflect.UInt64Value, reflect.UInt32Value:
+		return []any{reflectPackage.Ident("MatchNumber"), "(float64(", v, ".GetValue()), ", implicit, ", f)"}, true
+	}
+	return nil, false
+}
+
+func (g *generator) goType(gf *protogen.GeneratedFile, f *protogen.Field) string {
+	switch f.Desc.Kind() {
+	case protoreflect.StringKind:
+		return "string"
+	case protoreflect.BoolKind:
+		return "bool"
+	case protoreflect.EnumKind:
+		return gf.QualifiedGoIdent(f.Enum.GoIdent)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int32"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return "int64"
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return "uint32"
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "uint64"
+	case protoreflect.FloatKind:
+		return "float32"
+	default:
+		return "float64"
+	}
+}
+
+func errorsIs(gf *protogen.GeneratedFile) string {
+	return gf.QualifiedGoIdent(protogen.GoImportPath("errors").Ident("Is"))
+}
+
+// isWKT reports whether the message is matched as a value
+func isWKT(name protoreflect.FullName) bool {
+	return reflect.IsWKType(name)
+}
@@ -0,0 +1,237 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// Explanation is the trace of the match of an expression, mirroring it.
+type Explanation struct {
+	// Condition is the field filter of the expression, if any
+	Condition *filters.FieldFilter
+	// Values are the values read to match the condition
+	Values []ExplainedValue
+	// Match is the result of the condition
+	Match bool
+	// And and Or are the explanations of the and / or expressions
+	And []*Explanation
+	Or  []*Explanation
+	// Result is the result of the expression
+	Result bool
+	// Skipped reports whether the expression was short-circuited, i.e. not
+	// evaluated as its result was not needed
+	Skipped bool
+}
+
+// ExplainedValue is a field value read to match a condition.
+type ExplainedValue struct {
+	// Path is the field path of the value, with the index of the repeated
+	// fields elements, e.g. repeated_message_field[1].string_field.
+	// The paths in the messages packed in the google.protobuf.Any fields
+	// and in the google.protobuf.Value fields are relative to them.
+	Path string
+	// Value is the value, nil if unset: the enum values are their name and
+	// the messages values their proto.Message
+	Value any
+	// Match reports whether the value matched the filter
+	Match bool
+}
+
+// MatchExplain is a convenient method calling MatchExplain on the defaultMatcher
+func MatchExplain(msg proto.Message, f filters.FieldFilterer) (*Explanation, error) {
+	return defaultMatcher.MatchExplain(msg, f)
+}
+
+// MatchExplain matches the message against the expression, returning the
+// trace of the match, whose Result is the Match result.
+// The message is always matched by reflection.
+func (m *matcher) MatchExplain(msg proto.Message, f filters.FieldFilterer) (*Explanation, error) {
+	if msg == nil {
+		return nil, errors.New("message is null")
+	}
+	if f == nil || f.Expr() == nil {
+		return &Explanation{Match: true, Result: true}, nil
+	}
+	if err := m.limits.Check(f); err != nil {
+		return nil, err
+	}
+	s := newMatchState(context.Background(), m.limits)
+	s.explain = true
+	return m.explainExpression(s, msg, f.Expr())
+}
+
+func (m *matcher) explainExpression(s *matchState, msg proto.Message, expr *filters.Expression) (*Explanation, error) {
+	e := &Explanation{Condition: expr.Condition}
+	s.values = nil
+	ok, err := m.matchFieldFilter(s, msg, expr.Condition)
+	if err != nil {
+		return nil, err
+	}
+	e.Match, e.Values = ok, s.values
+	for _, v := range expr.AndExprs {
+		if !ok {
+			e.And = append(e.And, skipped(v))
+			continue
+		}
+		c, err := m.explainExpression(s, msg, v)
+		if err != nil {
+			return nil, err
+		}
+		e.And = append(e.And, c)
+		ok = c.Result
+	}
+	for _, v := range expr.OrExprs {
+		if ok {
+			e.Or = append(e.Or, skipped(v))
+			continue
+		}
+		c, err := m.explainExpression(s, msg, v)
+		if err != nil {
+			return nil, err
+		}
+		e.Or = append(e.Or, c)
+		ok = c.Result
+	}
+	e.Result = ok
+	return e, nil
+}
+
+// skipped returns the explanation of the short-circuited expression
+func skipped(expr *filters.Expression) *Explanation {
+	e := &Explanation{Condition: expr.Condition, Skipped: true}
+	for _, v := range expr.AndExprs {
+		e.And = append(e.And, skipped(v))
+	}
+	for _, v := range expr.OrExprs {
+		e.Or = append(e.Or, skipped(v))
+	}
+	return e
+}
+
+func (s *matchState) explaining() bool {
+	return s != nil && s.explain
+}
+
+// record records the value read at the current path, i being the index of
+// the list element or -1
+func (s *matchState) record(v pref.Value, fd pref.FieldDescriptor, i int, match bool) {
+	if !s.explaining() {
+		return
+	}
+	path := strings.Join(s.path, ".")
+	if i >= 0 {
+		path = fmt.Sprintf("%s[%d]", path, i)
+	}
+	s.values = append(s.values, ExplainedValue{Path: path, Value: explainedValue(v, fd), Match: match})
+}
+
+func explainedValue(v pref.Value, fd pref.FieldDescriptor) any {
+	if !v.IsValid() {
+		return nil
+	}
+	switch fd.Kind() {
+	case pref.EnumKind:
+		if e := fd.Enum().Values().ByNumber(v.Enum()); e != nil {
+			return string(e.Name())
+		}
+		return int32(v.Enum())
+	case pref.MessageKind, pref.GroupKind:
+		if !v.Message().IsValid() {
+			return nil
+		}
+		return v.Message().Interface()
+	}
+	return v.Interface()
+}
+
+// String renders the explanation, one line per expression with the
+// formatted condition and its result, followed by the values read, e.g.
+//
+//	[true] string_field eq 'a'
+//	    string_field = "a"
+//	  AND [false] number_field gt 1
+//	      number_field = 0 (no match)
+//	  OR [skipped] bool_field eq true
+//	=> false
+func (e *Explanation) String() string {
+	var b strings.Builder
+	e.render(&b, "", "")
+	if len(e.And) != 0 || len(e.Or) != 0 {
+		fmt.Fprintf(&b, "=> %t\n", e.Result)
+	}
+	return b.String()
+}
+
+func (e *Explanation) render(b *strings.Builder, indent, op string) {
+	cond := "true"
+	if e.Condition != nil {
+		cond = e.Condition.Format()
+	}
+	status := fmt.Sprint(e.Match)
+	if e.Skipped {
+		status = "skipped"
+	}
+	fmt.Fprintf(b, "%s%s[%s] %s\n", indent, op, status, cond)
+	for _, v := range e.Values {
+		fmt.Fprintf(b, "%s    %s = %s", indent, v.Path, formatValue(v.Value))
+		if !v.Match {
+			b.WriteString(" (no match)")
+		}
+		b.WriteByte('\n')
+	}
+	for _, v := range e.And {
+		v.render(b, indent+"  ", "AND ")
+	}
+	for _, v := range e.Or {
+		v.render(b, indent+"  ", "OR ")
+	}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		return fmt.Sprintf("%q", v)
+	case proto.Message:
+		b, err := protojson.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		// protojson output is not stable
+		var out bytes.Buffer
+		if err := json.Compact(&out, b); err != nil {
+			return string(b)
+		}
+		return out.String()
+	}
+	return fmt.Sprint(v)
+}
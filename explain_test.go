@@ -0,0 +1,84 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestMatchExplain(t *testing.T) {
+	m := &test.Test{
+		StringField:          "a",
+		EnumField:            test.Test_Type(1),
+		RepeatedMessageField: []*test.Test{{StringField: "a"}, {StringField: "b"}},
+	}
+	f := filters.Where("string_field").StringEquals("a").
+		And(filters.Where("number_field").NumberSup(1)).
+		Or(filters.Where("repeated_message_field.string_field").StringEquals("b")).
+		Or(filters.Where("enum_field").StringEquals("OTHER"))
+	ok, err := Match(m, f)
+	require.NoError(t, err)
+
+	e, err := MatchExplain(m, f)
+	require.NoError(t, err)
+	assert.Equal(t, ok, e.Result)
+	assert.True(t, e.Result)
+	assert.True(t, e.Match)
+	assert.Equal(t, []ExplainedValue{{Path: "string_field", Value: "a", Match: true}}, e.Values)
+
+	require.Len(t, e.And, 1)
+	assert.False(t, e.And[0].Result)
+	assert.Equal(t, []ExplainedValue{{Path: "number_field", Value: int64(0)}}, e.And[0].Values)
+
+	require.Len(t, e.Or, 2)
+	assert.True(t, e.Or[0].Result)
+	assert.False(t, e.Or[0].Skipped)
+	assert.Equal(t, []ExplainedValue{
+		{Path: "repeated_message_field[0].string_field", Value: "a"},
+		{Path: "repeated_message_field[1].string_field", Value: "b", Match: true},
+	}, e.Or[0].Values)
+	assert.True(t, e.Or[1].Skipped)
+	assert.Empty(t, e.Or[1].Values)
+
+	assert.Equal(t, `[true] string_field eq 'a'
+    string_field = "a"
+  AND [false] number_field sup 1
+      number_field = 0 (no match)
+  OR [true] repeated_message_field.string_field eq 'b'
+      repeated_message_field[0].string_field = "a" (no match)
+      repeated_message_field[1].string_field = "b"
+  OR [skipped] enum_field eq 'OTHER'
+=> true
+`, e.String())
+
+	e, err = MatchExplain(m, filters.Where("enum_field").StringEquals("OTHER"))
+	require.NoError(t, err)
+	assert.Equal(t, []ExplainedValue{{Path: "enum_field", Value: test.Test_Type(1).String()}}, e.Values)
+
+	e, err = MatchExplain(m, nil)
+	require.NoError(t, err)
+	assert.True(t, e.Result)
+
+	_, err = MatchExplain(m, filters.Where("unknown").StringEquals("a"))
+	assert.ErrorIs(t, err, filters.ErrUnknownField)
+}
@@ -0,0 +1,299 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"context"
+	"iter"
+	"runtime"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// FilterOption configures Filter
+type FilterOption func(o *filterOptions)
+
+type filterOptions struct {
+	matcher   Matcher
+	workers   int
+	batch     int
+	unordered bool
+	limit     int
+}
+
+// WithMatcher sets the matcher used by the workers.
+// It defaults to the default matcher.
+func WithMatcher(m Matcher) FilterOption {
+	return func(o *filterOptions) {
+		o.matcher = m
+	}
+}
+
+// WithWorkers sets the number of workers matching the messages.
+// It defaults to runtime.GOMAXPROCS(0); the messages are matched by the
+// calling goroutine with a single worker.
+func WithWorkers(n int) FilterOption {
+	return func(o *filterOptions) {
+		o.workers = n
+	}
+}
+
+// WithBatchSize sets the number of messages sent at once to the workers.
+// It defaults to 64. The messages of a sequence producing them slowly, e.g.
+// from a channel, are only matched once a batch is full or the sequence
+// ends, so that a small size should be used.
+func WithBatchSize(n int) FilterOption {
+	return func(o *filterOptions) {
+		o.batch = n
+	}
+}
+
+// WithUnordered yields the matching messages as soon as their batch is
+// matched instead of in the sequence order.
+func WithUnordered() FilterOption {
+	return func(o *filterOptions) {
+		o.unordered = true
+	}
+}
+
+// WithLimit stops the filtering after n matching messages.
+func WithLimit(n int) FilterOption {
+	return func(o *filterOptions) {
+		o.limit = n
+	}
+}
+
+// Filter returns the messages of the sequence matching the filter, matched
+// by a pool of workers sharing the matcher and its resolved fields paths.
+// The messages are yielded in the sequence order unless WithUnordered is
+// set.
+// A matching error or the context cancellation is yielded with the
+// message, or the zero value, and ends the sequence.
+// With several workers, the sequence is ranged by another goroutine, which
+// is waited for before the returned sequence ends, i.e. until the sequence
+// yields its next element or returns. A sequence blocking until its next
+// element, e.g. on a channel, should return when the context is done, so
+// that cancelling it before breaking the loop does not block.
+func Filter[T proto.Message](ctx context.Context, seq iter.Seq[T], f filters.FieldFilterer, opts ...FilterOption) iter.Seq2[T, error] {
+	o := filterOptions{matcher: defaultMatcher, workers: runtime.GOMAXPROCS(0), batch: 64}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.batch < 1 {
+		o.batch = 1
+	}
+	var expr *filters.Expression
+	if f != nil {
+		expr = f.Expr()
+	}
+	if o.workers <= 1 {
+		return func(yield func(T, error) bool) {
+			filterSeq(ctx, seq, expr, o, yield)
+		}
+	}
+	return func(yield func(T, error) bool) {
+		filterPool(ctx, seq, expr, o, yield)
+	}
+}
+
+func filterSeq[T proto.Message](ctx context.Context, seq iter.Seq[T], expr *filters.Expression, o filterOptions, yield func(T, error) bool) {
+	n := 0
+	for v := range seq {
+		if err := ctx.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+		ok, err := o.matcher.MatchContext(ctx, v, expr)
+		if err != nil {
+			yield(v, err)
+			return
+		}
+		if !ok {
+			continue
+		}
+		if !yield(v, nil) {
+			return
+		}
+		if n++; n == o.limit {
+			return
+		}
+	}
+}
+
+// filterBatch is a batch of messages, whose matching ones replace them once
+// matched
+type filterBatch[T proto.Message] struct {
+	i    int
+	msgs []T
+	// err is the matching error of the last message
+	err error
+}
+
+func filterPool[T proto.Message](ctx context.Context, seq iter.Seq[T], expr *filters.Expression, o filterOptions, yield func(T, error) bool) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	// produced is closed once the producer stopped ranging the sequence
+	produced := make(chan struct{})
+	defer workers.Wait()
+	defer func() { <-produced }()
+	defer cancel()
+
+	jobs := make(chan *filterBatch[T], o.workers)
+	results := make(chan *filterBatch[T], o.workers)
+	// the pending batches are bounded, so that the ordered results do not
+	// wait for a slow one without limit
+	pending := make(chan struct{}, 4*o.workers)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(produced)
+		defer close(jobs)
+		send := func(b *filterBatch[T]) bool {
+			select {
+			case pending <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			select {
+			case jobs <- b:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		b := &filterBatch[T]{msgs: make([]T, 0, o.batch)}
+		for v := range seq {
+			if ctx.Err() != nil {
+				return
+			}
+			b.msgs = append(b.msgs, v)
+			if len(b.msgs) < o.batch {
+				continue
+			}
+			if !send(b) {
+				return
+			}
+			b = &filterBatch[T]{i: b.i + 1, msgs: make([]T, 0, o.batch)}
+		}
+		if len(b.msgs) != 0 {
+			send(b)
+		}
+	}()
+	for range o.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				var b *filterBatch[T]
+				select {
+				case v, ok := <-jobs:
+					if !ok {
+						return
+					}
+					b = v
+				case <-ctx.Done():
+					return
+				}
+				matched := b.msgs[:0]
+				for _, v := range b.msgs {
+					ok, err := o.matcher.MatchContext(ctx, v, expr)
+					if err != nil {
+						matched, b.err = append(matched, v), err
+						break
+					}
+					if ok {
+						matched = append(matched, v)
+					}
+				}
+				b.msgs = matched
+				select {
+				case results <- b:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	var zero T
+	n := 0
+	// emit yields the batch matching messages and reports whether to continue
+	emit := func(b *filterBatch[T]) bool {
+		for i, v := range b.msgs {
+			if b.err != nil && i == len(b.msgs)-1 {
+				yield(v, b.err)
+				return false
+			}
+			if !yield(v, nil) {
+				return false
+			}
+			if n++; n == o.limit {
+				return false
+			}
+		}
+		return true
+	}
+	next := 0
+	buf := make(map[int]*filterBatch[T])
+	for {
+		if err := parent.Err(); err != nil {
+			yield(zero, err)
+			return
+		}
+		select {
+		case b := <-results:
+			if o.unordered {
+				<-pending
+				if !emit(b) {
+					return
+				}
+				continue
+			}
+			buf[b.i] = b
+			for {
+				b, ok := buf[next]
+				if !ok {
+					break
+				}
+				delete(buf, next)
+				next++
+				<-pending
+				if !emit(b) {
+					return
+				}
+			}
+		case <-done:
+			// the workers may have sent their last results
+			if len(results) != 0 {
+				continue
+			}
+			if err := parent.Err(); err != nil {
+				yield(zero, err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestFilter(t *testing.T) {
+	msgs := benchmarkBuildMatchMessages(10_000, 10)
+	f := filters.Where("string_field").StringEquals("match").AndWhere("number_field").NumberSup(50)
+	var want []int64
+	for _, v := range msgs {
+		if v.StringField == "match" && v.NumberField > 50 {
+			want = append(want, v.NumberField)
+		}
+	}
+	collect := func(t *testing.T, opts ...FilterOption) []int64 {
+		var out []int64
+		for v, err := range Filter(context.Background(), slices.Values(msgs), f, opts...) {
+			require.NoError(t, err)
+			out = append(out, v.NumberField)
+		}
+		return out
+	}
+	for _, workers := range []int{1, 4} {
+		assert.Equal(t, want, collect(t, WithWorkers(workers)))
+		got := collect(t, WithWorkers(workers), WithUnordered())
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		sorted := slices.Clone(want)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		assert.Equal(t, sorted, got)
+		assert.Equal(t, want[:5], collect(t, WithWorkers(workers), WithLimit(5)))
+		assert.Len(t, collect(t, WithWorkers(workers), WithLimit(5), WithUnordered()), 5)
+
+		// breaking the loop stops the workers
+		n := 0
+		for range Filter(context.Background(), slices.Values(msgs), f, WithWorkers(workers)) {
+			if n++; n == 3 {
+				break
+			}
+		}
+		assert.Equal(t, 3, n)
+
+		var errs int
+		for v, err := range Filter(context.Background(), slices.Values(msgs), filters.Where("unknown").StringEquals("a"), WithWorkers(workers)) {
+			assert.NotNil(t, v)
+			assert.Error(t, err)
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		errs = 0
+		for v, err := range Filter(ctx, slices.Values(msgs), f, WithWorkers(workers)) {
+			assert.Nil(t, v)
+			assert.ErrorIs(t, err, context.Canceled)
+			errs++
+		}
+		assert.Equal(t, 1, errs)
+	}
+
+	// the sequence blocking on a channel returns on the context
+	// cancellation, and is waited for
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan *test.Test)
+	go func() {
+		for _, v := range msgs[:100] {
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var ranging atomic.Bool
+	seq := func(yield func(*test.Test) bool) {
+		ranging.Store(true)
+		defer ranging.Store(false)
+		for {
+			select {
+			case v := <-ch:
+				if !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	n := 0
+	for _, err := range Filter(ctx, seq, f, WithWorkers(4), WithBatchSize(1), WithLimit(1)) {
+		require.NoError(t, err)
+		n++
+		cancel()
+	}
+	assert.Equal(t, 1, n)
+	assert.False(t, ranging.Load())
+
+	// the sequence is not ranged anymore once the filtering stopped
+	ranging.Store(false)
+	n = 0
+	for range Filter(context.Background(), func(yield func(*test.Test) bool) {
+		ranging.Store(true)
+		defer ranging.Store(false)
+		for _, v := range msgs {
+			if !yield(v) {
+				return
+			}
+		}
+	}, f, WithWorkers(4), WithLimit(2)) {
+		n++
+	}
+	assert.Equal(t, 2, n)
+	assert.False(t, ranging.Load())
+}
@@ -0,0 +1,650 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AIP-160 tokens, in addition to the ones shared with the default parser
+const (
+	tokenComparator tokenType = iota + tokenComma + 1
+	tokenText
+)
+
+// ParseAIP160 builds an Expression from a filter written in the syntax
+// defined by https://google.aip.dev/160.
+// The field paths are resolved against md to type the literals.
+// Functions, field comparisons and global restrictions are not supported.
+// An empty string returns (nil, nil).
+func ParseAIP160(md protoreflect.MessageDescriptor, input string) (*Expression, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeAIP160(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &aipParser{parser: parser{tokens: tokens}, md: md}
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectEOF(); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+func tokenizeAIP160(input string) ([]token, error) {
+	var tokens []token
+	for idx := 0; idx < len(input); {
+		r, w := utf8.DecodeRuneInString(input[idx:])
+		switch {
+		case unicode.IsSpace(r):
+			idx += w
+		case r == '"' || r == '\'':
+			start := idx
+			idx += w
+			closed := false
+			for idx < len(input) {
+				c, w := utf8.DecodeRuneInString(input[idx:])
+				idx += w
+				if c == '\\' && idx < len(input) {
+					_, w = utf8.DecodeRuneInString(input[idx:])
+					idx += w
+					continue
+				}
+				if c == r {
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return nil, &ParseError{Pos: start, Msg: "unterminated string literal"}
+			}
+			s, err := unquoteAIP160(input[start:idx])
+			if err != nil {
+				return nil, &ParseError{Pos: start, Msg: fmt.Sprintf("invalid string literal: %v", err), Err: err}
+			}
+			tokens = append(tokens, token{typ: tokenString, value: s, pos: start})
+		case r == '(':
+			tokens = append(tokens, token{typ: tokenLParen, value: "(", pos: idx})
+			idx += w
+		case r == ')':
+			tokens = append(tokens, token{typ: tokenRParen, value: ")", pos: idx})
+			idx += w
+		case r == ',':
+			tokens = append(tokens, token{typ: tokenComma, value: ",", pos: idx})
+			idx += w
+		case strings.ContainsRune("<>=!:", r):
+			op := string(r)
+			if next := idx + 1; next < len(input) && input[next] == '=' && r != '=' && r != ':' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, &ParseError{Pos: idx, Msg: "unexpected '!'"}
+			}
+			tokens = append(tokens, token{typ: tokenComparator, value: op, pos: idx})
+			idx += len(op)
+		default:
+			start := idx
+			for idx < len(input) {
+				r, w = utf8.DecodeRuneInString(input[idx:])
+				if unicode.IsSpace(r) || strings.ContainsRune("()<>=!:,\"'", r) {
+					break
+				}
+				idx += w
+			}
+			tokens = append(tokens, token{typ: tokenText, value: input[start:idx], pos: start})
+		}
+	}
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func unquoteAIP160(s string) (string, error) {
+	if s[0] == '"' {
+		return strconv.Unquote(s)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+type aipParser struct {
+	parser
+	md protoreflect.MessageDescriptor
+}
+
+// expression : sequence {AND sequence}
+func (p *aipParser) parseExpression() (*Expression, error) {
+	var exprs []FieldFilterer
+	for {
+		e, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.peekKeyword("AND") {
+			return And(exprs...), nil
+		}
+		p.next()
+	}
+}
+
+// sequence : factor {factor}
+func (p *aipParser) parseSequence() (*Expression, error) {
+	var exprs []FieldFilterer
+	for {
+		e, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if tok := p.peek(); tok.typ == tokenEOF || tok.typ == tokenRParen || p.peekKeyword("AND") {
+			return And(exprs...), nil
+		}
+	}
+}
+
+// factor : term {OR term}
+func (p *aipParser) parseFactor() (*Expression, error) {
+	var exprs []FieldFilterer
+	for {
+		e, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if !p.peekKeyword("OR") {
+			return Or(exprs...), nil
+		}
+		p.next()
+	}
+}
+
+// term : [NOT | '-'] simple
+func (p *aipParser) parseTerm() (*Expression, error) {
+	tok := p.peek()
+	switch {
+	case p.peekKeyword("NOT") || tok.typ == tokenText && tok.value == "-":
+		p.next()
+		e, err := p.parseSimple()
+		if err != nil {
+			return nil, err
+		}
+		return Not(e), nil
+	case tok.typ == tokenText && strings.HasPrefix(tok.value, "-"):
+		// the minus operator is bound to the member: -file:"java"
+		p.tokens[p.idx].value = tok.value[1:]
+		p.tokens[p.idx].pos++
+		e, err := p.parseSimple()
+		if err != nil {
+			return nil, err
+		}
+		return Not(e), nil
+	}
+	return p.parseSimple()
+}
+
+// simple : restriction | '(' expression ')'
+func (p *aipParser) parseSimple() (*Expression, error) {
+	tok := p.peek()
+	if tok.typ == tokenLParen {
+		p.next()
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expectToken(tokenRParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return p.parseRestriction()
+}
+
+// restriction : member comparator arg
+func (p *aipParser) parseRestriction() (*Expression, error) {
+	tok := p.next()
+	if tok.typ != tokenText || isAIP160Keyword(tok.value) {
+		return nil, p.expected(tok, "field name")
+	}
+	if isCall(tok, p.peek()) {
+		return nil, p.error(tok, "functions are not supported")
+	}
+	fds, err := p.lookup(tok)
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op.typ != tokenComparator {
+		return nil, p.error(tok, "global restrictions are not supported: expected comparator after %q", tok.value)
+	}
+	arg := p.next()
+	switch arg.typ {
+	case tokenString, tokenText:
+	case tokenLParen:
+		return nil, p.error(arg, "composite arguments are not supported")
+	default:
+		return nil, p.expected(arg, "value")
+	}
+	if arg.typ == tokenText && isCall(arg, p.peek()) {
+		return nil, p.error(arg, "functions are not supported")
+	}
+	f, err := p.filter(fds, op, arg)
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{Condition: &FieldFilter{Field: tok.value, Filter: f}}, nil
+}
+
+func (p *aipParser) lookup(tok token) ([]protoreflect.FieldDescriptor, error) {
+	var fds []protoreflect.FieldDescriptor
+	md := p.md
+	for i, v := range strings.Split(tok.value, ".") {
+		if md == nil {
+			return nil, p.error(tok, "%s is not a message", strings.Join(strings.Split(tok.value, ".")[:i], "."))
+		}
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			err := &UnknownFieldError{Message: string(md.FullName()), Path: v}
+			return nil, &ParseError{Pos: tok.pos, Msg: err.Error(), Err: err}
+		}
+		if fd.IsMap() {
+			return nil, p.error(tok, "map fields are not supported")
+		}
+		fds = append(fds, fd)
+		md = fd.Message()
+	}
+	return fds, nil
+}
+
+func (p *aipParser) filter(fds []protoreflect.FieldDescriptor, op, arg token) (*Filter, error) {
+	fd := fds[len(fds)-1]
+	kind := aipKindOf(fd)
+	comparator := op.value
+	if comparator == ":" {
+		if arg.typ == tokenText && arg.value == "*" {
+			if fd.IsList() || kind != aipMessage && !fd.HasOptionalKeyword() {
+				return nil, p.error(op, "presence is only supported on singular message and optional fields")
+			}
+			return makeNullFilter(true), nil
+		}
+		repeated := false
+		for _, v := range fds {
+			repeated = repeated || v.IsList()
+		}
+		if !repeated {
+			return nil, p.error(op, "':' is only supported on repeated fields or with '*'")
+		}
+		comparator = "="
+	}
+	if arg.typ == tokenText && arg.value == "null" {
+		if comparator != "=" && comparator != "!=" {
+			return nil, p.error(op, "null can only be compared with '=' or '!='")
+		}
+		if kind != aipMessage && !fd.HasOptionalKeyword() {
+			return nil, p.error(arg, "%s cannot be null", fd.Name())
+		}
+		return makeNullFilter(comparator == "!="), nil
+	}
+	negated := comparator == "!=" || comparator == "<=" || comparator == ">="
+	switch kind {
+	case aipString:
+		value := arg.value
+		switch comparator {
+		case "=", "!=":
+			return makeStringFilter(false, negated, wildcardCondition(value)), nil
+		case "<", ">=":
+			return makeStringFilter(false, negated, &StringFilter_Inf{Inf: value}), nil
+		default:
+			return makeStringFilter(false, negated, &StringFilter_Sup{Sup: value}), nil
+		}
+	case aipEnum:
+		if n, err := strconv.ParseFloat(arg.value, 64); err == nil && arg.typ == tokenText {
+			return numberFilter(comparator, negated, n), nil
+		}
+		v := fd.Enum().Values().ByName(protoreflect.Name(arg.value))
+		if v == nil {
+			return nil, p.error(arg, "%s has no value %q", fd.Enum().FullName(), arg.value)
+		}
+		if comparator == "=" || comparator == "!=" {
+			return makeStringFilter(false, negated, &StringFilter_Equals{Equals: arg.value}), nil
+		}
+		return numberFilter(comparator, negated, float64(v.Number())), nil
+	case aipNumber:
+		n, err := strconv.ParseFloat(arg.value, 64)
+		if err != nil || arg.typ != tokenText {
+			return nil, p.error(arg, "invalid number %q", arg.value)
+		}
+		return numberFilter(comparator, negated, n), nil
+	case aipBool:
+		if comparator != "=" && comparator != "!=" {
+			return nil, p.error(op, "booleans can only be compared with '=' or '!='")
+		}
+		b, err := strconv.ParseBool(arg.value)
+		if err != nil || arg.typ != tokenText {
+			return nil, p.error(arg, "invalid boolean %q", arg.value)
+		}
+		return makeBoolFilter(negated, b), nil
+	case aipTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, arg.value)
+		if err != nil {
+			return nil, p.error(arg, "invalid RFC3339 timestamp %q", arg.value)
+		}
+		ts := timestamppb.New(t)
+		switch comparator {
+		case "=", "!=":
+			return makeTimeFilter(negated, &TimeFilter_Equals{Equals: ts}), nil
+		case "<", ">=":
+			return makeTimeFilter(negated, &TimeFilter_Before{Before: ts}), nil
+		default:
+			return makeTimeFilter(negated, &TimeFilter_After{After: ts}), nil
+		}
+	case aipDuration:
+		d, err := time.ParseDuration(arg.value)
+		if err != nil {
+			return nil, p.error(arg, "invalid duration %q", arg.value)
+		}
+		pd := durationpb.New(d)
+		switch comparator {
+		case "=", "!=":
+			return makeDurationFilter(negated, &DurationFilter_Equals{Equals: pd}), nil
+		case "<", ">=":
+			return makeDurationFilter(negated, &DurationFilter_Inf{Inf: pd}), nil
+		default:
+			return makeDurationFilter(negated, &DurationFilter_Sup{Sup: pd}), nil
+		}
+	}
+	return nil, p.error(op, "%s fields are not supported", fd.Kind())
+}
+
+func numberFilter(comparator string, negated bool, n float64) *Filter {
+	switch comparator {
+	case "=", "!=":
+		return makeNumberFilter(negated, &NumberFilter_Equals{Equals: n})
+	case "<", ">=":
+		return makeNumberFilter(negated, &NumberFilter_Inf{Inf: n})
+	default:
+		return makeNumberFilter(negated, &NumberFilter_Sup{Sup: n})
+	}
+}
+
+// wildcardCondition returns the string condition of an equality, where
+// leading and trailing '*' are wildcards.
+func wildcardCondition(s string) isStringFilter_Condition {
+	switch {
+	case len(s) >= 2 && strings.HasPrefix(s, "*") && strings.HasSuffix(s, "*"):
+		return &StringFilter_Contains{Contains: s[1 : len(s)-1]}
+	case strings.HasSuffix(s, "*"):
+		return &StringFilter_HasPrefix{HasPrefix: s[:len(s)-1]}
+	case strings.HasPrefix(s, "*"):
+		return &StringFilter_HasSuffix{HasSuffix: s[1:]}
+	}
+	return &StringFilter_Equals{Equals: s}
+}
+
+type aipKind int
+
+const (
+	aipUnsupported aipKind = iota
+	aipString
+	aipEnum
+	aipNumber
+	aipBool
+	aipTimestamp
+	aipDuration
+	aipMessage
+)
+
+func aipKindOf(fd protoreflect.FieldDescriptor) aipKind {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return aipString
+	case protoreflect.EnumKind:
+		return aipEnum
+	case protoreflect.BoolKind:
+		return aipBool
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Int64Kind, protoreflect.Sint64Kind,
+		protoreflect.Sfixed32Kind, protoreflect.Fixed32Kind, protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.FloatKind, protoreflect.DoubleKind:
+		return aipNumber
+	case protoreflect.MessageKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.StringValue":
+			return aipString
+		case "google.protobuf.BoolValue":
+			return aipBool
+		case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+			"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+			"google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+			return aipNumber
+		case "google.protobuf.Timestamp":
+			return aipTimestamp
+		case "google.protobuf.Duration":
+			return aipDuration
+		}
+		return aipMessage
+	}
+	return aipUnsupported
+}
+
+// isCall reports whether the text token is a function name, i.e. is directly
+// followed by a parenthesis
+func isCall(tok, next token) bool {
+	return next.typ == tokenLParen && next.pos == tok.pos+len(tok.value)
+}
+
+func isAIP160Keyword(s string) bool {
+	return s == "AND" || s == "OR" || s == "NOT"
+}
+
+func (p *aipParser) peekKeyword(word string) bool {
+	tok := p.peek()
+	return tok.typ == tokenText && tok.value == word
+}
+
+// FormatAIP160 formats the expression using the syntax defined by
+// https://google.aip.dev/160.
+// It returns an error for the filters without AIP-160 equivalent, i.e.
+// case-insensitive and regex string filters, and equality with values
+// starting or ending with a '*'.
+func FormatAIP160(f FieldFilterer) (string, error) {
+	if f == nil || f.Expr() == nil {
+		return "", nil
+	}
+	s, _, err := formatAIP160(f.Expr())
+	return s, err
+}
+
+// formatAIP160 returns the formatted expression and whether it must be
+// wrapped in parenthesis to be used as an operand
+func formatAIP160(expr *Expression) (string, bool, error) {
+	var conj []string
+	if expr.Condition != nil {
+		s, compound, err := formatAIP160FieldFilter(expr.Condition)
+		if err != nil {
+			return "", false, err
+		}
+		conj = append(conj, wrap(s, compound))
+	}
+	for _, v := range expr.AndExprs {
+		s, compound, err := formatAIP160(v)
+		if err != nil {
+			return "", false, err
+		}
+		conj = append(conj, wrap(s, compound))
+	}
+	if len(expr.OrExprs) == 0 {
+		return strings.Join(conj, " AND "), len(conj) > 1, nil
+	}
+	// OR binds tighter than AND
+	disj := []string{wrap(strings.Join(conj, " AND "), len(conj) > 1)}
+	for _, v := range expr.OrExprs {
+		s, compound, err := formatAIP160(v)
+		if err != nil {
+			return "", false, err
+		}
+		disj = append(disj, wrap(s, compound))
+	}
+	return strings.Join(disj, " OR "), true, nil
+}
+
+func wrap(s string, compound bool) string {
+	if compound {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func formatAIP160FieldFilter(ff *FieldFilter) (string, bool, error) {
+	f := ff.GetFilter()
+	name := ff.GetField()
+	// op returns the comparator of a condition, using the negated one if any
+	op := func(op, negated string) string {
+		if f.GetNot() {
+			return negated
+		}
+		return op
+	}
+	switch m := f.GetMatch().(type) {
+	case *Filter_String_:
+		if m.String_.GetCaseInsensitive() {
+			return "", false, fmt.Errorf("filters: %s: case insensitive string filters are not supported by AIP-160", name)
+		}
+		switch c := m.String_.GetCondition().(type) {
+		case *StringFilter_Equals:
+			if strings.HasPrefix(c.Equals, "*") || strings.HasSuffix(c.Equals, "*") {
+				return "", false, fmt.Errorf("filters: %s: equality with wildcard value %q is not supported by AIP-160", name, c.Equals)
+			}
+			return name + " " + op("=", "!=") + " " + strconv.Quote(c.Equals), false, nil
+		case *StringFilter_HasPrefix:
+			return name + " " + op("=", "!=") + " " + strconv.Quote(c.HasPrefix+"*"), false, nil
+		case *StringFilter_HasSuffix:
+			return name + " " + op("=", "!=") + " " + strconv.Quote("*"+c.HasSuffix), false, nil
+		case *StringFilter_Contains:
+			return name + " " + op("=", "!=") + " " + strconv.Quote("*"+c.Contains+"*"), false, nil
+		case *StringFilter_Inf:
+			return name + " " + op("<", ">=") + " " + strconv.Quote(c.Inf), false, nil
+		case *StringFilter_Sup:
+			return name + " " + op(">", "<=") + " " + strconv.Quote(c.Sup), false, nil
+		case *StringFilter_In_:
+			var parts []string
+			for _, v := range c.In.GetValues() {
+				parts = append(parts, name+" = "+strconv.Quote(v))
+			}
+			return formatAIP160In(name, parts, f.GetNot())
+		case *StringFilter_Regex:
+			return "", false, fmt.Errorf("filters: %s: regex filters are not supported by AIP-160", name)
+		}
+	case *Filter_Number:
+		n := func(v float64) string {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		switch c := m.Number.GetCondition().(type) {
+		case *NumberFilter_Equals:
+			return name + " " + op("=", "!=") + " " + n(c.Equals), false, nil
+		case *NumberFilter_Inf:
+			return name + " " + op("<", ">=") + " " + n(c.Inf), false, nil
+		case *NumberFilter_Sup:
+			return name + " " + op(">", "<=") + " " + n(c.Sup), false, nil
+		case *NumberFilter_In_:
+			var parts []string
+			for _, v := range c.In.GetValues() {
+				parts = append(parts, name+" = "+n(v))
+			}
+			return formatAIP160In(name, parts, f.GetNot())
+		}
+	case *Filter_Bool:
+		return name + " " + op("=", "!=") + " " + strconv.FormatBool(m.Bool.GetEquals()), false, nil
+	case *Filter_Null:
+		return name + " " + op("=", "!=") + " null", false, nil
+	case *Filter_Time:
+		t := func(v *timestamppb.Timestamp) string {
+			return strconv.Quote(v.AsTime().Format(time.RFC3339Nano))
+		}
+		switch c := m.Time.GetCondition().(type) {
+		case *TimeFilter_Equals:
+			return name + " " + op("=", "!=") + " " + t(c.Equals), false, nil
+		case *TimeFilter_Before:
+			return name + " " + op("<", ">=") + " " + t(c.Before), false, nil
+		case *TimeFilter_After:
+			return name + " " + op(">", "<=") + " " + t(c.After), false, nil
+		}
+	case *Filter_Duration:
+		switch c := m.Duration.GetCondition().(type) {
+		case *DurationFilter_Equals:
+			return name + " " + op("=", "!=") + " " + formatAIP160Duration(c.Equals), false, nil
+		case *DurationFilter_Inf:
+			return name + " " + op("<", ">=") + " " + formatAIP160Duration(c.Inf), false, nil
+		case *DurationFilter_Sup:
+			return name + " " + op(">", "<=") + " " + formatAIP160Duration(c.Sup), false, nil
+		}
+	}
+	return "", false, fmt.Errorf("filters: %s: unsupported filter %q", name, f.Format())
+}
+
+func formatAIP160In(name string, parts []string, negated bool) (string, bool, error) {
+	if len(parts) == 0 {
+		return "", false, fmt.Errorf("filters: %s: empty in filter", name)
+	}
+	s := strings.Join(parts, " OR ")
+	if negated {
+		return "NOT " + wrap(s, len(parts) > 1), false, nil
+	}
+	return s, len(parts) > 1, nil
+}
+
+// formatAIP160Duration formats the duration as seconds, e.g. 1.5s
+func formatAIP160Duration(d *durationpb.Duration) string {
+	s, n := d.GetSeconds(), d.GetNanos()
+	sign := ""
+	if s < 0 || n < 0 {
+		sign, s, n = "-", -s, -n
+	}
+	if n == 0 {
+		return sign + strconv.FormatInt(s, 10) + "s"
+	}
+	return sign + strconv.FormatInt(s, 10) + "." + strings.TrimRight(fmt.Sprintf("%09d", n), "0") + "s"
+}
@@ -0,0 +1,201 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+var md = (&test.Test{}).ProtoReflect().Descriptor()
+
+func TestParseAIP160(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `string_field = "x"`, want: "string_field eq 'x'"},
+		{input: `string_field = x`, want: "string_field eq 'x'"},
+		{input: `string_field != 'it\'s'`, want: `string_field not eq 'it's'`},
+		{input: `string_field = "ab*"`, want: "string_field has_prefix 'ab'"},
+		{input: `string_field = "*ab"`, want: "string_field has_suffix 'ab'"},
+		{input: `string_field = "*ab*"`, want: "string_field contains 'ab'"},
+		{input: `string_field < "b"`, want: "string_field inf 'b'"},
+		{input: `string_field >= "b"`, want: "string_field not inf 'b'"},
+		{input: `number_field > 3`, want: "number_field sup 3"},
+		{input: `number_field <= -3.5`, want: "number_field not sup -3.5"},
+		{input: `number_value_field = 2`, want: "number_value_field eq 2"},
+		{input: `bool_field = true`, want: "bool_field is true"},
+		{input: `bool_value_field != false`, want: "bool_value_field not is false"},
+		{input: `enum_field = ONE`, want: "enum_field eq 'ONE'"},
+		{input: `enum_field > ONE`, want: "enum_field sup 1"},
+		{input: `enum_field = 2`, want: "enum_field eq 2"},
+		{input: `time_value_field > "1970-01-01T00:00:00Z"`, want: "time_value_field after 1970-01-01T00:00:00Z"},
+		{input: `duration_value_field < 1.5s`, want: "duration_value_field inf 1.5s"},
+		{input: `message_field:*`, want: "message_field not is null"},
+		{input: `optional_string_field = null`, want: "optional_string_field is null"},
+		{input: `repeated_string_field:"a"`, want: "repeated_string_field eq 'a'"},
+		{input: `repeated_message_field.number_field:1`, want: "repeated_message_field.number_field eq 1"},
+		{input: `message_field.string_field = "a"`, want: "message_field.string_field eq 'a'"},
+		{input: `string_field = "a" AND number_field = 1`, want: "string_field eq 'a' and number_field eq 1"},
+		{input: `string_field = "a" number_field = 1`, want: "string_field eq 'a' and number_field eq 1"},
+		{input: `string_field = "a" OR number_field = 1`, want: "string_field eq 'a' or number_field eq 1"},
+		// OR binds tighter than AND
+		{input: `string_field = "a" AND number_field = 1 OR bool_field = true`, want: "string_field eq 'a' and (number_field eq 1 or bool_field is true)"},
+		{input: `(string_field = "a" AND number_field = 1) OR bool_field = true`, want: "string_field eq 'a' and number_field eq 1 or bool_field is true"},
+		{input: `NOT string_field = "a"`, want: "string_field not eq 'a'"},
+		{input: `-string_field = "a"`, want: "string_field not eq 'a'"},
+		{input: `NOT (string_field = "a" AND number_field = 1)`, want: "string_field not eq 'a' or number_field not eq 1"},
+		{input: `NOT (string_field = "a" OR number_field = 1)`, want: "string_field not eq 'a' and number_field not eq 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := filters.ParseAIP160(md, tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.Format())
+		})
+	}
+	expr, err := filters.ParseAIP160(md, " ")
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+func TestParseAIP160Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: `unknown = 1`, err: "does not contain"},
+		{input: `string_field.value = 1`, err: "is not a message"},
+		{input: `string_field`, err: "global restrictions"},
+		{input: `"text"`, err: "expected field name"},
+		{input: `string_field = "a`, err: "unterminated"},
+		{input: `number_field = "a"`, err: "invalid number"},
+		{input: `bool_field > true`, err: "booleans"},
+		{input: `enum_field = THREE`, err: "has no value"},
+		{input: `time_value_field = "yesterday"`, err: "timestamp"},
+		{input: `string_field:"a"`, err: "repeated"},
+		{input: `string_field:*`, err: "presence"},
+		{input: `string_field = null`, err: "cannot be null"},
+		{input: `size(repeated_string_field) > 1`, err: "functions"},
+		{input: `string_field = lower(x)`, err: "functions"},
+		{input: `string_field = (a OR b)`, err: "composite"},
+		{input: `(string_field = "a"`, err: "expected ')'"},
+		{input: `string_field = "a" AND`, err: "expected field name"},
+		{input: `string_field ! "a"`, err: "unexpected '!'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := filters.ParseAIP160(md, tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestFormatAIP160(t *testing.T) {
+	tests := []struct {
+		f    filters.FieldFilterer
+		want string
+	}{
+		{f: filters.Where("string_field").StringEquals(`a "b"`), want: `string_field = "a \"b\""`},
+		{f: filters.Where("string_field").StringNotHasPrefix("a"), want: `string_field != "a*"`},
+		{f: filters.Where("string_field").StringHasSuffix("a"), want: `string_field = "*a"`},
+		{f: filters.Where("string_field").StringContains("a"), want: `string_field = "*a*"`},
+		{f: filters.Where("string_field").StringSup("a"), want: `string_field > "a"`},
+		{f: filters.Where("string_field").StringIN("a", "b"), want: `(string_field = "a" OR string_field = "b")`},
+		{f: filters.Where("number_field").NumberNotIN(1, 2), want: `NOT (number_field = 1 OR number_field = 2)`},
+		{f: filters.Where("number_field").NumberInf(1.5), want: `number_field < 1.5`},
+		{f: filters.Where("bool_field").False(), want: `bool_field = false`},
+		{f: filters.Where("message_field").NotNull(), want: `message_field != null`},
+		{f: filters.Where("time_value_field").TimeBefore(time.Unix(1, 5).UTC()), want: `time_value_field < "1970-01-01T00:00:01.000000005Z"`},
+		{f: filters.Where("duration_value_field").DurationSup(-1500 * time.Millisecond), want: `duration_value_field > -1.5s`},
+		{
+			f:    filters.Where("string_field").StringEquals("a").AndWhere("number_field").NumberEquals(1).OrWhere("bool_field").True(),
+			want: `(string_field = "a" AND number_field = 1) OR bool_field = true`,
+		},
+		{
+			f:    filters.Where("string_field").StringEquals("a").And(filters.Where("number_field").NumberEquals(1).OrWhere("bool_field").True()),
+			want: `string_field = "a" AND (number_field = 1 OR bool_field = true)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := filters.FormatAIP160(tt.f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			expr, err := filters.ParseAIP160(md, got)
+			require.NoError(t, err)
+			again, err := filters.FormatAIP160(expr)
+			require.NoError(t, err)
+			reparsed, err := filters.ParseAIP160(md, again)
+			require.NoError(t, err)
+			assert.Equal(t, expr.Format(), reparsed.Format())
+		})
+	}
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("string_field").StringIEquals("a"),
+		filters.Where("string_field").StringRegex("a"),
+		filters.Where("string_field").StringEquals("a*"),
+	} {
+		_, err := filters.FormatAIP160(f)
+		assert.Error(t, err, f.Expr().Format())
+	}
+}
+
+func TestAIP160Match(t *testing.T) {
+	msgs := []*test.Test{
+		{StringField: "abc", NumberField: 1},
+		{StringField: "abc", NumberField: 2, BoolField: true},
+		{StringField: "xyz", NumberField: 1, OptionalStringField: proto.String("")},
+		{StringField: "xyz", NumberField: 3, RepeatedStringField: []string{"a", "b"}},
+	}
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{input: `string_field = "ab*" AND number_field = 1 OR bool_field = true`, want: []int{0, 1}},
+		{input: `NOT (string_field = "abc" OR number_field > 2)`, want: []int{2}},
+		{input: `-string_field = "*z" number_field >= 2`, want: []int{1}},
+		{input: `(string_field = abc OR number_field = 3) AND (number_field = 2 OR repeated_string_field:a)`, want: []int{1, 3}},
+		{input: `optional_string_field:*`, want: []int{2}},
+		{input: `repeated_string_field:b`, want: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := filters.ParseAIP160(md, tt.input)
+			require.NoError(t, err)
+			var got []int
+			for i, m := range msgs {
+				ok, err := protofilters.Match(m, expr)
+				require.NoError(t, err)
+				if ok {
+					got = append(got, i)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -38,6 +38,10 @@ type Builder interface {
 	StringNotHasSuffix(s string) Builder
 	StringIHasSuffix(s string) Builder
 	StringNotIHasSuffix(s string) Builder
+	StringContains(s string) Builder
+	StringNotContains(s string) Builder
+	StringIContains(s string) Builder
+	StringNotIContains(s string) Builder
 	StringRegex(s string) Builder
 	StringNotRegex(s string) Builder
 	StringIN(s ...string) Builder
@@ -201,6 +205,30 @@ func (b *builder) StringNotIHasSuffix(s string) Builder {
 	return b
 }
 
+// StringContains constructs a string contains substring filter
+func (b *builder) StringContains(s string) Builder {
+	b.c.Condition.Filter = StringContains(s)
+	return b
+}
+
+// StringNotContains constructs a string not contains substring filter
+func (b *builder) StringNotContains(s string) Builder {
+	b.c.Condition.Filter = StringNotContains(s)
+	return b
+}
+
+// StringIContains constructs a case insensitive string contains substring filter
+func (b *builder) StringIContains(s string) Builder {
+	b.c.Condition.Filter = StringIContains(s)
+	return b
+}
+
+// StringNotIContains constructs a case insensitive string not contains substring filter
+func (b *builder) StringNotIContains(s string) Builder {
+	b.c.Condition.Filter = StringNotIContains(s)
+	return b
+}
+
 // StringRegex constructs a string match regex filter
 func (b *builder) StringRegex(s string) Builder {
 	b.c.Condition.Filter = StringRegex(s)
@@ -0,0 +1,118 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+// And returns the conjunction of the expressions.
+// Nil expressions are ignored, and nil is returned if none is left.
+// The expressions are copied.
+func And(fs ...FieldFilterer) *Expression {
+	exprs := exprs(fs)
+	if len(exprs) == 0 {
+		return nil
+	}
+	// any expression without or expressions can hold the others
+	// as and expressions
+	for i, v := range exprs {
+		if len(v.OrExprs) != 0 {
+			continue
+		}
+		out := v.CloneVT()
+		for j, v := range exprs {
+			if j != i {
+				out.AndExprs = append(out.AndExprs, v.CloneVT())
+			}
+		}
+		return out
+	}
+	// ((c and a) or o) and r == (c and a and r) or (o and r)
+	first, rest := exprs[0], exprs[1:]
+	out := &Expression{Condition: first.Condition.CloneVT()}
+	for _, v := range first.AndExprs {
+		out.AndExprs = append(out.AndExprs, v.CloneVT())
+	}
+	for _, v := range rest {
+		out.AndExprs = append(out.AndExprs, v.CloneVT())
+	}
+	for _, o := range first.OrExprs {
+		fs := []FieldFilterer{o}
+		for _, v := range rest {
+			fs = append(fs, v)
+		}
+		out.OrExprs = append(out.OrExprs, And(fs...))
+	}
+	return out
+}
+
+// Or returns the disjunction of the expressions.
+// Nil expressions are ignored, and nil is returned if none is left.
+// The expressions are copied.
+func Or(fs ...FieldFilterer) *Expression {
+	exprs := exprs(fs)
+	if len(exprs) == 0 {
+		return nil
+	}
+	out := exprs[0].CloneVT()
+	for _, v := range exprs[1:] {
+		out.OrExprs = append(out.OrExprs, v.CloneVT())
+	}
+	return out
+}
+
+// Not returns the negation of the expression, pushing the negation down to
+// its field filters.
+// Note that the negated filters on repeated scalar fields never match, so
+// that the result is not the complement of the expression for such fields.
+// Not returns nil, matching all the messages, if the expression is nil or
+// empty, e.g. Not(And()), as the constant false expression needs a field:
+// use an empty in filter on one of the message fields instead.
+func Not(f FieldFilterer) *Expression {
+	if f == nil || f.Expr() == nil {
+		return nil
+	}
+	expr := f.Expr()
+	// not ((c and a) or o) == (not c or not a) and not o
+	var conj []FieldFilterer
+	if expr.Condition != nil {
+		c := expr.Condition.CloneVT()
+		if c.Filter == nil {
+			c.Filter = &Filter{}
+		}
+		c.Filter.Not = !c.Filter.Not
+		conj = append(conj, &Expression{Condition: c})
+	}
+	for _, v := range expr.AndExprs {
+		conj = append(conj, Not(v))
+	}
+	out := []FieldFilterer{Or(conj...)}
+	for _, v := range expr.OrExprs {
+		out = append(out, Not(v))
+	}
+	return And(out...)
+}
+
+func exprs(fs []FieldFilterer) []*Expression {
+	var out []*Expression
+	for _, v := range fs {
+		if v == nil {
+			continue
+		}
+		if e := v.Expr(); e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestNot(t *testing.T) {
+	m := &test.Test{StringField: "a"}
+	// the negation of the match-all expression is not representable
+	assert.Nil(t, filters.Not(nil))
+	assert.Nil(t, filters.Not(filters.And()))
+	assert.Nil(t, filters.Not(filters.Or()))
+
+	// the constant false is an empty in filter on a field
+	f := filters.Not(filters.Where("string_field").StringNotIN())
+	assert.Equal(t, "string_field in ()", f.Format())
+	ok, err := protofilters.Match(m, f)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("string_field").StringEquals("a"),
+		filters.Where("string_field").StringEquals("b").OrWhere("bool_field").True(),
+		filters.And(filters.Where("string_field").StringEquals("a"), filters.Where("bool_field").False()),
+	} {
+		ok, err := protofilters.Match(m, f)
+		require.NoError(t, err)
+		nok, err := protofilters.Match(m, filters.Not(f))
+		require.NoError(t, err)
+		assert.Equal(t, !ok, nok, f.Expr().Format())
+	}
+}
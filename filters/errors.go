@@ -0,0 +1,167 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+var (
+	// ErrUnknownField is matched by the UnknownFieldError errors.
+	ErrUnknownField = errors.New("filters: unknown field")
+	// ErrTypeMismatch is matched by the TypeMismatchError errors.
+	ErrTypeMismatch = errors.New("filters: type mismatch")
+	// ErrInvalidRegex is matched by the RegexError errors.
+	ErrInvalidRegex = errors.New("filters: invalid regex")
+	// ErrParse is matched by the ParseError errors.
+	ErrParse = errors.New("filters: parse error")
+)
+
+// UnknownFieldError is returned when a field path does not resolve to a
+// field of the message.
+type UnknownFieldError struct {
+	// Message is the full name of the message
+	Message string
+	// Path is the unresolved field path, relative to the message
+	Path string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("%s does not contain '%s'", e.Message, e.Path)
+}
+
+// Is reports whether target is ErrUnknownField.
+func (e *UnknownFieldError) Is(target error) bool {
+	return target == ErrUnknownField
+}
+
+// TypeMismatchError is returned when a filter is used on a field of a kind
+// it does not support, e.g. a string filter on a number field.
+type TypeMismatchError struct {
+	// Path is the field path, if known
+	Path string
+	// Kind is the field kind, e.g. int64 or google.protobuf.Value
+	Kind string
+	// FilterKind is the filter kind, e.g. string or null
+	FilterKind string
+}
+
+func (e *TypeMismatchError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s: cannot use %s filter on %s", e.Path, e.FilterKind, e.Kind)
+	}
+	return fmt.Sprintf("cannot use %s filter on %s", e.FilterKind, e.Kind)
+}
+
+// Is reports whether target is ErrTypeMismatch.
+func (e *TypeMismatchError) Is(target error) bool {
+	return target == ErrTypeMismatch
+}
+
+// RegexError is returned when the regular expression of a string filter
+// does not compile.
+type RegexError struct {
+	// Path is the field path, if known
+	Path  string
+	Regex string
+	Err   error
+}
+
+func (e *RegexError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// Is reports whether target is ErrInvalidRegex.
+func (e *RegexError) Is(target error) bool {
+	return target == ErrInvalidRegex
+}
+
+func (e *RegexError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned by the parsers when the expression is invalid.
+type ParseError struct {
+	// Pos is the byte offset of the invalid token in the expression
+	Pos int
+	// Expected describes the expected token, if any, e.g. "field name"
+	Expected string
+	// Msg is the error description
+	Msg string
+	// Err is the underlying error, if any, e.g. an UnknownFieldError
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("filters: %s (at position %d)", e.Msg, e.Pos)
+}
+
+// Is reports whether target is ErrParse.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrParse
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// BadRequest converts the typed errors, possibly wrapped or joined, to the
+// google.rpc.BadRequest field violations of the request field holding the
+// expression, e.g. "filter". It returns nil if err contains none of them.
+func BadRequest(field string, err error) *errdetails.BadRequest {
+	var out errdetails.BadRequest
+	for _, v := range flattenErrors(err) {
+		if fv := fieldViolation(field, v); fv != nil {
+			out.FieldViolations = append(out.FieldViolations, fv)
+		}
+	}
+	if len(out.FieldViolations) == 0 {
+		return nil
+	}
+	return &out
+}
+
+func fieldViolation(field string, err error) *errdetails.BadRequest_FieldViolation {
+	for _, target := range []error{ErrParse, ErrUnknownField, ErrTypeMismatch, ErrInvalidRegex, ErrLimitExceeded} {
+		if errors.Is(err, target) {
+			return &errdetails.BadRequest_FieldViolation{Field: field, Description: err.Error()}
+		}
+	}
+	return nil
+}
+
+// flattenErrors returns the errors joined by errors.Join, or err
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	j, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var out []error
+	for _, v := range j.Unwrap() {
+		out = append(out, flattenErrors(v)...)
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		_, err := filters.ParseExpression("string_field eq 'a' and")
+		var e *filters.ParseError
+		require.ErrorAs(t, err, &e)
+		assert.ErrorIs(t, err, filters.ErrParse)
+		assert.Equal(t, 23, e.Pos)
+		assert.Equal(t, "field name", e.Expected)
+
+		_, err = filters.ParseExpression("string_field eq 'a")
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, 16, e.Pos)
+		assert.Empty(t, e.Expected)
+	})
+	t.Run("aip160 unknown field", func(t *testing.T) {
+		_, err := filters.ParseAIP160(md, `string_field = "a" AND unknown = 1`)
+		var e *filters.ParseError
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, 23, e.Pos)
+		assert.ErrorIs(t, err, filters.ErrUnknownField)
+		var u *filters.UnknownFieldError
+		require.ErrorAs(t, err, &u)
+		assert.Equal(t, "linka.cloud.test.Test", u.Message)
+		assert.Equal(t, "unknown", u.Path)
+	})
+	t.Run("match", func(t *testing.T) {
+		m := protofilters.NewMatcher()
+		_, err := m.Match(&test.Test{}, filters.Where("message_field.unknown").StringEquals("a"))
+		var u *filters.UnknownFieldError
+		require.ErrorAs(t, err, &u)
+		assert.Equal(t, "message_field.unknown", u.Path)
+
+		_, err = m.Match(&test.Test{}, filters.Where("number_field").StringEquals("a"))
+		var tm *filters.TypeMismatchError
+		require.ErrorAs(t, err, &tm)
+		assert.Equal(t, filters.TypeMismatchError{Path: "number_field", Kind: "int64", FilterKind: "string"}, *tm)
+
+		_, err = m.Match(&test.Test{StringField: "a"}, filters.Where("string_field").StringRegex("("))
+		assert.ErrorIs(t, err, filters.ErrInvalidRegex)
+		var re *filters.RegexError
+		require.ErrorAs(t, err, &re)
+		assert.Equal(t, "string_field", re.Path)
+	})
+	t.Run("bad request", func(t *testing.T) {
+		assert.Nil(t, filters.BadRequest("filter", nil))
+		assert.Nil(t, filters.BadRequest("filter", errors.New("internal")))
+		_, err1 := filters.ParseExpression("string_field")
+		_, err2 := protofilters.NewMatcher().Match(&test.Test{}, filters.Where("number_field").StringEquals("a"))
+		br := filters.BadRequest("filter", errors.Join(err1, errors.New("internal"), err2))
+		require.NotNil(t, br)
+		require.Len(t, br.FieldViolations, 2)
+		assert.Equal(t, "filter", br.FieldViolations[0].Field)
+		assert.Equal(t, err1.Error(), br.FieldViolations[0].Description)
+		assert.Equal(t, "number_field: cannot use string filter on int64", br.FieldViolations[1].Description)
+	})
+}
@@ -78,6 +78,11 @@ func (x *StringFilter) Match(v *string) (bool, error) {
 			return strings.HasSuffix(strings.ToLower(value), strings.ToLower(x.GetHasSuffix())), nil
 		}
 		return strings.HasSuffix(value, x.GetHasSuffix()), nil
+	case *StringFilter_Contains:
+		if insensitive {
+			return strings.Contains(strings.ToLower(value), strings.ToLower(x.GetContains())), nil
+		}
+		return strings.Contains(value, x.GetContains()), nil
 	case *StringFilter_Regex:
 		reg, err := regexp.Compile(x.GetRegex())
 		if err != nil {
@@ -116,6 +121,8 @@ func (x *StringFilter) Format() string {
 		return out + fmt.Sprintf("has_prefix '%s'", x.GetHasPrefix())
 	case *StringFilter_HasSuffix:
 		return out + fmt.Sprintf("has_suffix '%s'", x.GetHasSuffix())
+	case *StringFilter_Contains:
+		return out + fmt.Sprintf("contains '%s'", x.GetContains())
 	case *StringFilter_Regex:
 		return out + fmt.Sprintf("matches '%s'", x.GetRegex())
 	case *StringFilter_In_:
@@ -66,6 +66,7 @@ var StringFilterFields = struct {
 	In              string
 	Sup             string
 	Inf             string
+	Contains        string
 	CaseInsensitive string
 }{
 	Equals:          "equals",
@@ -75,6 +76,7 @@ var StringFilterFields = struct {
 	In:              "in",
 	Sup:             "sup",
 	Inf:             "inf",
+	Contains:        "contains",
 	CaseInsensitive: "case_insensitive",
 }
 
@@ -278,6 +278,15 @@ func (m *StringFilter_Inf) CloneVT() isStringFilter_Condition {
 	return r
 }
 
+func (m *StringFilter_Contains) CloneVT() isStringFilter_Condition {
+	if m == nil {
+		return (*StringFilter_Contains)(nil)
+	}
+	r := new(StringFilter_Contains)
+	r.Contains = m.Contains
+	return r
+}
+
 func (m *NumberFilter_In) CloneVT() *NumberFilter_In {
 	if m == nil {
 		return (*NumberFilter_In)(nil)
@@ -1018,6 +1027,20 @@ func (m *StringFilter_Inf) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
 	dAtA[i] = 0x42
 	return len(dAtA) - i, nil
 }
+func (m *StringFilter_Contains) MarshalToVT(dAtA []byte) (int, error) {
+	size := m.SizeVT()
+	return m.MarshalToSizedBufferVT(dAtA[:size])
+}
+
+func (m *StringFilter_Contains) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	i -= len(m.Contains)
+	copy(dAtA[i:], m.Contains)
+	i = protohelpers.EncodeVarint(dAtA, i, uint64(len(m.Contains)))
+	i--
+	dAtA[i] = 0x4a
+	return len(dAtA) - i, nil
+}
 func (m *NumberFilter_In) MarshalVT() (dAtA []byte, err error) {
 	if m == nil {
 		return nil, nil
@@ -1694,6 +1717,16 @@ func (m *StringFilter_Inf) SizeVT() (n int) {
 	n += 1 + l + protohelpers.SizeOfVarint(uint64(l))
 	return n
 }
+func (m *StringFilter_Contains) SizeVT() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Contains)
+	n += 1 + l + protohelpers.SizeOfVarint(uint64(l))
+	return n
+}
 func (m *NumberFilter_In) SizeVT() (n int) {
 	if m == nil {
 		return 0
@@ -3016,6 +3049,38 @@ func (m *StringFilter) UnmarshalVT(dAtA []byte) error {
 			}
 			m.Condition = &StringFilter_Inf{Inf: string(dAtA[iNdEx:postIndex])}
 			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Contains", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return protohelpers.ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return protohelpers.ErrInvalidLength
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return protohelpers.ErrInvalidLength
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Condition = &StringFilter_Contains{Contains: string(dAtA[iNdEx:postIndex])}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := protohelpers.Skip(dAtA[iNdEx:])
@@ -43,6 +43,10 @@ func TestFormat(t *testing.T) {
 		{"StringNotHasSuffix", Where("name").StringNotHasSuffix("hn"), "name not has_suffix 'hn'"},
 		{"StringIHasSuffix", Where("name").StringIHasSuffix("HN"), "name ihas_suffix 'HN'"},
 		{"StringNotIHasSuffix", Where("name").StringNotIHasSuffix("HN"), "name not ihas_suffix 'HN'"},
+		{"StringContains", Where("name").StringContains("oh"), "name contains 'oh'"},
+		{"StringNotContains", Where("name").StringNotContains("oh"), "name not contains 'oh'"},
+		{"StringIContains", Where("name").StringIContains("OH"), "name icontains 'OH'"},
+		{"StringNotIContains", Where("name").StringNotIContains("OH"), "name not icontains 'OH'"},
 		{"StringRegex", Where("name").StringRegex("Jo.*"), "name matches 'Jo.*'"},
 		{"StringNotRegex", Where("name").StringNotRegex("Jo.*"), "name not matches 'Jo.*'"},
 		{"StringIN", Where("name").StringIN("John", "Doe"), "name in ('John', 'Doe')"},
@@ -236,6 +240,10 @@ func sampleFilters() []*Filter {
 		StringNotHasSuffix("hn"),
 		StringIHasSuffix("hn"),
 		StringNotIHasSuffix("hn"),
+		StringContains("oh"),
+		StringNotContains("oh"),
+		StringIContains("oh"),
+		StringNotIContains("oh"),
 		StringRegex("Jo.*"),
 		StringNotRegex("Jo.*"),
 		StringIN("a", "b"),
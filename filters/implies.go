@@ -0,0 +1,583 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Implies reports whether all the messages matching a also match b.
+// It is sound but incomplete: it may report false for some expressions
+// implying the other one, but never true for the ones that do not.
+// It supports the equality, in, range, prefix, suffix, contains, null and
+// bool filters. The reasoning on the null and negated filters requires the
+// fields to be known to be singular, i.e. the messages descriptor to be
+// set with WithMessage, which also intersects the filters on the same
+// field, as Normalize does.
+// It returns an error if a field filter has no filter.
+func Implies(a, b FieldFilterer, opts ...NormalizeOption) (bool, error) {
+	n, err := newNormalizer(opts, a, b)
+	if err != nil {
+		return false, err
+	}
+	return n.implies(n.tree(a), n.tree(b)), nil
+}
+
+// Disjoint reports whether no message matches both a and b.
+// As Implies, it is sound but incomplete, and mostly requires the fields to
+// be known to be singular with WithMessage: the different elements of a
+// repeated field may match both expressions.
+// It returns an error if a field filter has no filter.
+func Disjoint(a, b FieldFilterer, opts ...NormalizeOption) (bool, error) {
+	n, err := newNormalizer(opts, a, b)
+	if err != nil {
+		return false, err
+	}
+	if n.tree(And(a, b)).op == opFalse {
+		return true, nil
+	}
+	return n.disjoint(n.tree(a), n.tree(b)), nil
+}
+
+func newNormalizer(opts []NormalizeOption, fs ...FieldFilterer) (*normalizer, error) {
+	n := &normalizer{}
+	for _, v := range opts {
+		v(n)
+	}
+	for _, f := range fs {
+		if f == nil {
+			continue
+		}
+		if err := validate(f.Expr()); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func validate(expr *Expression) error {
+	if expr == nil {
+		return nil
+	}
+	if c := expr.Condition; c != nil && c.GetFilter().GetMatch() == nil {
+		return fmt.Errorf("filters: %s: empty filter", c.Field)
+	}
+	for _, v := range expr.AndExprs {
+		if err := validate(v); err != nil {
+			return err
+		}
+	}
+	for _, v := range expr.OrExprs {
+		if err := validate(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tree returns the normalized tree of the expression
+func (n *normalizer) tree(f FieldFilterer) *node {
+	if f == nil || f.Expr() == nil {
+		return &node{op: opTrue}
+	}
+	return n.simplify(build(f.Expr()))
+}
+
+func (n *normalizer) implies(x, y *node) bool {
+	switch {
+	case x.op == opFalse || y.op == opTrue:
+		return true
+	case x.op == opTrue || y.op == opFalse:
+		return false
+	case x.op == opOr:
+		for _, v := range x.children {
+			if !n.implies(v, y) {
+				return false
+			}
+		}
+		return true
+	case y.op == opAnd:
+		for _, v := range y.children {
+			if !n.implies(x, v) {
+				return false
+			}
+		}
+		return true
+	}
+	if y.op == opOr {
+		for _, v := range y.children {
+			if n.implies(x, v) {
+				return true
+			}
+		}
+	}
+	if x.op == opAnd {
+		for _, v := range x.children {
+			if n.implies(v, y) {
+				return true
+			}
+		}
+		return false
+	}
+	return y.op == opLeaf && n.leafImplies(x.leaf, y.leaf)
+}
+
+func (n *normalizer) disjoint(x, y *node) bool {
+	switch {
+	case x.op == opFalse || y.op == opFalse:
+		return true
+	case x.op == opTrue || y.op == opTrue:
+		return false
+	case x.op == opOr:
+		for _, v := range x.children {
+			if !n.disjoint(v, y) {
+				return false
+			}
+		}
+		return true
+	case y.op == opOr:
+		for _, v := range y.children {
+			if !n.disjoint(x, v) {
+				return false
+			}
+		}
+		return true
+	case x.op == opAnd:
+		for _, v := range x.children {
+			if n.disjoint(v, y) {
+				return true
+			}
+		}
+		return false
+	case y.op == opAnd:
+		for _, v := range y.children {
+			if n.disjoint(x, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return n.leafDisjoint(x.leaf, y.leaf)
+}
+
+// leafImplies reports whether the field filter a implies b.
+// The field filters on the repeated fields match if one of the elements
+// matches, and the negated ones never match on the repeated scalar fields,
+// so that without knowing the field to be singular, only the implications
+// between the values filters of the same polarity hold.
+func (n *normalizer) leafImplies(a, b *FieldFilter) bool {
+	if a.Field != b.Field {
+		return false
+	}
+	if leafKey(a) == leafKey(b) {
+		return true
+	}
+	fa, fb := a.GetFilter(), b.GetFilter()
+	_, nullA := fa.GetMatch().(*Filter_Null)
+	_, nullB := fb.GetMatch().(*Filter_Null)
+	if !nullA && !nullB && fa.GetNot() == fb.GetNot() {
+		if fa.GetNot() {
+			return valuesImply(fb, fa)
+		}
+		return valuesImply(fa, fb)
+	}
+	if !n.singular(a.Field) {
+		return false
+	}
+	// the null filter matches the unset values, which the values filters
+	// may match too, see unsetMatch
+	switch {
+	case nullA && nullB:
+		return false
+	// is not null
+	case nullB && fb.GetNot():
+		match, known := n.unsetMatch(a.Field, fa)
+		return known && !match
+	case nullB:
+		return false
+	// is null
+	case nullA && !fa.GetNot():
+		match, known := n.unsetMatch(b.Field, fb)
+		return known && match
+	case nullA:
+		return false
+	// a value filter implies a negated one if they are disjoint
+	case !fa.GetNot() && fb.GetNot():
+		return valuesDisjoint(fa, fb)
+	}
+	return false
+}
+
+// leafDisjoint reports whether the field filters cannot match the same
+// message, which requires the field to be singular
+func (n *normalizer) leafDisjoint(a, b *FieldFilter) bool {
+	if a.Field != b.Field || !n.singular(a.Field) {
+		return false
+	}
+	fa, fb := a.GetFilter(), b.GetFilter()
+	_, nullA := fa.GetMatch().(*Filter_Null)
+	_, nullB := fb.GetMatch().(*Filter_Null)
+	switch {
+	case nullA && nullB:
+		return fa.GetNot() != fb.GetNot()
+	case nullA:
+		// is null and a values filter not matching the unset values
+		match, known := n.unsetMatch(b.Field, fb)
+		return !fa.GetNot() && known && !match
+	case nullB:
+		match, known := n.unsetMatch(a.Field, fa)
+		return !fb.GetNot() && known && !match
+	case fa.GetNot() && fb.GetNot():
+		// both match the unset values
+		return false
+	case fa.GetNot():
+		return valuesImply(fb, fa)
+	case fb.GetNot():
+		return valuesImply(fa, fb)
+	}
+	return valuesDisjoint(fa, fb)
+}
+
+// unsetMatch reports whether the values filter f matches the unset field of
+// the path, and whether it is known. The values filters never match the
+// unset optional fields, and the negated ones always do, but the unset
+// wrappers, google.protobuf.Timestamp and Duration fields are read as their
+// zero values.
+func (n *normalizer) unsetMatch(path string, f *Filter) (match, known bool) {
+	var (
+		ok  bool
+		err error
+	)
+	switch n.fieldMessage(path) {
+	case "":
+		return f.GetNot(), true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		if f.GetNumber() == nil {
+			return false, false
+		}
+		ok, err = f.GetNumber().Match(new(float64))
+	case "google.protobuf.StringValue":
+		if f.GetString_() == nil {
+			return false, false
+		}
+		ok, err = f.GetString_().Match(new(string))
+	case "google.protobuf.BoolValue":
+		if f.GetBool() == nil {
+			return false, false
+		}
+		ok = !f.GetBool().GetEquals()
+	case "google.protobuf.Timestamp":
+		if f.GetTime() == nil {
+			return false, false
+		}
+		ok, err = f.GetTime().Match(&timestamppb.Timestamp{})
+	case "google.protobuf.Duration":
+		if f.GetDuration() == nil {
+			return false, false
+		}
+		ok, err = f.GetDuration().Match(&durationpb.Duration{})
+	default:
+		return false, false
+	}
+	if err != nil {
+		return false, false
+	}
+	return ok != f.GetNot(), true
+}
+
+// fieldMessage returns the name of the message of the last field of the
+// path, or an empty name if it is not a message field
+func (n *normalizer) fieldMessage(path string) protoreflect.FullName {
+	md := n.md
+	for _, v := range strings.Split(path, ".") {
+		if md == nil {
+			return ""
+		}
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(v)
+		}
+		if fd == nil {
+			return ""
+		}
+		md = fd.Message()
+	}
+	if md == nil {
+		return ""
+	}
+	return md.FullName()
+}
+
+// valuesImply reports whether all the values matching a, not negated,
+// match b, not negated
+func valuesImply(a, b *Filter) bool {
+	switch fa := a.GetMatch().(type) {
+	case *Filter_Number:
+		fb := b.GetNumber()
+		if fb == nil {
+			return false
+		}
+		if vs, ok := numberValues(fa.Number); ok {
+			return all(vs, fb.Match)
+		}
+		switch c := fa.Number.GetCondition().(type) {
+		case *NumberFilter_Sup:
+			sup, ok := fb.GetCondition().(*NumberFilter_Sup)
+			return ok && c.Sup >= sup.Sup
+		case *NumberFilter_Inf:
+			inf, ok := fb.GetCondition().(*NumberFilter_Inf)
+			return ok && c.Inf <= inf.Inf
+		}
+	case *Filter_String_:
+		fb := b.GetString_()
+		if fb == nil || fa.String_.GetCaseInsensitive() {
+			return false
+		}
+		if vs, ok := stringValues(fa.String_); ok {
+			return all(vs, fb.Match)
+		}
+		if fb.GetCaseInsensitive() {
+			return false
+		}
+		return stringImplies(fa.String_, fb)
+	case *Filter_Bool:
+		fb := b.GetBool()
+		return fb != nil && fa.Bool.GetEquals() == fb.GetEquals()
+	case *Filter_Time:
+		fb := b.GetTime()
+		if fb == nil {
+			return false
+		}
+		switch c := fa.Time.GetCondition().(type) {
+		case *TimeFilter_Equals:
+			return matches(fb.Match(c.Equals))
+		case *TimeFilter_After:
+			after, ok := fb.GetCondition().(*TimeFilter_After)
+			return ok && !c.After.AsTime().Before(after.After.AsTime())
+		case *TimeFilter_Before:
+			before, ok := fb.GetCondition().(*TimeFilter_Before)
+			return ok && !c.Before.AsTime().After(before.Before.AsTime())
+		}
+	case *Filter_Duration:
+		fb := b.GetDuration()
+		if fb == nil {
+			return false
+		}
+		switch c := fa.Duration.GetCondition().(type) {
+		case *DurationFilter_Equals:
+			return matches(fb.Match(c.Equals))
+		case *DurationFilter_Sup:
+			sup, ok := fb.GetCondition().(*DurationFilter_Sup)
+			return ok && c.Sup.AsDuration() >= sup.Sup.AsDuration()
+		case *DurationFilter_Inf:
+			inf, ok := fb.GetCondition().(*DurationFilter_Inf)
+			return ok && c.Inf.AsDuration() <= inf.Inf.AsDuration()
+		}
+	}
+	return false
+}
+
+// valuesDisjoint reports whether no value matches both a and b, not
+// negated
+func valuesDisjoint(a, b *Filter) bool {
+	switch fa := a.GetMatch().(type) {
+	case *Filter_Number:
+		fb := b.GetNumber()
+		if fb == nil {
+			return false
+		}
+		if vs, ok := numberValues(fa.Number); ok {
+			return none(vs, fb.Match)
+		}
+		if vs, ok := numberValues(fb); ok {
+			return none(vs, fa.Number.Match)
+		}
+		return disjointRanges(fa.Number.GetCondition(), fb.GetCondition())
+	case *Filter_String_:
+		fb := b.GetString_()
+		if fb == nil {
+			return false
+		}
+		if vs, ok := stringValues(fa.String_); ok && !fa.String_.GetCaseInsensitive() {
+			return none(vs, fb.Match)
+		}
+		if vs, ok := stringValues(fb); ok && !fb.GetCaseInsensitive() {
+			return none(vs, fa.String_.Match)
+		}
+		if fa.String_.GetCaseInsensitive() || fb.GetCaseInsensitive() {
+			return false
+		}
+		if pa, pb := fa.String_.GetHasPrefix(), fb.GetHasPrefix(); isCondition[*StringFilter_HasPrefix](fa.String_.GetCondition()) && isCondition[*StringFilter_HasPrefix](fb.GetCondition()) {
+			return !strings.HasPrefix(pa, pb) && !strings.HasPrefix(pb, pa)
+		}
+		return disjointRanges(fa.String_.GetCondition(), fb.GetCondition())
+	case *Filter_Bool:
+		fb := b.GetBool()
+		return fb != nil && fa.Bool.GetEquals() != fb.GetEquals()
+	case *Filter_Time:
+		fb := b.GetTime()
+		if fb == nil {
+			return false
+		}
+		if c, ok := fa.Time.GetCondition().(*TimeFilter_Equals); ok {
+			return misses(fb.Match(c.Equals))
+		}
+		if c, ok := fb.GetCondition().(*TimeFilter_Equals); ok {
+			return misses(fa.Time.Match(c.Equals))
+		}
+		after, before := fa.Time.GetAfter(), fb.GetBefore()
+		if after == nil {
+			after, before = fb.GetAfter(), fa.Time.GetBefore()
+		}
+		return after != nil && before != nil && !before.AsTime().After(after.AsTime())
+	case *Filter_Duration:
+		fb := b.GetDuration()
+		if fb == nil {
+			return false
+		}
+		if c, ok := fa.Duration.GetCondition().(*DurationFilter_Equals); ok {
+			return misses(fb.Match(c.Equals))
+		}
+		if c, ok := fb.GetCondition().(*DurationFilter_Equals); ok {
+			return misses(fa.Duration.Match(c.Equals))
+		}
+		sup, inf := fa.Duration.GetSup(), fb.GetInf()
+		if sup == nil {
+			sup, inf = fb.GetSup(), fa.Duration.GetInf()
+		}
+		return sup != nil && inf != nil && inf.AsDuration() <= sup.AsDuration()
+	}
+	return false
+}
+
+// stringImplies reports whether all the strings matching the prefix,
+// suffix, contains or range filter a match b, both case sensitive
+func stringImplies(a, b *StringFilter) bool {
+	switch c := a.GetCondition().(type) {
+	case *StringFilter_HasPrefix:
+		switch d := b.GetCondition().(type) {
+		case *StringFilter_HasPrefix:
+			return strings.HasPrefix(c.HasPrefix, d.HasPrefix)
+		case *StringFilter_Contains:
+			return strings.Contains(c.HasPrefix, d.Contains)
+		case *StringFilter_Sup:
+			// the strings with the prefix are greater or equal to it
+			return c.HasPrefix > d.Sup
+		}
+	case *StringFilter_HasSuffix:
+		switch d := b.GetCondition().(type) {
+		case *StringFilter_HasSuffix:
+			return strings.HasSuffix(c.HasSuffix, d.HasSuffix)
+		case *StringFilter_Contains:
+			return strings.Contains(c.HasSuffix, d.Contains)
+		}
+	case *StringFilter_Contains:
+		d, ok := b.GetCondition().(*StringFilter_Contains)
+		return ok && strings.Contains(c.Contains, d.Contains)
+	case *StringFilter_Sup:
+		d, ok := b.GetCondition().(*StringFilter_Sup)
+		return ok && c.Sup >= d.Sup
+	case *StringFilter_Inf:
+		d, ok := b.GetCondition().(*StringFilter_Inf)
+		return ok && c.Inf <= d.Inf
+	}
+	return false
+}
+
+// disjointRanges reports whether the sup and inf conditions do not
+// overlap, e.g. sup 5 and inf 3
+func disjointRanges(a, b any) bool {
+	switch c := a.(type) {
+	case *NumberFilter_Sup:
+		d, ok := b.(*NumberFilter_Inf)
+		return ok && d.Inf <= c.Sup
+	case *NumberFilter_Inf:
+		d, ok := b.(*NumberFilter_Sup)
+		return ok && c.Inf <= d.Sup
+	case *StringFilter_Sup:
+		d, ok := b.(*StringFilter_Inf)
+		return ok && d.Inf <= c.Sup
+	case *StringFilter_Inf:
+		d, ok := b.(*StringFilter_Sup)
+		return ok && c.Inf <= d.Sup
+	}
+	return false
+}
+
+// numberValues returns the values of the equality and in filters
+func numberValues(f *NumberFilter) ([]float64, bool) {
+	switch c := f.GetCondition().(type) {
+	case *NumberFilter_Equals:
+		return []float64{c.Equals}, true
+	case *NumberFilter_In_:
+		return c.In.GetValues(), true
+	}
+	return nil, false
+}
+
+// stringValues returns the values of the equality and in filters
+func stringValues(f *StringFilter) ([]string, bool) {
+	switch c := f.GetCondition().(type) {
+	case *StringFilter_Equals:
+		return []string{c.Equals}, true
+	case *StringFilter_In_:
+		return c.In.GetValues(), true
+	}
+	return nil, false
+}
+
+func isCondition[T any](c any) bool {
+	_, ok := c.(T)
+	return ok
+}
+
+// matches reports whether the value matched without error
+func matches(ok bool, err error) bool {
+	return err == nil && ok
+}
+
+// misses reports whether the value did not match without error
+func misses(ok bool, err error) bool {
+	return err == nil && !ok
+}
+
+// all reports whether all the values match
+func all[T any](vs []T, match func(*T) (bool, error)) bool {
+	for _, v := range vs {
+		if !matches(match(&v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// none reports whether none of the values match
+func none[T any](vs []T, match func(*T) (bool, error)) bool {
+	for _, v := range vs {
+		if !misses(match(&v)) {
+			return false
+		}
+	}
+	return true
+}
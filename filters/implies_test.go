@@ -0,0 +1,150 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestImplies(t *testing.T) {
+	now := time.Unix(1000, 0)
+	withMessage := []filters.NormalizeOption{filters.WithMessage(md)}
+	tests := []struct {
+		name     string
+		a, b     filters.FieldFilterer
+		opts     []filters.NormalizeOption
+		implies  bool
+		disjoint bool
+	}{
+		{name: "nil", implies: true},
+		{name: "anything implies nil", a: filters.Where("string_field").StringEquals("a"), implies: true},
+		{name: "nil implies nothing", b: filters.Where("string_field").StringEquals("a")},
+		{name: "same", a: filters.Where("string_field").StringEquals("a"), b: filters.Where("string_field").StringEquals("a"), implies: true},
+		{name: "other field", a: filters.Where("string_field").StringEquals("a"), b: filters.Where("enum_field").StringEquals("a")},
+		{name: "equals in", a: filters.Where("string_field").StringEquals("a"), b: filters.Where("string_field").StringIN("a", "b"), implies: true},
+		{name: "in equals", a: filters.Where("string_field").StringIN("a", "b"), b: filters.Where("string_field").StringEquals("a")},
+		{name: "in in", a: filters.Where("number_field").NumberIN(1, 2), b: filters.Where("number_field").NumberIN(1, 2, 3), implies: true},
+		{name: "equals", a: filters.Where("string_field").StringEquals("a"), b: filters.Where("string_field").StringEquals("b")},
+		{name: "equals singular", a: filters.Where("string_field").StringEquals("a"), b: filters.Where("string_field").StringEquals("b"), opts: withMessage, disjoint: true},
+		{name: "equals ieq", a: filters.Where("string_field").StringEquals("A"), b: filters.Where("string_field").StringIEquals("a"), implies: true},
+		{name: "equals prefix", a: filters.Where("string_field").StringEquals("abc"), b: filters.Where("string_field").StringHasPrefix("ab"), implies: true},
+		{name: "equals regex", a: filters.Where("string_field").StringEquals("abc"), b: filters.Where("string_field").StringRegex("^a.c$"), implies: true},
+		{name: "prefix prefix", a: filters.Where("string_field").StringHasPrefix("abc"), b: filters.Where("string_field").StringHasPrefix("ab"), implies: true},
+		{name: "prefix contains", a: filters.Where("string_field").StringHasPrefix("abc"), b: filters.Where("string_field").StringContains("bc"), implies: true},
+		{name: "prefix sup", a: filters.Where("string_field").StringHasPrefix("b"), b: filters.Where("string_field").StringSup("a"), implies: true},
+		{name: "prefix iprefix", a: filters.Where("string_field").StringHasPrefix("abc"), b: filters.Where("string_field").StringIHasPrefix("ab")},
+		{name: "disjoint prefixes", a: filters.Where("string_field").StringHasPrefix("ab"), b: filters.Where("string_field").StringHasPrefix("b"), opts: withMessage, disjoint: true},
+		{name: "overlapping prefixes", a: filters.Where("string_field").StringHasPrefix("ab"), b: filters.Where("string_field").StringHasPrefix("a"), opts: withMessage, implies: true},
+		{name: "suffix suffix", a: filters.Where("string_field").StringHasSuffix("abc"), b: filters.Where("string_field").StringHasSuffix("bc"), implies: true},
+		{name: "sup sup", a: filters.Where("number_field").NumberSup(3), b: filters.Where("number_field").NumberSup(2), implies: true},
+		{name: "sup sup reverse", a: filters.Where("number_field").NumberSup(2), b: filters.Where("number_field").NumberSup(3)},
+		{name: "inf inf", a: filters.Where("number_field").NumberInf(2), b: filters.Where("number_field").NumberInf(3), implies: true},
+		{name: "range", a: filters.And(filters.Where("number_field").NumberSup(2), filters.Where("number_field").NumberInf(5)), b: filters.Where("number_field").NumberSup(1), implies: true},
+		{name: "in range", a: filters.Where("number_field").NumberIN(3, 4), b: filters.And(filters.Where("number_field").NumberSup(2), filters.Where("number_field").NumberInf(5)), implies: true},
+		{name: "disjoint ranges", a: filters.Where("number_field").NumberSup(3), b: filters.Where("number_field").NumberInf(3), opts: withMessage, disjoint: true},
+		{name: "disjoint repeated ranges", a: filters.Where("repeated_message_field.number_field").NumberSup(3), b: filters.Where("repeated_message_field.number_field").NumberInf(3), opts: withMessage},
+		{name: "equals not equals", a: filters.Where("number_field").NumberEquals(1), b: filters.Where("number_field").NumberNotEquals(2), opts: withMessage, implies: true},
+		{name: "equals not equals repeated", a: filters.Where("repeated_string_field").StringEquals("a"), b: filters.Where("repeated_string_field").StringNotEquals("b"), opts: withMessage},
+		{name: "equals not in", a: filters.Where("number_field").NumberEquals(1), b: filters.Where("number_field").NumberNotIN(1, 2), opts: withMessage, disjoint: true},
+		{name: "not in not equals", a: filters.Where("string_field").StringNotIN("a", "b"), b: filters.Where("string_field").StringNotEquals("a"), implies: true},
+		{name: "not equals equals", a: filters.Where("string_field").StringNotEquals("a"), b: filters.Where("string_field").StringEquals("b"), opts: withMessage},
+		{name: "bools", a: filters.Where("bool_field").True(), b: filters.Where("bool_field").False(), opts: withMessage, disjoint: true},
+		{name: "bool or", a: filters.Where("bool_field").True(), b: filters.Where("bool_field").True().OrWhere("string_field").StringEquals("a"), implies: true},
+		{name: "or", a: filters.Or(filters.Where("number_field").NumberEquals(1), filters.Where("number_field").NumberEquals(2)), b: filters.Where("number_field").NumberIN(1, 2), implies: true},
+		{name: "and", a: filters.And(filters.Where("bool_field").True(), filters.Where("string_field").StringEquals("a")), b: filters.Where("bool_field").True(), implies: true},
+		{name: "and of ors", a: filters.And(filters.Where("bool_field").True(), filters.Where("string_field").StringIN("a", "b")), b: filters.Or(filters.Where("bool_field").False(), filters.Where("string_field").StringHasPrefix("c")), opts: withMessage, disjoint: true},
+		{name: "not null", a: filters.Where("optional_number_field").NumberSup(1), b: filters.Where("optional_number_field").NotNull(), opts: withMessage, implies: true},
+		{name: "not null without message", a: filters.Where("optional_number_field").NumberSup(1), b: filters.Where("optional_number_field").NotNull()},
+		{name: "null", a: filters.Where("optional_number_field").Null(), b: filters.Where("optional_number_field").NumberNotEquals(1), opts: withMessage, implies: true},
+		{name: "null value", a: filters.Where("optional_string_field").Null(), b: filters.Where("optional_string_field").StringEquals("a"), opts: withMessage, disjoint: true},
+		{name: "null not null", a: filters.Where("message_field").Null(), b: filters.Where("message_field").NotNull(), opts: withMessage, disjoint: true},
+		{name: "time", a: filters.Where("time_value_field").TimeAfter(now), b: filters.Where("time_value_field").TimeAfter(now.Add(-time.Second)), implies: true},
+		{name: "time equals", a: filters.Where("time_value_field").TimeEquals(now), b: filters.Where("time_value_field").TimeBefore(now), opts: withMessage, disjoint: true},
+		{name: "times", a: filters.Where("time_value_field").TimeAfter(now), b: filters.Where("time_value_field").TimeBefore(now), opts: withMessage, disjoint: true},
+		{name: "duration", a: filters.Where("duration_value_field").DurationInf(time.Second), b: filters.Where("duration_value_field").DurationInf(time.Minute), implies: true},
+		{name: "durations", a: filters.Where("duration_value_field").DurationSup(time.Minute), b: filters.Where("duration_value_field").DurationInf(time.Second), opts: withMessage, disjoint: true},
+
+		// the unset wrappers, timestamps and durations are read as zero values
+		{name: "wrapper zero not null", a: filters.Where("number_value_field").NumberEquals(0), b: filters.Where("number_value_field").NotNull(), opts: withMessage},
+		{name: "wrapper zero in not null", a: filters.Where("number_value_field").NumberIN(0, 2), b: filters.Where("number_value_field").NotNull(), opts: withMessage},
+		{name: "wrapper inf not null", a: filters.Where("number_value_field").NumberInf(1), b: filters.Where("number_value_field").NotNull(), opts: withMessage},
+		{name: "wrapper not zero not null", a: filters.Where("number_value_field").NumberEquals(1), b: filters.Where("number_value_field").NotNull(), opts: withMessage, implies: true},
+		{name: "wrapper null not zero", a: filters.Where("number_value_field").Null(), b: filters.Where("number_value_field").NumberNotEquals(0), opts: withMessage, disjoint: true},
+		{name: "wrapper null zero", a: filters.Where("number_value_field").Null(), b: filters.Where("number_value_field").NumberEquals(0), opts: withMessage, implies: true},
+		{name: "wrapper zero null", a: filters.Where("number_value_field").NumberEquals(0), b: filters.Where("number_value_field").Null(), opts: withMessage},
+		{name: "wrapper false not null", a: filters.Where("bool_value_field").False(), b: filters.Where("bool_value_field").NotNull(), opts: withMessage},
+		{name: "wrapper false null", a: filters.Where("bool_value_field").False(), b: filters.Where("bool_value_field").Null(), opts: withMessage},
+		{name: "wrapper true null", a: filters.Where("bool_value_field").True(), b: filters.Where("bool_value_field").Null(), opts: withMessage, disjoint: true},
+		{name: "wrapper empty string null", a: filters.Where("string_value_field").StringEquals(""), b: filters.Where("string_value_field").Null(), opts: withMessage},
+		{name: "wrapper string inf null", a: filters.Where("string_value_field").StringInf("a"), b: filters.Where("string_value_field").Null(), opts: withMessage},
+		{name: "wrapper string inf not null", a: filters.Where("string_value_field").StringInf("a"), b: filters.Where("string_value_field").NotNull(), opts: withMessage},
+		{name: "time before not null", a: filters.Where("time_value_field").TimeBefore(now), b: filters.Where("time_value_field").NotNull(), opts: withMessage},
+		{name: "time null before", a: filters.Where("time_value_field").Null(), b: filters.Where("time_value_field").TimeBefore(now), opts: withMessage, implies: true},
+		{name: "time null after", a: filters.Where("time_value_field").Null(), b: filters.Where("time_value_field").TimeAfter(now), opts: withMessage, disjoint: true},
+		{name: "duration inf null", a: filters.Where("duration_value_field").DurationInf(time.Second), b: filters.Where("duration_value_field").Null(), opts: withMessage},
+	}
+	msgs := []*test.Test{
+		{},
+		{StringField: "a", NumberField: 1, BoolField: true, RepeatedStringField: []string{"a", "b"}},
+		{StringField: "abc", NumberField: 3, TimeValueField: timestamppb.New(now), DurationValueField: durationpb.New(time.Second)},
+		{StringField: "b", NumberField: 4, OptionalNumberField: proto.Int64(1), OptionalStringField: proto.String("a"), MessageField: &test.Test{}},
+		{StringField: "c", NumberField: 2, TimeValueField: timestamppb.New(now.Add(time.Hour)), DurationValueField: durationpb.New(time.Hour), RepeatedMessageField: []*test.Test{{NumberField: 1}, {NumberField: 5}}},
+
+		{NumberValueField: wrapperspb.Int64(0), BoolValueField: wrapperspb.Bool(false), StringValueField: wrapperspb.String("")},
+		{NumberValueField: wrapperspb.Int64(2), BoolValueField: wrapperspb.Bool(true), StringValueField: wrapperspb.String("b"), TimeValueField: timestamppb.New(time.Time{})},
+	}
+	m := protofilters.NewMatcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filters.Implies(tt.a, tt.b, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.implies, got, "implies")
+			got, err = filters.Disjoint(tt.a, tt.b, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.disjoint, got, "disjoint")
+			for _, msg := range msgs {
+				a, err := m.Match(msg, tt.a)
+				require.NoError(t, err)
+				b, err := m.Match(msg, tt.b)
+				require.NoError(t, err)
+				if tt.implies {
+					assert.True(t, !a || b, "%v", msg)
+				}
+				if tt.disjoint {
+					assert.False(t, a && b, "%v", msg)
+				}
+			}
+		})
+	}
+
+	_, err := filters.Implies(&filters.Expression{Condition: &filters.FieldFilter{Field: "string_field"}}, nil)
+	assert.Error(t, err)
+	_, err = filters.Disjoint(nil, &filters.Expression{Condition: &filters.FieldFilter{Field: "string_field", Filter: &filters.Filter{}}})
+	assert.Error(t, err)
+}
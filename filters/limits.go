@@ -0,0 +1,136 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+)
+
+// ErrLimitExceeded is matched by the LimitError errors.
+var ErrLimitExceeded = errors.New("filters: limit exceeded")
+
+// LimitError is returned when an expression exceeds one of the Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, e.g. MaxDepth
+	Limit string
+	Max   int
+	// Field is the field path of the filter exceeding the limit, if any
+	Field string
+}
+
+func (e *LimitError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("filters: %s: %s of %d exceeded", e.Field, e.Limit, e.Max)
+	}
+	return fmt.Sprintf("filters: %s of %d exceeded", e.Limit, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// Limits bounds the resources used to match an expression, so that the
+// filters from untrusted sources can be matched safely.
+// The zero values are unlimited.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of the expressions, the
+	// expression without and / or expressions having a depth of 1
+	MaxDepth int
+	// MaxNodes is the maximum number of field filters
+	MaxNodes int
+	// MaxRegexSize is the maximum number of instructions of the compiled
+	// regular expressions
+	MaxRegexSize int
+	// MaxInLength is the maximum number of values of the in filters
+	MaxInLength int
+	// MaxListElements is the maximum number of list elements scanned to
+	// match a message, which is enforced by the matchers
+	MaxListElements int
+}
+
+// Check returns a *LimitError if the expression exceeds the limits which
+// do not depend on the matched message.
+func (l Limits) Check(f FieldFilterer) error {
+	if f == nil || f.Expr() == nil {
+		return nil
+	}
+	nodes := 0
+	return l.check(f.Expr(), 1, &nodes)
+}
+
+func (l Limits) check(expr *Expression, depth int, nodes *int) error {
+	if expr == nil {
+		return nil
+	}
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: l.MaxDepth}
+	}
+	if expr.Condition != nil {
+		*nodes++
+		if l.MaxNodes > 0 && *nodes > l.MaxNodes {
+			return &LimitError{Limit: "MaxNodes", Max: l.MaxNodes}
+		}
+		if err := l.checkFilter(expr.Condition); err != nil {
+			return err
+		}
+	}
+	for _, v := range expr.AndExprs {
+		if err := l.check(v, depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	for _, v := range expr.OrExprs {
+		if err := l.check(v, depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l Limits) checkFilter(ff *FieldFilter) error {
+	var in int
+	switch f := ff.GetFilter().GetMatch().(type) {
+	case *Filter_String_:
+		in = len(f.String_.GetIn().GetValues())
+		if l.MaxRegexSize > 0 && f.String_.GetRegex() != "" && regexSize(f.String_.GetRegex()) > l.MaxRegexSize {
+			return &LimitError{Limit: "MaxRegexSize", Max: l.MaxRegexSize, Field: ff.Field}
+		}
+	case *Filter_Number:
+		in = len(f.Number.GetIn().GetValues())
+	}
+	if l.MaxInLength > 0 && in > l.MaxInLength {
+		return &LimitError{Limit: "MaxInLength", Max: l.MaxInLength, Field: ff.Field}
+	}
+	return nil
+}
+
+// regexSize returns the number of instructions of the compiled regular
+// expression, or 0 if it is invalid, which is reported by the matchers
+func regexSize(s string) int {
+	re, err := syntax.Parse(s, syntax.Perl)
+	if err != nil {
+		return 0
+	}
+	p, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return 0
+	}
+	return len(p.Inst)
+}
@@ -0,0 +1,63 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+func TestLimits(t *testing.T) {
+	nested := filters.Where("a").True().And(filters.Where("b").True().And(filters.Where("c").True()))
+	tests := []struct {
+		name   string
+		limits filters.Limits
+		f      filters.FieldFilterer
+		limit  string
+	}{
+		{name: "unlimited", f: nested},
+		{name: "depth", limits: filters.Limits{MaxDepth: 3}, f: nested},
+		{name: "depth exceeded", limits: filters.Limits{MaxDepth: 2}, f: nested, limit: "MaxDepth"},
+		{name: "nodes", limits: filters.Limits{MaxNodes: 3}, f: nested},
+		{name: "nodes exceeded", limits: filters.Limits{MaxNodes: 2}, f: nested, limit: "MaxNodes"},
+		{name: "in", limits: filters.Limits{MaxInLength: 2}, f: filters.Where("a").StringIN("a", "b")},
+		{name: "string in exceeded", limits: filters.Limits{MaxInLength: 2}, f: filters.Where("a").StringIN("a", "b", "c"), limit: "MaxInLength"},
+		{name: "number in exceeded", limits: filters.Limits{MaxInLength: 2}, f: filters.Where("a").True().OrWhere("b").NumberNotIN(1, 2, 3), limit: "MaxInLength"},
+		{name: "regex", limits: filters.Limits{MaxRegexSize: 10}, f: filters.Where("a").StringRegex("^a+$")},
+		{name: "regex exceeded", limits: filters.Limits{MaxRegexSize: 10}, f: filters.Where("a").StringRegex("(a|b|c|d|e)+[0-9]{3,10}"), limit: "MaxRegexSize"},
+		{name: "invalid regex", limits: filters.Limits{MaxRegexSize: 10}, f: filters.Where("a").StringRegex("(")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(tt.f)
+			if tt.limit == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, filters.ErrLimitExceeded)
+			var e *filters.LimitError
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, tt.limit, e.Limit)
+		})
+	}
+	assert.NoError(t, filters.Limits{MaxDepth: 1}.Check(nil))
+}
@@ -0,0 +1,93 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RenameFields returns a copy of the expression where the fields paths,
+// resolved in md by their proto or JSON names, use the JSON names if json is
+// set, or the proto names otherwise.
+func RenameFields(md protoreflect.MessageDescriptor, f FieldFilterer, json bool) (*Expression, error) {
+	if f == nil || f.Expr() == nil {
+		return nil, nil
+	}
+	out := f.Expr().CloneVT()
+	if err := renameFields(md, out, json); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FormatNames formats the expression as Format, with the fields paths
+// using their JSON names if json is set, or their proto names otherwise.
+func (x *Expression) FormatNames(md protoreflect.MessageDescriptor, json bool) (string, error) {
+	e, err := RenameFields(md, x, json)
+	if err != nil {
+		return "", err
+	}
+	return e.Format(), nil
+}
+
+func renameFields(md protoreflect.MessageDescriptor, e *Expression, json bool) error {
+	if e.Condition != nil {
+		path, err := renamePath(md, e.Condition.Field, json)
+		if err != nil {
+			return err
+		}
+		e.Condition.Field = path
+	}
+	for _, v := range e.AndExprs {
+		if err := renameFields(md, v, json); err != nil {
+			return err
+		}
+	}
+	for _, v := range e.OrExprs {
+		if err := renameFields(md, v, json); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renamePath(md protoreflect.MessageDescriptor, path string, json bool) (string, error) {
+	parts := strings.Split(path, ".")
+	m := md
+	for i, v := range parts {
+		if m == nil {
+			return "", fmt.Errorf("filters: %s: not a message path", path)
+		}
+		fd := m.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			fd = m.Fields().ByJSONName(v)
+		}
+		if fd == nil {
+			return "", fmt.Errorf("filters: %w", &UnknownFieldError{Message: string(m.FullName()), Path: v})
+		}
+		if json {
+			parts[i] = fd.JSONName()
+		} else {
+			parts[i] = string(fd.Name())
+		}
+		m = fd.Message()
+	}
+	return strings.Join(parts, "."), nil
+}
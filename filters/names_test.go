@@ -0,0 +1,44 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+func TestFormatNames(t *testing.T) {
+	f := filters.Where("message_field.stringField").StringEquals("a").
+		And(filters.Where("number_value_field").NumberSup(1).OrWhere("repeatedMessageField.bool_field").True())
+	s, err := f.Expr().FormatNames(md, true)
+	require.NoError(t, err)
+	assert.Equal(t, "messageField.stringField eq 'a' and (numberValueField sup 1 or repeatedMessageField.boolField is true)", s)
+	s, err = f.Expr().FormatNames(md, false)
+	require.NoError(t, err)
+	assert.Equal(t, "message_field.string_field eq 'a' and (number_value_field sup 1 or repeated_message_field.bool_field is true)", s)
+	// the original expression is not modified
+	assert.Equal(t, "message_field.stringField eq 'a' and (number_value_field sup 1 or repeatedMessageField.bool_field is true)", f.Expr().Format())
+
+	_, err = f.Expr().FormatNames(md.Fields().ByName("message_field").Message().Fields().ByName("time_value_field").Message(), true)
+	assert.Error(t, err)
+	_, err = filters.Where("string_field.value").Null().Expr().FormatNames(md, true)
+	assert.Error(t, err)
+}
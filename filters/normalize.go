@@ -0,0 +1,550 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// NormalizeOption configures Normalize
+type NormalizeOption func(n *normalizer)
+
+// WithMessage sets the descriptor of the messages the expression applies
+// to, so that the equality, in and range filters on the same singular
+// field are intersected, as they cannot be matched by different values.
+func WithMessage(md protoreflect.MessageDescriptor) NormalizeOption {
+	return func(n *normalizer) {
+		n.md = md
+	}
+}
+
+// Normalize returns the canonical form of the expression, matching the same
+// messages:
+//   - the nested and / or expressions are flattened
+//   - the duplicated expressions are removed
+//   - the equality and in filters on the same field of an or expression
+//     are merged into an in filter
+//   - with WithMessage, the filters on the same singular field of an and
+//     expression are intersected, the contradictory ones resulting in the
+//     constant false expression: an empty in filter on the field
+//   - the expressions are sorted, the field filters first, by field path
+//
+// An and expression of or expressions is distributed, like And does.
+// It returns nil if the expression matches all the messages.
+func Normalize(f FieldFilterer, opts ...NormalizeOption) *Expression {
+	if f == nil || f.Expr() == nil {
+		return nil
+	}
+	var n normalizer
+	for _, v := range opts {
+		v(&n)
+	}
+	return n.simplify(build(f.Expr())).expr()
+}
+
+// Equal reports whether the expressions have the same canonical form.
+func Equal(a, b FieldFilterer, opts ...NormalizeOption) bool {
+	x, y := Normalize(a, opts...), Normalize(b, opts...)
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return proto.Equal(x, y)
+}
+
+// Hash returns the hash of the canonical form of the expression, the Equal
+// expressions having the same hash.
+func Hash(f FieldFilterer, opts ...NormalizeOption) uint64 {
+	b, _ := Normalize(f, opts...).MarshalVT()
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// CanonicalFormat returns the formatted canonical form of the expression.
+func CanonicalFormat(f FieldFilterer, opts ...NormalizeOption) string {
+	return Normalize(f, opts...).Format()
+}
+
+type normalizer struct {
+	md protoreflect.MessageDescriptor
+}
+
+type nodeOp int
+
+const (
+	opLeaf nodeOp = iota
+	opAnd
+	opOr
+	opTrue
+	opFalse
+)
+
+// node is an expression as a tree of and / or nodes
+type node struct {
+	op nodeOp
+	// leaf is the field filter of the leaves, or the empty in filter of
+	// the constant false
+	leaf     *FieldFilter
+	children []*node
+	// key orders the nodes, the leaves first
+	key string
+}
+
+// build returns the tree of ((condition and and_exprs...) or or_exprs...)
+func build(expr *Expression) *node {
+	and := &node{op: opAnd}
+	if expr.Condition != nil {
+		and.children = append(and.children, &node{op: opLeaf, leaf: expr.Condition})
+	}
+	for _, v := range expr.AndExprs {
+		if v != nil {
+			and.children = append(and.children, build(v))
+		}
+	}
+	if len(expr.OrExprs) == 0 {
+		return and
+	}
+	or := &node{op: opOr, children: []*node{and}}
+	for _, v := range expr.OrExprs {
+		if v != nil {
+			or.children = append(or.children, build(v))
+		}
+	}
+	return or
+}
+
+func (n *node) expr() *Expression {
+	switch n.op {
+	case opTrue:
+		return nil
+	case opLeaf, opFalse:
+		return &Expression{Condition: n.leaf.CloneVT()}
+	}
+	var fs []FieldFilterer
+	for _, v := range n.children {
+		fs = append(fs, v.expr())
+	}
+	if n.op == opAnd {
+		return And(fs...)
+	}
+	return Or(fs...)
+}
+
+func (n *normalizer) simplify(t *node) *node {
+	switch t.op {
+	case opLeaf:
+		return n.leaf(t.leaf)
+	case opAnd, opOr:
+	default:
+		return t
+	}
+	var children []*node
+	for _, v := range t.children {
+		v = n.simplify(v)
+		if v.op == t.op {
+			children = append(children, v.children...)
+		} else {
+			children = append(children, v)
+		}
+	}
+	if t.op == opAnd {
+		return n.and(children)
+	}
+	return n.or(children)
+}
+
+func (n *normalizer) and(children []*node) *node {
+	var out []*node
+	var f *node
+	for _, v := range children {
+		switch v.op {
+		case opTrue:
+		case opFalse:
+			f = minFalse(f, v)
+		default:
+			out = append(out, v)
+		}
+	}
+	if f != nil {
+		return f
+	}
+	out = n.intersect(out)
+	for _, v := range out {
+		if v.op == opFalse {
+			f = minFalse(f, v)
+		}
+	}
+	if f != nil {
+		return f
+	}
+	out = sortNodes(out)
+	switch len(out) {
+	case 0:
+		return &node{op: opTrue}
+	case 1:
+		return out[0]
+	}
+	// the and expressions need a field filter as condition:
+	// (a or b) and c == (a and c) or (b and c)
+	if out[0].op != opLeaf {
+		var ors []*node
+		for _, v := range out[0].children {
+			ors = append(ors, &node{op: opAnd, children: append([]*node{v}, out[1:]...)})
+		}
+		return n.simplify(&node{op: opOr, children: ors})
+	}
+	return &node{op: opAnd, children: out, key: groupKey(opAnd, out)}
+}
+
+func (n *normalizer) or(children []*node) *node {
+	var out []*node
+	var f *node
+	for _, v := range children {
+		switch v.op {
+		case opTrue:
+			return v
+		case opFalse:
+			f = minFalse(f, v)
+		default:
+			out = append(out, v)
+		}
+	}
+	out = sortNodes(n.mergeIn(out))
+	switch len(out) {
+	case 0:
+		return f
+	case 1:
+		return out[0]
+	}
+	return &node{op: opOr, children: out, key: groupKey(opOr, out)}
+}
+
+// leaf returns the leaf of the field filter, whose in values are sorted,
+// the single value ones being equality filters, and the empty ones false
+func (n *normalizer) leaf(ff *FieldFilter) *node {
+	ff = ff.CloneVT()
+	empty := false
+	switch f := ff.GetFilter().GetMatch().(type) {
+	case *Filter_Number:
+		if in, ok := f.Number.GetCondition().(*NumberFilter_In_); ok {
+			vs := sortedSet(in.In.GetValues())
+			if len(vs) == 1 {
+				f.Number.Condition = &NumberFilter_Equals{Equals: vs[0]}
+			} else {
+				in.In = &NumberFilter_In{Values: vs}
+			}
+			empty = len(vs) == 0
+		}
+	case *Filter_String_:
+		if in, ok := f.String_.GetCondition().(*StringFilter_In_); ok {
+			vs := sortedSet(in.In.GetValues())
+			if len(vs) == 1 {
+				f.String_.Condition = &StringFilter_Equals{Equals: vs[0]}
+			} else {
+				in.In = &StringFilter_In{Values: vs}
+			}
+			empty = len(vs) == 0
+		}
+	}
+	t := &node{op: opLeaf, leaf: ff, key: leafKey(ff)}
+	// the negated filters on repeated fields never match
+	if empty && !ff.GetFilter().GetNot() {
+		t.op = opFalse
+	}
+	return t
+}
+
+// mergeIn merges the equality and in filters on the same field of an or
+// expression into in filters
+func (n *normalizer) mergeIn(nodes []*node) []*node {
+	type group struct {
+		i       int
+		numbers []float64
+		strings []string
+	}
+	groups := make(map[string]*group)
+	var out []*node
+	for _, v := range nodes {
+		if v.op != opLeaf || v.leaf.GetFilter().GetNot() {
+			out = append(out, v)
+			continue
+		}
+		var (
+			key     string
+			numbers []float64
+			strs    []string
+		)
+		switch f := v.leaf.Filter.GetMatch().(type) {
+		case *Filter_Number:
+			switch c := f.Number.GetCondition().(type) {
+			case *NumberFilter_Equals:
+				numbers = []float64{c.Equals}
+			case *NumberFilter_In_:
+				numbers = c.In.GetValues()
+			default:
+				out = append(out, v)
+				continue
+			}
+			key = "number\x00" + v.leaf.Field
+		case *Filter_String_:
+			switch c := f.String_.GetCondition().(type) {
+			case *StringFilter_Equals:
+				strs = []string{c.Equals}
+			case *StringFilter_In_:
+				strs = c.In.GetValues()
+			default:
+				out = append(out, v)
+				continue
+			}
+			key = "string\x00" + strconv.FormatBool(f.String_.GetCaseInsensitive()) + "\x00" + v.leaf.Field
+		default:
+			out = append(out, v)
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{i: len(out)}
+			groups[key] = g
+			out = append(out, v)
+		}
+		g.numbers = append(g.numbers, numbers...)
+		g.strings = append(g.strings, strs...)
+	}
+	for k, g := range groups {
+		ff := &FieldFilter{Field: out[g.i].leaf.Field}
+		if strings.HasPrefix(k, "number") {
+			ff.Filter = NumberIN(g.numbers...)
+		} else {
+			ff.Filter = StringIN(g.strings...)
+			ff.Filter.GetString_().CaseInsensitive = out[g.i].leaf.Filter.GetString_().GetCaseInsensitive()
+		}
+		out[g.i] = n.leaf(ff)
+	}
+	return out
+}
+
+// intersect intersects the equality, in and range filters on the same
+// singular field of an and expression
+func (n *normalizer) intersect(nodes []*node) []*node {
+	if n.md == nil {
+		return nodes
+	}
+	numbers := make(map[string]*interval[float64])
+	strs := make(map[string]*interval[string])
+	var fields []string
+	var out []*node
+	for _, v := range nodes {
+		if v.op != opLeaf || v.leaf.GetFilter().GetNot() || !n.singular(v.leaf.Field) {
+			out = append(out, v)
+			continue
+		}
+		field := v.leaf.Field
+		switch f := v.leaf.Filter.GetMatch().(type) {
+		case *Filter_Number:
+			r, ok := numbers[field]
+			if !ok {
+				r = &interval[float64]{}
+			}
+			switch c := f.Number.GetCondition().(type) {
+			case *NumberFilter_Equals:
+				r.in(c.Equals)
+			case *NumberFilter_In_:
+				r.in(c.In.GetValues()...)
+			case *NumberFilter_Sup:
+				r.sup(c.Sup)
+			case *NumberFilter_Inf:
+				r.inf(c.Inf)
+			default:
+				out = append(out, v)
+				continue
+			}
+			if !ok {
+				numbers[field] = r
+				fields = append(fields, "n"+field)
+			}
+		case *Filter_String_:
+			if f.String_.GetCaseInsensitive() {
+				out = append(out, v)
+				continue
+			}
+			r, ok := strs[field]
+			if !ok {
+				r = &interval[string]{}
+			}
+			switch c := f.String_.GetCondition().(type) {
+			case *StringFilter_Equals:
+				r.in(c.Equals)
+			case *StringFilter_In_:
+				r.in(c.In.GetValues()...)
+			case *StringFilter_Sup:
+				r.sup(c.Sup)
+			case *StringFilter_Inf:
+				r.inf(c.Inf)
+			default:
+				out = append(out, v)
+				continue
+			}
+			if !ok {
+				strs[field] = r
+				fields = append(fields, "s"+field)
+			}
+		default:
+			out = append(out, v)
+		}
+	}
+	for _, v := range fields {
+		kind, field := v[0], v[1:]
+		var fs []*Filter
+		if kind == 'n' {
+			fs = intervalFilters(numbers[field], NumberIN, NumberSup, NumberInf)
+		} else {
+			fs = intervalFilters(strs[field], StringIN, StringSup, StringInf)
+		}
+		for _, f := range fs {
+			out = append(out, n.leaf(&FieldFilter{Field: field, Filter: f}))
+		}
+	}
+	return out
+}
+
+// singular reports whether the field path resolves to a single value
+func (n *normalizer) singular(path string) bool {
+	md := n.md
+	for _, v := range strings.Split(path, ".") {
+		if md == nil || dynamicMessage(md) {
+			return false
+		}
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(v)
+		}
+		if fd == nil || fd.IsList() || fd.IsMap() {
+			return false
+		}
+		md = fd.Message()
+	}
+	// the google.protobuf.Value fields may hold lists
+	return md == nil || !dynamicMessage(md)
+}
+
+// dynamicMessage reports whether the fields paths continue in the message
+// values, e.g. in the google.protobuf.Any or Struct messages
+func dynamicMessage(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Any", "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue":
+		return true
+	}
+	return false
+}
+
+// interval is the intersection of the filters on a singular field
+type interval[T cmp.Ordered] struct {
+	set          []T
+	hasSet       bool
+	lo, hi       T
+	hasLo, hasHi bool
+}
+
+func (r *interval[T]) in(vs ...T) {
+	if !r.hasSet {
+		r.set, r.hasSet = sortedSet(vs), true
+		return
+	}
+	r.set = slices.DeleteFunc(r.set, func(v T) bool {
+		return !slices.Contains(vs, v)
+	})
+}
+
+func (r *interval[T]) sup(v T) {
+	if !r.hasLo || v > r.lo {
+		r.lo, r.hasLo = v, true
+	}
+}
+
+func (r *interval[T]) inf(v T) {
+	if !r.hasHi || v < r.hi {
+		r.hi, r.hasHi = v, true
+	}
+}
+
+// intervalFilters returns the filters matching the interval values, the
+// empty in filter if it is empty
+func intervalFilters[T cmp.Ordered](r *interval[T], in func(...T) *Filter, sup, inf func(T) *Filter) []*Filter {
+	if r.hasSet {
+		return []*Filter{in(slices.DeleteFunc(r.set, func(v T) bool {
+			return (r.hasLo && v <= r.lo) || (r.hasHi && v >= r.hi)
+		})...)}
+	}
+	if r.hasLo && r.hasHi && r.lo >= r.hi {
+		return []*Filter{in()}
+	}
+	var out []*Filter
+	if r.hasLo {
+		out = append(out, sup(r.lo))
+	}
+	if r.hasHi {
+		out = append(out, inf(r.hi))
+	}
+	return out
+}
+
+func sortedSet[T cmp.Ordered](vs []T) []T {
+	vs = slices.Clone(vs)
+	slices.Sort(vs)
+	return slices.Compact(vs)
+}
+
+func sortNodes(nodes []*node) []*node {
+	slices.SortFunc(nodes, func(a, b *node) int {
+		return strings.Compare(a.key, b.key)
+	})
+	return slices.CompactFunc(nodes, func(a, b *node) bool {
+		return a.key == b.key
+	})
+}
+
+// minFalse returns the constant false of the lowest key, so that its
+// representation is canonical
+func minFalse(a, b *node) *node {
+	if a == nil || b.key < a.key {
+		return b
+	}
+	return a
+}
+
+func leafKey(ff *FieldFilter) string {
+	b, _ := ff.MarshalVT()
+	return strconv.Itoa(int(opLeaf)) + ff.Field + "\x00" + ff.GetFilter().Format() + "\x00" + string(b)
+}
+
+func groupKey(op nodeOp, children []*node) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(op)))
+	for _, v := range children {
+		b.WriteString(strconv.Itoa(len(v.key)))
+		b.WriteByte(':')
+		b.WriteString(v.key)
+	}
+	return b.String()
+}
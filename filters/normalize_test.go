@@ -0,0 +1,121 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestNormalize(t *testing.T) {
+	a := filters.Where("string_field").StringEquals("a")
+	b := filters.Where("bool_field").True()
+	c := filters.Where("number_field").NumberSup(1)
+	d := filters.Where("enum_field").StringEquals("ONE")
+	withMessage := []filters.NormalizeOption{filters.WithMessage(md)}
+	tests := []struct {
+		name string
+		f    filters.FieldFilterer
+		opts []filters.NormalizeOption
+		want string
+	}{
+		{name: "nil", want: ""},
+		{name: "empty", f: &filters.Expression{}, want: ""},
+		{name: "single", f: a, want: "string_field eq 'a'"},
+		{name: "sort", f: filters.And(a, b), want: "bool_field is true and string_field eq 'a'"},
+		{name: "flatten", f: filters.And(filters.And(a, b), filters.And(c, d)), want: "bool_field is true and enum_field eq 'ONE' and number_field sup 1 and string_field eq 'a'"},
+		{name: "dedupe and", f: filters.And(a, b, a), want: "bool_field is true and string_field eq 'a'"},
+		{name: "dedupe or", f: filters.Or(b, filters.Or(c, b)), want: "bool_field is true or number_field sup 1"},
+		{name: "or of equals", f: filters.Or(a, b, filters.Where("string_field").StringIN("c", "b")), want: "bool_field is true or string_field in ('a', 'b', 'c')"},
+		{name: "or of equals numbers", f: filters.Where("number_field").NumberEquals(2).OrWhere("number_field").NumberEquals(1), want: "number_field in (1, 2)"},
+		{name: "or of equals case", f: filters.Or(a, filters.Where("string_field").StringIEquals("b")), want: "string_field eq 'a' or string_field ieq 'b'"},
+		{name: "or of not equals", f: filters.Or(a, filters.Where("string_field").StringNotEquals("b")), want: "string_field eq 'a' or string_field not eq 'b'"},
+		{name: "in", f: filters.Where("string_field").StringIN("b", "a", "b"), want: "string_field in ('a', 'b')"},
+		{name: "single in", f: filters.Where("string_field").StringIN("b", "b"), want: "string_field eq 'b'"},
+		{name: "ranges", f: filters.And(c, filters.Where("number_field").NumberInf(1)), want: "number_field inf 1 and number_field sup 1"},
+		{name: "contradictory ranges", f: filters.And(c, filters.Where("number_field").NumberInf(1)), opts: withMessage, want: "number_field in ()"},
+		{name: "intersected ranges", f: filters.And(c, filters.Where("number_field").NumberSup(2), filters.Where("number_field").NumberInf(5)), opts: withMessage, want: "number_field inf 5 and number_field sup 2"},
+		{name: "intersected in", f: filters.And(filters.Where("number_field").NumberIN(1, 2, 3, 4), filters.Where("number_field").NumberIN(2, 3, 4, 5), c, filters.Where("number_field").NumberInf(4)), opts: withMessage, want: "number_field in (2, 3)"},
+		{name: "contradictory equals", f: filters.And(a, filters.Where("string_field").StringEquals("b"), b), opts: withMessage, want: "string_field in ()"},
+		{name: "contradictory in or", f: filters.Or(filters.And(a, filters.Where("string_field").StringEquals("b")), b), opts: withMessage, want: "bool_field is true"},
+		{name: "repeated ranges", f: filters.And(filters.Where("repeated_string_field").StringSup("b"), filters.Where("repeated_string_field").StringInf("a")), opts: withMessage, want: "repeated_string_field inf 'a' and repeated_string_field sup 'b'"},
+		{name: "repeated message ranges", f: filters.And(filters.Where("repeated_message_field.number_field").NumberSup(2), filters.Where("repeated_message_field.number_field").NumberInf(1)), opts: withMessage, want: "repeated_message_field.number_field inf 1 and repeated_message_field.number_field sup 2"},
+		{name: "wrapper ranges", f: filters.And(filters.Where("number_value_field").NumberSup(2), filters.Where("number_value_field").NumberEquals(1)), opts: withMessage, want: "number_value_field in ()"},
+		{name: "and of or", f: filters.And(filters.Or(a, b), filters.Or(c, d)), want: "bool_field is true and (enum_field eq 'ONE' or number_field sup 1) or (string_field eq 'a' and (enum_field eq 'ONE' or number_field sup 1))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filters.Normalize(tt.f, tt.opts...)
+			assert.Equal(t, tt.want, got.Format())
+			assert.Equal(t, tt.want, filters.CanonicalFormat(tt.f, tt.opts...))
+			again := filters.Normalize(got, tt.opts...)
+			assert.True(t, proto.Equal(got, again), "not idempotent: %s", again.Format())
+			assert.True(t, filters.Equal(tt.f, got, tt.opts...))
+			assert.Equal(t, filters.Hash(tt.f, tt.opts...), filters.Hash(got, tt.opts...))
+		})
+	}
+}
+
+func TestNormalizeMatch(t *testing.T) {
+	msgs := []*test.Test{
+		{},
+		{StringField: "a", NumberField: 2, BoolField: true},
+		{StringField: "b", NumberField: 5, EnumField: test.Test_ONE, RepeatedStringField: []string{"a", "c"}},
+		{StringField: "c", NumberField: 1, NumberValueField: wrapperspb.Int64(3), RepeatedMessageField: []*test.Test{{NumberField: 1}, {NumberField: 3}}},
+	}
+	exprs := []filters.FieldFilterer{
+		filters.And(filters.Or(filters.Where("string_field").StringEquals("a"), filters.Where("bool_field").True()), filters.Or(filters.Where("number_field").NumberSup(1), filters.Where("enum_field").StringEquals("ONE"))),
+		filters.Or(filters.Where("string_field").StringEquals("a"), filters.Where("string_field").StringEquals("c"), filters.Where("number_field").NumberEquals(5)),
+		filters.And(filters.Where("number_field").NumberSup(1), filters.Where("number_field").NumberInf(3)),
+		filters.And(filters.Where("number_value_field").NumberSup(1), filters.Where("number_value_field").NumberInf(3)),
+		filters.And(filters.Where("repeated_string_field").StringSup("b"), filters.Where("repeated_string_field").StringInf("b")),
+		filters.And(filters.Where("repeated_message_field.number_field").NumberSup(2), filters.Where("repeated_message_field.number_field").NumberInf(2)),
+		filters.Or(filters.Where("repeated_string_field").StringEquals("c"), filters.Where("repeated_string_field").StringEquals("d")),
+		filters.Where("string_field").StringEquals("a").And(filters.Where("number_field").NumberIN(1, 2)).OrWhere("bool_field").False(),
+	}
+	m := protofilters.NewMatcher()
+	for _, e := range exprs {
+		n := filters.Normalize(e, filters.WithMessage(md))
+		for _, msg := range msgs {
+			want, err := m.Match(msg, e)
+			require.NoError(t, err)
+			got, err := m.Match(msg, n)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "%s => %s: %v", e.Expr().Format(), n.Format(), msg)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := filters.Where("string_field").StringEquals("a")
+	b := filters.Where("bool_field").True()
+	assert.True(t, filters.Equal(filters.And(a, b), filters.And(b, a)))
+	assert.True(t, filters.Equal(filters.Or(a, b), filters.Or(b, filters.Or(a, a))))
+	assert.False(t, filters.Equal(filters.And(a, b), filters.Or(a, b)))
+	assert.True(t, filters.Equal(nil, &filters.Expression{}))
+	assert.False(t, filters.Equal(nil, a))
+	assert.Equal(t, filters.Hash(filters.And(a, b)), filters.Hash(filters.And(b, a)))
+	assert.NotEqual(t, filters.Hash(filters.And(a, b)), filters.Hash(filters.Or(a, b)))
+}
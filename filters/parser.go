@@ -54,6 +54,7 @@ var caseInsensitiveOps = map[string]struct{}{
 	"eq":         {},
 	"has_prefix": {},
 	"has_suffix": {},
+	"contains":   {},
 	"matches":    {},
 	"in":         {},
 	"inf":        {},
@@ -145,7 +146,7 @@ func tokenize(input string) ([]token, error) {
 				if r == '\\' {
 					idx += w
 					if idx >= len(input) {
-						return nil, fmt.Errorf("filters: unterminated escape at %d", start)
+						return nil, &ParseError{Pos: start, Msg: "unterminated escape"}
 					}
 					r, w = utf8.DecodeRuneInString(input[idx:])
 					sb.WriteRune(r)
@@ -164,7 +165,7 @@ func tokenize(input string) ([]token, error) {
 			if closed {
 				continue
 			}
-			return nil, fmt.Errorf("filters: unterminated string literal at %d", start)
+			return nil, &ParseError{Pos: start, Msg: "unterminated string literal"}
 		case r == '(':
 			tokens = append(tokens, token{typ: tokenLParen, value: "(", pos: idx})
 			idx += w
@@ -196,7 +197,7 @@ func (p *parser) parseExpression() (*Expression, error) {
 		return nil, err
 	}
 	if expr == nil {
-		return nil, p.error(p.peek(), "expected expression")
+		return nil, p.expected(p.peek(), "expression")
 	}
 	return expr, nil
 }
@@ -256,7 +257,7 @@ func (p *parser) parsePrimary() (*Expression, error) {
 func (p *parser) parseFieldFilter() (*FieldFilter, error) {
 	tok := p.next()
 	if tok.typ != tokenWord {
-		return nil, p.error(tok, "expected field name")
+		return nil, p.expected(tok, "field name")
 	}
 	filter, err := p.parseFilter()
 	if err != nil {
@@ -278,7 +279,7 @@ func (p *parser) parseFilter() (*Filter, error) {
 	}
 	tok := p.next()
 	if tok.typ != tokenWord {
-		return nil, p.error(tok, "expected filter operator")
+		return nil, p.expected(tok, "filter operator")
 	}
 	lower := strings.ToLower(tok.value)
 	if lower == "is" {
@@ -292,6 +293,8 @@ func (p *parser) parseFilter() (*Filter, error) {
 		return p.parseStringFunc(ci, negated, func(val string) isStringFilter_Condition { return &StringFilter_HasPrefix{HasPrefix: val} })
 	case "has_suffix":
 		return p.parseStringFunc(ci, negated, func(val string) isStringFilter_Condition { return &StringFilter_HasSuffix{HasSuffix: val} })
+	case "contains":
+		return p.parseStringFunc(ci, negated, func(val string) isStringFilter_Condition { return &StringFilter_Contains{Contains: val} })
 	case "matches":
 		return p.parseStringFunc(ci, negated, func(val string) isStringFilter_Condition { return &StringFilter_Regex{Regex: val} })
 	case "in":
@@ -313,7 +316,7 @@ func (p *parser) parseFilter() (*Filter, error) {
 func (p *parser) parseIsFilter(negated bool) (*Filter, error) {
 	tok := p.next()
 	if tok.typ != tokenWord {
-		return nil, p.error(tok, "expected value after 'is'")
+		return nil, p.expected(tok, "value after 'is'")
 	}
 	switch strings.ToLower(tok.value) {
 	case "null":
@@ -330,7 +333,7 @@ func (p *parser) parseIsFilter(negated bool) (*Filter, error) {
 func (p *parser) parseStringFunc(ci, negated bool, builder func(string) isStringFilter_Condition) (*Filter, error) {
 	tok := p.next()
 	if tok.typ != tokenString {
-		return nil, p.error(tok, "expected quoted string value")
+		return nil, p.expected(tok, "quoted string value")
 	}
 	return makeStringFilter(ci, negated, builder(tok.value)), nil
 }
@@ -403,14 +406,14 @@ func (p *parser) parseIn(ci, negated bool) (*Filter, error) {
 	}
 	peek := p.peek()
 	if peek.typ == tokenRParen {
-		return nil, p.error(peek, "expected at least one value in 'in' clause")
+		return nil, p.expected(peek, "at least one value in 'in' clause")
 	}
 	if peek.typ == tokenString {
 		var values []string
 		for {
 			tok := p.next()
 			if tok.typ != tokenString {
-				return nil, p.error(tok, "expected quoted string value in 'in' clause")
+				return nil, p.expected(tok, "quoted string value in 'in' clause")
 			}
 			values = append(values, tok.value)
 			if p.peek().typ != tokenComma {
@@ -430,7 +433,7 @@ func (p *parser) parseIn(ci, negated bool) (*Filter, error) {
 	for {
 		tok := p.next()
 		if tok.typ != tokenWord {
-			return nil, p.error(tok, "expected number in 'in' clause")
+			return nil, p.expected(tok, "number in 'in' clause")
 		}
 		val, err := strconv.ParseFloat(tok.value, 64)
 		if err != nil {
@@ -451,7 +454,7 @@ func (p *parser) parseIn(ci, negated bool) (*Filter, error) {
 func (p *parser) parseTimeComparison(op string, negated bool) (*Filter, error) {
 	tok := p.next()
 	if tok.typ != tokenWord {
-		return nil, p.error(tok, "expected RFC3339 timestamp")
+		return nil, p.expected(tok, "RFC3339 timestamp")
 	}
 	ts, err := time.Parse(time.RFC3339, tok.value)
 	if err != nil {
@@ -581,7 +584,7 @@ func (p *parser) peekWord(word string) bool {
 func (p *parser) expectToken(tt tokenType) (token, error) {
 	tok := p.next()
 	if tok.typ != tt {
-		return token{}, p.error(tok, "expected %s", tokenTypeName(tt))
+		return token{}, p.expected(tok, tokenTypeName(tt))
 	}
 	return tok, nil
 }
@@ -595,8 +598,11 @@ func (p *parser) expectEOF() error {
 }
 
 func (p *parser) error(tok token, format string, args ...interface{}) error {
-	msg := fmt.Sprintf(format, args...)
-	return fmt.Errorf("filters: %s (at position %d)", msg, tok.pos)
+	return &ParseError{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expected(tok token, what string) error {
+	return &ParseError{Pos: tok.pos, Expected: what, Msg: "expected " + what}
 }
 
 func tokenTypeName(tt tokenType) string {
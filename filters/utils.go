@@ -163,6 +163,54 @@ func StringNotIHasSuffix(s string) *Filter {
 	)
 }
 
+// StringContains constructs a string contains substring filter
+func StringContains(s string) *Filter {
+	return newStringFilter(
+		&StringFilter{
+			Condition: &StringFilter_Contains{
+				Contains: s,
+			},
+		},
+	)
+}
+
+// StringNotContains constructs a string not contains substring filter
+func StringNotContains(s string) *Filter {
+	return newStringFilter(
+		&StringFilter{
+			Condition: &StringFilter_Contains{
+				Contains: s,
+			},
+		},
+		true,
+	)
+}
+
+// StringIContains constructs a case insensitive string contains substring filter
+func StringIContains(s string) *Filter {
+	return newStringFilter(
+		&StringFilter{
+			Condition: &StringFilter_Contains{
+				Contains: s,
+			},
+			CaseInsensitive: true,
+		},
+	)
+}
+
+// StringNotIContains constructs a case insensitive string not contains substring filter
+func StringNotIContains(s string) *Filter {
+	return newStringFilter(
+		&StringFilter{
+			Condition: &StringFilter_Contains{
+				Contains: s,
+			},
+			CaseInsensitive: true,
+		},
+		true,
+	)
+}
+
 // StringRegex constructs a string match regex filter
 func StringRegex(s string) *Filter {
 	return newStringFilter(
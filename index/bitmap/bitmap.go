@@ -17,11 +17,21 @@
 package bitmap
 
 import (
+	"errors"
+	"fmt"
 	"iter"
 )
 
 var p Provider
 
+// ErrProviderMismatch is returned when combining bitmaps created by
+// different providers.
+var ErrProviderMismatch = errors.New("bitmap: cannot combine bitmaps from different providers")
+
+// Global is the Provider delegating to the implementation set with
+// SetProvider, usually by importing one of the provider packages.
+var Global Provider = global{}
+
 func New() Bitmap {
 	if p == nil {
 		panic("no bitmap implementation imported")
@@ -36,7 +46,8 @@ func NewWith(n int) Bitmap {
 	return p.NewWith(n)
 }
 
-func NewFrom(buf []byte) Bitmap {
+// NewFrom decodes a bitmap serialized by any provider, see Decode.
+func NewFrom(buf []byte) (Bitmap, error) {
 	if p == nil {
 		panic("no bitmap implementation imported")
 	}
@@ -46,25 +57,69 @@ func NewFrom(buf []byte) Bitmap {
 type Provider interface {
 	New() Bitmap
 	NewWith(n int) Bitmap
-	NewFrom(buf []byte) Bitmap
+	// NewFrom decodes a bitmap serialized in any of the supported encodings.
+	NewFrom(buf []byte) (Bitmap, error)
 }
 
 type Bitmap interface {
 	Set(k uint64)
 	Remove(k uint64)
-	And(o Bitmap)
-	Or(o Bitmap)
+	// And, Or, AndNot and Xor return an error wrapping ErrProviderMismatch
+	// if o was not created by the same provider.
+	And(o Bitmap) error
+	Or(o Bitmap) error
+	// AndNot removes the UIDs contained in o.
+	AndNot(o Bitmap) error
+	// Xor keeps the UIDs contained in exactly one of the bitmaps.
+	Xor(o Bitmap) error
+	// Clone returns a copy of the bitmap.
+	Clone() Bitmap
 	Contains(k uint64) bool
 	Cardinality() uint64
+	// Min returns the smallest UID, or false if the bitmap is empty.
+	Min() (uint64, bool)
+	// Max returns the largest UID, or false if the bitmap is empty.
+	Max() (uint64, bool)
+	// Rank returns the number of UIDs lower than or equal to k.
+	Rank(k uint64) uint64
+	// Select returns the UID at the zero-based position i in ascending
+	// order, or false if i is out of range.
+	Select(i uint64) (uint64, bool)
+	// Bytes returns the bitmap serialized with its header, using the
+	// Roaring encoding.
 	Bytes() []byte
 	// Iter returns UIDs in ascending order.
 	Iter() iter.Seq[uint64]
+	// IterFrom returns UIDs greater than or equal to k in ascending order.
+	IterFrom(k uint64) iter.Seq[uint64]
+	// ReverseIter returns UIDs in descending order.
+	ReverseIter() iter.Seq[uint64]
 }
 
 type BitmapIterator interface {
 	Next() uint64
 }
 
+// SetProvider sets the implementation used by Global.
 func SetProvider(prv Provider) {
 	p = prv
 }
+
+// Mismatch returns the error reported when combining a with o.
+func Mismatch(a, o Bitmap) error {
+	return fmt.Errorf("%w: %T and %T", ErrProviderMismatch, a, o)
+}
+
+type global struct{}
+
+func (global) New() Bitmap {
+	return New()
+}
+
+func (global) NewWith(n int) Bitmap {
+	return NewWith(n)
+}
+
+func (global) NewFrom(buf []byte) (Bitmap, error) {
+	return NewFrom(buf)
+}
@@ -0,0 +1,227 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package bitmaptest provides a conformance test suite for bitmap providers.
+package bitmaptest
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/index/bitmap"
+)
+
+// foreign is a bitmap from another provider.
+type foreign struct {
+	bitmap.Bitmap
+}
+
+func legacy(vs []uint64) []byte {
+	buf := make([]byte, 0, 8*len(vs))
+	for _, v := range vs {
+		buf = binary.LittleEndian.AppendUint64(buf, v)
+	}
+	return buf
+}
+
+// Run checks that the provider's bitmaps behave like sorted sets of UIDs.
+func Run(t *testing.T, p bitmap.Provider) {
+	r := rand.New(rand.NewSource(42))
+	gen := func(n int, max uint64) []uint64 {
+		m := map[uint64]struct{}{}
+		for len(m) < n {
+			m[1+uint64(r.Int63n(int64(max)))] = struct{}{}
+		}
+		var out []uint64
+		for k := range m {
+			out = append(out, k)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+		return out
+	}
+	from := func(vs []uint64) bitmap.Bitmap {
+		b := p.New()
+		for _, v := range vs {
+			b.Set(v)
+		}
+		return b
+	}
+	collect := func(b bitmap.Bitmap) []uint64 {
+		var out []uint64
+		for v := range b.Iter() {
+			out = append(out, v)
+		}
+		return out
+	}
+	set := func(vs []uint64) map[uint64]bool {
+		m := map[uint64]bool{}
+		for _, v := range vs {
+			m[v] = true
+		}
+		return m
+	}
+	filter := func(a, b []uint64, keep func(inA, inB bool) bool) []uint64 {
+		sa, sb := set(a), set(b)
+		var out []uint64
+		for _, v := range append(slices.Clone(a), b...) {
+			if keep(sa[v], sb[v]) && !slices.Contains(out, v) {
+				out = append(out, v)
+			}
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	// values spread over several high keys to exercise multiple containers
+	a := gen(3000, 1<<34)
+	b := append(gen(2000, 1<<34), a[:500]...)
+	slices.Sort(b)
+	b = slices.Compact(b)
+
+	t.Run("set operations", func(t *testing.T) {
+		x := from(a)
+		require.NoError(t, x.And(from(b)))
+		assert.Equal(t, filter(a, b, func(x, y bool) bool { return x && y }), collect(x))
+		x = from(a)
+		require.NoError(t, x.Or(from(b)))
+		assert.Equal(t, filter(a, b, func(x, y bool) bool { return x || y }), collect(x))
+		x = from(a)
+		require.NoError(t, x.AndNot(from(b)))
+		assert.Equal(t, filter(a, b, func(x, y bool) bool { return x && !y }), collect(x))
+		x = from(a)
+		require.NoError(t, x.Xor(from(b)))
+		assert.Equal(t, filter(a, b, func(x, y bool) bool { return x != y }), collect(x))
+	})
+	t.Run("self operations", func(t *testing.T) {
+		x := from(a)
+		require.NoError(t, x.And(x))
+		assert.Equal(t, a, collect(x))
+		require.NoError(t, x.Or(x))
+		assert.Equal(t, a, collect(x))
+		require.NoError(t, x.AndNot(x))
+		assert.Empty(t, collect(x))
+		assert.Equal(t, uint64(0), x.Cardinality())
+		x = from(a)
+		require.NoError(t, x.Xor(x))
+		assert.Empty(t, collect(x))
+		assert.Equal(t, uint64(0), x.Cardinality())
+	})
+	t.Run("provider mismatch", func(t *testing.T) {
+		x := from(a)
+		o := foreign{Bitmap: from(b)}
+		for _, fn := range []func(bitmap.Bitmap) error{x.And, x.Or, x.AndNot, x.Xor} {
+			assert.ErrorIs(t, fn(o), bitmap.ErrProviderMismatch)
+		}
+		assert.Equal(t, a, collect(x))
+	})
+	t.Run("clone", func(t *testing.T) {
+		x := from(a)
+		c := x.Clone()
+		c.Remove(a[0])
+		c.Set(0)
+		assert.Equal(t, a, collect(x))
+		assert.True(t, c.Contains(0))
+		assert.False(t, c.Contains(a[0]))
+	})
+	t.Run("min max", func(t *testing.T) {
+		_, ok := p.New().Min()
+		assert.False(t, ok)
+		_, ok = p.New().Max()
+		assert.False(t, ok)
+		x := from(a)
+		v, ok := x.Min()
+		assert.True(t, ok)
+		assert.Equal(t, a[0], v)
+		v, ok = x.Max()
+		assert.True(t, ok)
+		assert.Equal(t, a[len(a)-1], v)
+	})
+	t.Run("rank select", func(t *testing.T) {
+		x := from(a)
+		assert.Equal(t, uint64(0), x.Rank(0))
+		assert.Equal(t, uint64(len(a)), x.Rank(^uint64(0)))
+		for i := 0; i < len(a); i += 97 {
+			assert.Equal(t, uint64(i+1), x.Rank(a[i]))
+			assert.Equal(t, uint64(i), x.Rank(a[i]-1))
+			v, ok := x.Select(uint64(i))
+			require.True(t, ok)
+			assert.Equal(t, a[i], v)
+		}
+		_, ok := x.Select(uint64(len(a)))
+		assert.False(t, ok)
+	})
+	t.Run("iterators", func(t *testing.T) {
+		x := from(append([]uint64{0}, a...))
+		assert.Equal(t, append([]uint64{0}, a...), collect(x))
+		var got []uint64
+		for v := range x.IterFrom(a[100]) {
+			got = append(got, v)
+		}
+		assert.Equal(t, a[100:], got)
+		got = nil
+		for v := range x.IterFrom(a[100] + 1) {
+			got = append(got, v)
+		}
+		assert.Equal(t, a[101:], got)
+		got = nil
+		for v := range x.ReverseIter() {
+			got = append(got, v)
+			if len(got) == 10 {
+				break
+			}
+		}
+		want := slices.Clone(a[len(a)-10:])
+		slices.Reverse(want)
+		assert.Equal(t, want, got)
+		got = nil
+		for v := range x.ReverseIter() {
+			got = append(got, v)
+		}
+		assert.Len(t, got, len(a)+1)
+		assert.Equal(t, uint64(0), got[len(got)-1])
+	})
+	t.Run("bytes", func(t *testing.T) {
+		// a dense range makes the roaring encoding use bitmap containers
+		dense := slices.Clone(a)
+		for i := uint64(1 << 40); i < 1<<40+10000; i++ {
+			dense = append(dense, i)
+		}
+		x := from(dense)
+		buf := x.Bytes()
+		e, _, err := bitmap.ReadHeader(buf)
+		require.NoError(t, err)
+		assert.Equal(t, bitmap.Roaring, e)
+		assert.Less(t, len(buf), 8*len(dense))
+		for _, buf := range [][]byte{buf, bitmap.Encode(x, bitmap.Raw), bitmap.Encode(x, bitmap.Roaring), legacy(dense)} {
+			y, err := p.NewFrom(buf)
+			require.NoError(t, err)
+			assert.Equal(t, dense, collect(y))
+		}
+		y, err := p.NewFrom(nil)
+		require.NoError(t, err)
+		assert.Zero(t, y.Cardinality())
+		_, err = p.NewFrom(buf[:len(buf)-1])
+		assert.ErrorIs(t, err, bitmap.ErrCorrupted)
+		_, err = p.NewFrom(append([]byte(bitmap.Magic), bitmap.Version+1, 0))
+		assert.ErrorIs(t, err, bitmap.ErrUnsupportedVersion)
+	})
+}
@@ -0,0 +1,315 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package bitmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"math/bits"
+)
+
+// Encoding identifies the serialization format of a bitmap.
+type Encoding byte
+
+const (
+	// Raw encodes the UIDs as little-endian uint64s.
+	Raw Encoding = iota
+	// Roaring encodes the UIDs using the portable 64-bit roaring format,
+	// as read and written by the CRoaring and roaring64 libraries.
+	Roaring
+)
+
+const (
+	// Magic starts every serialized bitmap.
+	// Data without it is read as legacy Raw encoded bitmap.
+	Magic = "PFBM"
+	// Version is the current serialization format version.
+	Version    = 1
+	headerSize = len(Magic) + 2
+)
+
+var (
+	ErrUnsupportedVersion  = errors.New("bitmap: unsupported version")
+	ErrUnsupportedEncoding = errors.New("bitmap: unsupported encoding")
+	ErrCorrupted           = errors.New("bitmap: corrupted data")
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case Raw:
+		return "raw"
+	case Roaring:
+		return "roaring"
+	}
+	return fmt.Sprintf("encoding(%d)", byte(e))
+}
+
+// AppendHeader appends the serialization header for the encoding to buf.
+func AppendHeader(buf []byte, e Encoding) []byte {
+	return append(append(buf, Magic...), Version, byte(e))
+}
+
+// ReadHeader returns the encoding of the serialized bitmap and its payload.
+// Data without header is reported as Raw.
+func ReadHeader(buf []byte) (Encoding, []byte, error) {
+	if len(buf) < headerSize || string(buf[:len(Magic)]) != Magic {
+		return Raw, buf, nil
+	}
+	if v := buf[len(Magic)]; v != Version {
+		return 0, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, v)
+	}
+	e := Encoding(buf[len(Magic)+1])
+	if e != Raw && e != Roaring {
+		return 0, nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, e)
+	}
+	return e, buf[headerSize:], nil
+}
+
+// Encode serializes the bitmap using the given encoding.
+func Encode(b Bitmap, e Encoding) []byte {
+	if e == Raw {
+		buf := AppendHeader(make([]byte, 0, headerSize+8*int(b.Cardinality())), Raw)
+		for k := range b.Iter() {
+			buf = binary.LittleEndian.AppendUint64(buf, k)
+		}
+		return buf
+	}
+	return appendRoaring(AppendHeader(nil, Roaring), b.Iter())
+}
+
+// Decode calls fn with the UIDs of the serialized bitmap, whatever its
+// encoding.
+func Decode(buf []byte, fn func(k uint64)) error {
+	e, buf, err := ReadHeader(buf)
+	if err != nil {
+		return err
+	}
+	if e == Roaring {
+		return decodeRoaring(buf, fn)
+	}
+	if len(buf)%8 != 0 {
+		return fmt.Errorf("%w: raw length %d is not a multiple of 8", ErrCorrupted, len(buf))
+	}
+	for i := 0; i < len(buf); i += 8 {
+		fn(binary.LittleEndian.Uint64(buf[i:]))
+	}
+	return nil
+}
+
+// The portable 64-bit roaring format is a little-endian uint64 count of
+// buckets, each made of its uint32 high bits followed by a 32-bit roaring
+// bitmap of the low bits in the portable format described in
+// https://github.com/RoaringBitmap/RoaringFormatSpec.
+
+const (
+	serialCookieNoRun = 12346
+	serialCookie      = 12347
+	noOffsetThreshold = 4
+	arrayMaxSize      = 4096
+	bitmapWords       = 1 << 16 / 64
+)
+
+func appendRoaring(buf []byte, it iter.Seq[uint64]) []byte {
+	type bucket struct {
+		high uint32
+		lows []uint32
+	}
+	var buckets []bucket
+	for k := range it {
+		h := uint32(k >> 32)
+		if n := len(buckets); n == 0 || buckets[n-1].high != h {
+			buckets = append(buckets, bucket{high: h})
+		}
+		b := &buckets[len(buckets)-1]
+		b.lows = append(b.lows, uint32(k))
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(buckets)))
+	for _, b := range buckets {
+		buf = binary.LittleEndian.AppendUint32(buf, b.high)
+		buf = appendRoaring32(buf, b.lows)
+	}
+	return buf
+}
+
+func appendRoaring32(buf []byte, lows []uint32) []byte {
+	type container struct {
+		key  uint16
+		vals []uint16
+	}
+	var cs []container
+	for _, v := range lows {
+		k := uint16(v >> 16)
+		if n := len(cs); n == 0 || cs[n-1].key != k {
+			cs = append(cs, container{key: k})
+		}
+		c := &cs[len(cs)-1]
+		c.vals = append(c.vals, uint16(v))
+	}
+	start := len(buf)
+	buf = binary.LittleEndian.AppendUint32(buf, serialCookieNoRun)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(cs)))
+	for _, c := range cs {
+		buf = binary.LittleEndian.AppendUint16(buf, c.key)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(c.vals)-1))
+	}
+	offset := len(buf) - start + 4*len(cs)
+	for _, c := range cs {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(offset))
+		if len(c.vals) > arrayMaxSize {
+			offset += 8 * bitmapWords
+		} else {
+			offset += 2 * len(c.vals)
+		}
+	}
+	for _, c := range cs {
+		if len(c.vals) <= arrayMaxSize {
+			for _, v := range c.vals {
+				buf = binary.LittleEndian.AppendUint16(buf, v)
+			}
+			continue
+		}
+		var words [bitmapWords]uint64
+		for _, v := range c.vals {
+			words[v/64] |= 1 << (v % 64)
+		}
+		for _, w := range words {
+			buf = binary.LittleEndian.AppendUint64(buf, w)
+		}
+	}
+	return buf
+}
+
+func decodeRoaring(buf []byte, fn func(k uint64)) error {
+	if len(buf) < 8 {
+		return fmt.Errorf("%w: missing bucket count", ErrCorrupted)
+	}
+	n := binary.LittleEndian.Uint64(buf)
+	buf = buf[8:]
+	for i := uint64(0); i < n; i++ {
+		if len(buf) < 4 {
+			return fmt.Errorf("%w: missing bucket key", ErrCorrupted)
+		}
+		high := uint64(binary.LittleEndian.Uint32(buf)) << 32
+		read, err := decodeRoaring32(buf[4:], func(v uint32) {
+			fn(high | uint64(v))
+		})
+		if err != nil {
+			return err
+		}
+		buf = buf[4+read:]
+	}
+	return nil
+}
+
+// decodeRoaring32 decodes a 32-bit roaring bitmap and returns the number of
+// bytes read.
+func decodeRoaring32(buf []byte, fn func(v uint32)) (int, error) {
+	r := reader{buf: buf}
+	cookie := r.uint32()
+	var size int
+	var runs []byte
+	switch {
+	case cookie == serialCookieNoRun:
+		size = int(r.uint32())
+	case cookie&0xFFFF == serialCookie:
+		size = int(cookie>>16) + 1
+		runs = r.bytes((size + 7) / 8)
+	default:
+		return 0, fmt.Errorf("%w: invalid cookie %d", ErrCorrupted, cookie)
+	}
+	if r.err != nil || size > 1<<16 {
+		return 0, fmt.Errorf("%w: invalid header", ErrCorrupted)
+	}
+	keys := make([]uint16, size)
+	cards := make([]int, size)
+	for i := range keys {
+		keys[i] = r.uint16()
+		cards[i] = int(r.uint16()) + 1
+	}
+	if runs == nil || size >= noOffsetThreshold {
+		// offsets are redundant as the containers are stored sequentially
+		r.bytes(4 * size)
+	}
+	for i, key := range keys {
+		high := uint32(key) << 16
+		switch {
+		case runs != nil && runs[i/8]&(1<<(i%8)) != 0:
+			n := int(r.uint16())
+			for j := 0; j < n && r.err == nil; j++ {
+				start, length := uint32(r.uint16()), uint32(r.uint16())
+				for v := start; v <= start+length; v++ {
+					fn(high | v)
+				}
+			}
+		case cards[i] > arrayMaxSize:
+			for j := 0; j < bitmapWords && r.err == nil; j++ {
+				w := r.uint64()
+				for w != 0 {
+					fn(high | uint32(j*64+bits.TrailingZeros64(w)))
+					w &= w - 1
+				}
+			}
+		default:
+			for j := 0; j < cards[i] && r.err == nil; j++ {
+				fn(high | uint32(r.uint16()))
+			}
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+	return r.off, r.err
+}
+
+type reader struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || len(r.buf)-r.off < n {
+		r.err = fmt.Errorf("%w: unexpected end of data", ErrCorrupted)
+		return nil
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
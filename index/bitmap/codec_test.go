@@ -0,0 +1,89 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package bitmap
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/v2/roaring64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoaringPortableFormat(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	var vs []uint64
+	// sparse values, a dense bitmap container and a long run
+	for i := 0; i < 5000; i++ {
+		vs = append(vs, uint64(r.Int63n(1<<40)))
+	}
+	for i := uint64(1 << 33); i < 1<<33+20000; i += 3 {
+		vs = append(vs, i)
+	}
+	for i := uint64(1 << 50); i < 1<<50+100000; i++ {
+		vs = append(vs, i)
+	}
+	vs = append(vs, 0, 1<<64-1)
+	slices.Sort(vs)
+	vs = slices.Compact(vs)
+
+	decode := func(t *testing.T, buf []byte) []uint64 {
+		var out []uint64
+		require.NoError(t, decodeRoaring(buf, func(k uint64) { out = append(out, k) }))
+		return out
+	}
+
+	t.Run("read by roaring64", func(t *testing.T) {
+		buf := appendRoaring(nil, slices.Values(vs))
+		m := roaring64.New()
+		require.NoError(t, m.UnmarshalBinary(buf))
+		assert.Equal(t, vs, m.ToArray())
+		assert.Equal(t, vs, decode(t, buf))
+	})
+	t.Run("written by roaring64", func(t *testing.T) {
+		m := roaring64.BitmapOf(vs...)
+		for _, optimize := range []bool{false, true} {
+			if optimize {
+				m.RunOptimize()
+			}
+			buf, err := m.ToBytes()
+			require.NoError(t, err)
+			assert.Equal(t, vs, decode(t, buf))
+		}
+	})
+	t.Run("few run containers", func(t *testing.T) {
+		// below the offset header threshold
+		m := roaring64.BitmapOf()
+		m.AddRange(10, 5000)
+		m.RunOptimize()
+		buf, err := m.ToBytes()
+		require.NoError(t, err)
+		assert.Equal(t, m.ToArray(), decode(t, buf))
+	})
+	t.Run("header", func(t *testing.T) {
+		e, payload, err := ReadHeader([]byte{1, 2, 3})
+		require.NoError(t, err)
+		assert.Equal(t, Raw, e)
+		assert.Equal(t, []byte{1, 2, 3}, payload)
+		_, _, err = ReadHeader(append([]byte(Magic), Version, 42))
+		assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+		assert.NoError(t, Decode(AppendHeader(nil, Raw), nil))
+		assert.ErrorIs(t, Decode(append(AppendHeader(nil, Raw), 1), nil), ErrCorrupted)
+	})
+}
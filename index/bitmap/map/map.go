@@ -17,13 +17,15 @@
 package simplemap
 
 import (
-	"encoding/binary"
 	"iter"
 	"sort"
 
 	bitmap2 "go.linka.cloud/protofilters/index/bitmap"
 )
 
+// Provider creates map based bitmaps.
+var Provider bitmap2.Provider = prov{}
+
 var (
 	_ bitmap2.Provider = (*prov)(nil)
 	_ bitmap2.Bitmap   = (*bitmap)(nil)
@@ -43,12 +45,12 @@ func (prov) NewWith(size int) bitmap2.Bitmap {
 	}
 }
 
-func (prov) NewFrom(buf []byte) bitmap2.Bitmap {
-	m := make(map[uint64]struct{}, len(buf)/8)
-	for i := 0; i < len(buf); i += 8 {
-		m[binary.LittleEndian.Uint64(buf[i:])] = struct{}{}
+func (prov) NewFrom(buf []byte) (bitmap2.Bitmap, error) {
+	b := prov{}.New()
+	if err := bitmap2.Decode(buf, b.Set); err != nil {
+		return nil, err
 	}
-	return &bitmap{m: m}
+	return b, nil
 }
 
 type bitmap struct {
@@ -72,38 +74,118 @@ func (b *bitmap) Remove(k uint64) {
 	delete(b.m, k)
 }
 
-func (b *bitmap) And(other bitmap2.Bitmap) {
-	o := other.(*bitmap)
+func (b *bitmap) And(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
 	for k := range b.m {
 		if _, exists := o.m[k]; !exists {
 			delete(b.m, k)
 		}
 	}
+	return nil
 }
 
-func (b *bitmap) Or(other bitmap2.Bitmap) {
-	o := other.(*bitmap)
+func (b *bitmap) Or(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
 	for k := range o.m {
 		b.m[k] = struct{}{}
 	}
+	return nil
 }
 
-func (b *bitmap) Bytes() []byte {
-	buf := make([]byte, 8*len(b.m))
-	i := 0
+func (b *bitmap) AndNot(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	for k := range o.m {
+		delete(b.m, k)
+	}
+	return nil
+}
+
+func (b *bitmap) Xor(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	for k := range o.m {
+		if _, exists := b.m[k]; exists {
+			delete(b.m, k)
+		} else {
+			b.m[k] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (b *bitmap) Clone() bitmap2.Bitmap {
+	m := make(map[uint64]struct{}, len(b.m))
 	for k := range b.m {
-		binary.LittleEndian.PutUint64(buf[i:], k)
-		i += 8
+		m[k] = struct{}{}
 	}
-	return buf
+	return &bitmap{m: m}
 }
 
-func (b *bitmap) Iter() iter.Seq[uint64] {
+func (b *bitmap) Min() (uint64, bool) {
+	var out uint64
+	ok := false
+	for k := range b.m {
+		if !ok || k < out {
+			out, ok = k, true
+		}
+	}
+	return out, ok
+}
+
+func (b *bitmap) Max() (uint64, bool) {
+	var out uint64
+	ok := false
+	for k := range b.m {
+		if !ok || k > out {
+			out, ok = k, true
+		}
+	}
+	return out, ok
+}
+
+func (b *bitmap) Rank(k uint64) uint64 {
+	var n uint64
+	for v := range b.m {
+		if v <= k {
+			n++
+		}
+	}
+	return n
+}
+
+func (b *bitmap) Select(i uint64) (uint64, bool) {
+	if i >= uint64(len(b.m)) {
+		return 0, false
+	}
+	return b.keys()[i], true
+}
+
+func (b *bitmap) Bytes() []byte {
+	return bitmap2.Encode(b, bitmap2.Roaring)
+}
+
+func (b *bitmap) keys() []uint64 {
 	keys := make([]uint64, 0, len(b.m))
 	for k := range b.m {
 		keys = append(keys, k)
 	}
 	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func (b *bitmap) Iter() iter.Seq[uint64] {
+	keys := b.keys()
 	return func(yield func(uint64) bool) {
 		for _, k := range keys {
 			if !yield(k) {
@@ -113,6 +195,29 @@ func (b *bitmap) Iter() iter.Seq[uint64] {
 	}
 }
 
+func (b *bitmap) IterFrom(k uint64) iter.Seq[uint64] {
+	keys := b.keys()
+	keys = keys[sort.Search(len(keys), func(i int) bool { return keys[i] >= k }):]
+	return func(yield func(uint64) bool) {
+		for _, k := range keys {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func (b *bitmap) ReverseIter() iter.Seq[uint64] {
+	keys := b.keys()
+	return func(yield func(uint64) bool) {
+		for i := len(keys) - 1; i >= 0; i-- {
+			if !yield(keys[i]) {
+				return
+			}
+		}
+	}
+}
+
 func init() {
-	bitmap2.SetProvider(prov{})
+	bitmap2.SetProvider(Provider)
 }
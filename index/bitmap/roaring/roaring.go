@@ -17,7 +17,8 @@
 package roaring
 
 import (
-	"encoding/binary"
+	"bytes"
+	"fmt"
 	"iter"
 
 	"github.com/RoaringBitmap/roaring/v2/roaring64"
@@ -25,6 +26,9 @@ import (
 	bitmap2 "go.linka.cloud/protofilters/index/bitmap"
 )
 
+// Provider creates 64-bit roaring bitmaps.
+var Provider bitmap2.Provider = prov{}
+
 var (
 	_ bitmap2.Provider = (*prov)(nil)
 	_ bitmap2.Bitmap   = (*bitmap)(nil)
@@ -44,12 +48,22 @@ func (prov) NewWith(_ int) bitmap2.Bitmap {
 	}
 }
 
-func (prov) NewFrom(buf []byte) bitmap2.Bitmap {
+func (prov) NewFrom(buf []byte) (bitmap2.Bitmap, error) {
+	e, payload, err := bitmap2.ReadHeader(buf)
+	if err != nil {
+		return nil, err
+	}
 	m := roaring64.New()
-	for i := 0; i < len(buf); i += 8 {
-		m.Add(binary.LittleEndian.Uint64(buf[i:]))
+	if e != bitmap2.Roaring {
+		if err := bitmap2.Decode(buf, m.Add); err != nil {
+			return nil, err
+		}
+		return &bitmap{m: m}, nil
 	}
-	return &bitmap{m: m}
+	if err := m.UnmarshalBinary(payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", bitmap2.ErrCorrupted, err)
+	}
+	return &bitmap{m: m}, nil
 }
 
 type bitmap struct {
@@ -72,25 +86,88 @@ func (r *bitmap) Remove(k uint64) {
 	r.m.Remove(k)
 }
 
-func (r *bitmap) And(o bitmap2.Bitmap) {
-	other := o.(*bitmap)
+func (r *bitmap) And(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
 	r.m.And(other.m)
+	return nil
 }
 
-func (r *bitmap) Or(o bitmap2.Bitmap) {
-	other := o.(*bitmap)
+func (r *bitmap) Or(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
 	r.m.Or(other.m)
+	return nil
+}
+
+func (r *bitmap) AndNot(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
+	if other == r {
+		r.m.Clear()
+		return nil
+	}
+	r.m.AndNot(other.m)
+	return nil
+}
+
+func (r *bitmap) Xor(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
+	// roaring64 does not support xoring a bitmap with itself
+	if other == r {
+		r.m.Clear()
+		return nil
+	}
+	r.m.Xor(other.m)
+	return nil
+}
+
+func (r *bitmap) Clone() bitmap2.Bitmap {
+	return &bitmap{m: r.m.Clone()}
+}
+
+func (r *bitmap) Min() (uint64, bool) {
+	if r.m.IsEmpty() {
+		return 0, false
+	}
+	return r.m.Minimum(), true
+}
+
+func (r *bitmap) Max() (uint64, bool) {
+	if r.m.IsEmpty() {
+		return 0, false
+	}
+	return r.m.Maximum(), true
+}
+
+func (r *bitmap) Rank(k uint64) uint64 {
+	return r.m.Rank(k)
+}
+
+func (r *bitmap) Select(i uint64) (uint64, bool) {
+	if i >= r.m.GetCardinality() {
+		return 0, false
+	}
+	k, err := r.m.Select(i)
+	return k, err == nil
 }
 
 func (r *bitmap) Bytes() []byte {
-	buf := make([]byte, r.m.GetCardinality()*8)
-	it := r.m.Iterator()
-	i := 0
-	for it.HasNext() {
-		binary.LittleEndian.PutUint64(buf[i:], it.Next())
-		i += 8
+	buf := bytes.NewBuffer(bitmap2.AppendHeader(nil, bitmap2.Roaring))
+	if _, err := r.m.WriteTo(buf); err != nil {
+		// writing to a bytes.Buffer does not fail
+		panic(err)
 	}
-	return buf
+	return buf.Bytes()
 }
 
 func (r *bitmap) Iter() iter.Seq[uint64] {
@@ -104,6 +181,29 @@ func (r *bitmap) Iter() iter.Seq[uint64] {
 	}
 }
 
+func (r *bitmap) IterFrom(k uint64) iter.Seq[uint64] {
+	it := r.m.Iterator()
+	it.AdvanceIfNeeded(k)
+	return func(yield func(uint64) bool) {
+		for it.HasNext() {
+			if !yield(it.Next()) {
+				return
+			}
+		}
+	}
+}
+
+func (r *bitmap) ReverseIter() iter.Seq[uint64] {
+	it := r.m.ReverseIterator()
+	return func(yield func(uint64) bool) {
+		for it.HasNext() {
+			if !yield(it.Next()) {
+				return
+			}
+		}
+	}
+}
+
 func init() {
-	bitmap2.SetProvider(prov{})
+	bitmap2.SetProvider(Provider)
 }
@@ -17,7 +17,6 @@
 package set
 
 import (
-	"encoding/binary"
 	"iter"
 
 	"github.com/tidwall/btree"
@@ -25,6 +24,9 @@ import (
 	bitmap2 "go.linka.cloud/protofilters/index/bitmap"
 )
 
+// Provider creates B-tree set based bitmaps.
+var Provider bitmap2.Provider = prov{}
+
 var (
 	_ bitmap2.Provider = (*prov)(nil)
 	_ bitmap2.Bitmap   = (*bitmap)(nil)
@@ -44,14 +46,12 @@ func (prov) NewWith(_ int) bitmap2.Bitmap {
 	}
 }
 
-func (prov) NewFrom(buf []byte) bitmap2.Bitmap {
-	s := &btree.Set[uint64]{}
-	for i := 0; i < len(buf); i += 8 {
-		s.Insert(binary.LittleEndian.Uint64(buf[i:]))
-	}
-	return &bitmap{
-		s: s,
+func (prov) NewFrom(buf []byte) (bitmap2.Bitmap, error) {
+	b := prov{}.New()
+	if err := bitmap2.Decode(buf, b.Set); err != nil {
+		return nil, err
 	}
+	return b, nil
 }
 
 type bitmap struct {
@@ -74,31 +74,109 @@ func (b *bitmap) Remove(k uint64) {
 	b.s.Delete(k)
 }
 
-func (b *bitmap) And(other bitmap2.Bitmap) {
-	o := other.(*bitmap)
-	it := b.s.Iter()
-	for it.Next() {
+func (b *bitmap) And(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	// the tree cannot be modified while iterating
+	var remove []uint64
+	for it := b.s.Iter(); it.Next(); {
 		if !o.s.Contains(it.Key()) {
-			b.s.Delete(it.Key())
+			remove = append(remove, it.Key())
 		}
 	}
+	for _, k := range remove {
+		b.s.Delete(k)
+	}
+	return nil
 }
 
-func (b *bitmap) Or(other bitmap2.Bitmap) {
-	o := other.(*bitmap)
+func (b *bitmap) Or(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	if o == b {
+		return nil
+	}
 	for it := o.s.Iter(); it.Next(); {
 		b.s.Insert(it.Key())
 	}
+	return nil
 }
 
-func (b *bitmap) Bytes() []byte {
-	buf := make([]byte, 8*b.s.Len())
-	i := 0
-	for it := b.s.Iter(); it.Next(); {
-		binary.LittleEndian.PutUint64(buf[i:], it.Key())
-		i += 8
+func (b *bitmap) AndNot(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	// the tree cannot be modified while iterating
+	if o == b {
+		b.s.Clear()
+		return nil
+	}
+	for it := o.s.Iter(); it.Next(); {
+		b.s.Delete(it.Key())
+	}
+	return nil
+}
+
+func (b *bitmap) Xor(other bitmap2.Bitmap) error {
+	o, ok := other.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(b, other)
+	}
+	// the tree cannot be modified while iterating
+	if o == b {
+		b.s.Clear()
+		return nil
+	}
+	for it := o.s.Iter(); it.Next(); {
+		if b.s.Contains(it.Key()) {
+			b.s.Delete(it.Key())
+		} else {
+			b.s.Insert(it.Key())
+		}
+	}
+	return nil
+}
+
+func (b *bitmap) Clone() bitmap2.Bitmap {
+	return &bitmap{s: b.s.Copy()}
+}
+
+func (b *bitmap) Min() (uint64, bool) {
+	return b.s.Min()
+}
+
+func (b *bitmap) Max() (uint64, bool) {
+	return b.s.Max()
+}
+
+func (b *bitmap) Rank(k uint64) uint64 {
+	// binary search of the first position holding a key greater than k
+	lo, hi := 0, b.s.Len()
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if v, _ := b.s.GetAt(m); v <= k {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return uint64(lo)
+}
+
+func (b *bitmap) Select(i uint64) (uint64, bool) {
+	if i >= uint64(b.s.Len()) {
+		return 0, false
 	}
-	return buf
+	return b.s.GetAt(int(i))
+}
+
+func (b *bitmap) Bytes() []byte {
+	return bitmap2.Encode(b, bitmap2.Roaring)
 }
 
 func (b *bitmap) Iter() iter.Seq[uint64] {
@@ -112,6 +190,18 @@ func (b *bitmap) Iter() iter.Seq[uint64] {
 	}
 }
 
+func (b *bitmap) IterFrom(k uint64) iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		b.s.Ascend(k, yield)
+	}
+}
+
+func (b *bitmap) ReverseIter() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		b.s.Reverse(yield)
+	}
+}
+
 func init() {
-	bitmap2.SetProvider(prov{})
+	bitmap2.SetProvider(Provider)
 }
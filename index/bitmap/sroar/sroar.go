@@ -17,7 +17,6 @@
 package sroar
 
 import (
-	"encoding/binary"
 	"iter"
 
 	"github.com/weaviate/sroar"
@@ -25,6 +24,9 @@ import (
 	bitmap2 "go.linka.cloud/protofilters/index/bitmap"
 )
 
+// Provider creates sroar (serialized roaring) bitmaps.
+var Provider bitmap2.Provider = prov{}
+
 var (
 	_ bitmap2.Provider = (*prov)(nil)
 	_ bitmap2.Bitmap   = (*bitmap)(nil)
@@ -44,12 +46,12 @@ func (prov) NewWith(n int) bitmap2.Bitmap {
 	}
 }
 
-func (prov) NewFrom(buf []byte) bitmap2.Bitmap {
-	m := sroar.NewBitmapWith(len(buf) / 8)
-	for i := 0; i < len(buf); i += 8 {
-		m.Set(binary.LittleEndian.Uint64(buf[i:]))
+func (prov) NewFrom(buf []byte) (bitmap2.Bitmap, error) {
+	b := prov{}.New()
+	if err := bitmap2.Decode(buf, b.Set); err != nil {
+		return nil, err
 	}
-	return &bitmap{m: m}
+	return b, nil
 }
 
 type bitmap struct {
@@ -72,30 +74,107 @@ func (r *bitmap) Remove(k uint64) {
 	r.m.Remove(k)
 }
 
-func (r *bitmap) And(o bitmap2.Bitmap) {
-	other := o.(*bitmap)
+func (r *bitmap) And(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
 	r.m.And(other.m)
+	return nil
 }
 
-func (r *bitmap) Or(o bitmap2.Bitmap) {
-	other := o.(*bitmap)
+func (r *bitmap) Or(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
 	r.m.Or(other.m)
+	return nil
 }
 
-func (r *bitmap) Bytes() []byte {
-	buf := make([]byte, r.m.GetCardinality()*8)
-	i := 0
-	for j := range r.Iter() {
-		binary.LittleEndian.PutUint64(buf[i:], j)
-		i += 8
+func (r *bitmap) AndNot(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
+	r.m.AndNot(other.m)
+	return nil
+}
+
+func (r *bitmap) Xor(o bitmap2.Bitmap) error {
+	other, ok := o.(*bitmap)
+	if !ok {
+		return bitmap2.Mismatch(r, o)
+	}
+	common := r.m.Clone().And(other.m)
+	r.m.Or(other.m).AndNot(common)
+	return nil
+}
+
+func (r *bitmap) Clone() bitmap2.Bitmap {
+	return &bitmap{m: r.m.Clone()}
+}
+
+func (r *bitmap) Min() (uint64, bool) {
+	if r.m.IsEmpty() {
+		return 0, false
 	}
-	return buf
+	return r.m.Minimum(), true
+}
+
+func (r *bitmap) Max() (uint64, bool) {
+	if r.m.IsEmpty() {
+		return 0, false
+	}
+	return r.m.Maximum(), true
+}
+
+// Rank is a binary search over Select, which is linear in the number of
+// containers: sroar only ranks the contained values.
+func (r *bitmap) Rank(k uint64) uint64 {
+	// binary search the first position holding a key greater than k
+	lo, hi := 0, r.m.GetCardinality()
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if v, _ := r.m.Select(uint64(m)); v <= k {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return uint64(lo)
+}
+
+// Select is linear in the number of containers.
+func (r *bitmap) Select(i uint64) (uint64, bool) {
+	if i >= uint64(r.m.GetCardinality()) {
+		return 0, false
+	}
+	k, err := r.m.Select(i)
+	return k, err == nil
+}
+
+func (r *bitmap) Bytes() []byte {
+	return bitmap2.Encode(r, bitmap2.Roaring)
 }
 
 func (r *bitmap) Iter() iter.Seq[uint64] {
+	return r.IterFrom(0)
+}
+
+// IterFrom skips the values lower than k from the start, in O(n): sroar
+// iterators cannot seek.
+func (r *bitmap) IterFrom(k uint64) iter.Seq[uint64] {
 	it := r.m.NewIterator()
+	n := r.m.GetCardinality()
 	return func(yield func(uint64) bool) {
-		for i := it.Next(); i > 0; i = it.Next() {
+		// the iterator returns 0 once exhausted, which is also a valid value,
+		// so the iteration is bounded by the cardinality
+		for ; n > 0; n-- {
+			i := it.Next()
+			if i < k {
+				continue
+			}
 			if !yield(i) {
 				return
 			}
@@ -103,6 +182,19 @@ func (r *bitmap) Iter() iter.Seq[uint64] {
 	}
 }
 
+// ReverseIter selects each value, each selection being linear in the number
+// of containers.
+func (r *bitmap) ReverseIter() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		for i := r.m.GetCardinality() - 1; i >= 0; i-- {
+			k, err := r.m.Select(uint64(i))
+			if err != nil || !yield(k) {
+				return
+			}
+		}
+	}
+}
+
 func init() {
-	bitmap2.SetProvider(prov{})
+	bitmap2.SetProvider(Provider)
 }
@@ -0,0 +1,221 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	preflect "go.linka.cloud/protofilters/reflect"
+)
+
+// CacheOptions configures the Find results cache.
+type CacheOptions struct {
+	// MaxEntries is the maximum number of cached results, the least
+	// recently used ones being evicted. It defaults to 128.
+	MaxEntries int
+	// MaxCardinality is the maximum number of UIDs of a cached result,
+	// the larger ones not being cached. Zero means no limit.
+	MaxCardinality uint64
+}
+
+// CacheStats are the Find results cache statistics.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	// Evictions is the number of results evicted by the size bounds.
+	Evictions uint64
+	// Invalidations is the number of results invalidated by the writes.
+	Invalidations uint64
+	Entries       int
+}
+
+// Cached is implemented by the indexes created with WithCache.
+type Cached interface {
+	CacheStats() CacheStats
+}
+
+// cache stores the Find results bitmaps keyed by message type and
+// canonical expression. The cached bitmaps are never modified.
+type cache struct {
+	opts    CacheOptions
+	names   preflect.NameMode
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// gen is incremented by the writes so that the results computed
+	// concurrently are not cached
+	gen   uint64
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	key   string
+	t     protoreflect.FullName
+	paths []string
+	b     bitmap.Bitmap
+}
+
+func newCache(o CacheOptions, names preflect.NameMode) *cache {
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = 128
+	}
+	return &cache{opts: o, names: names, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// cacheKey returns the cache key of the expression and the fields paths it
+// references, or an empty key if the expression cannot be cached
+func cacheKey(t protoreflect.FullName, expr *filters.Expression) (string, []string) {
+	n := filters.Normalize(expr)
+	if n == nil {
+		return "", nil
+	}
+	b, err := n.MarshalVT()
+	if err != nil {
+		return "", nil
+	}
+	paths := map[string]struct{}{}
+	var walk func(e *filters.Expression)
+	walk = func(e *filters.Expression) {
+		if e.Condition != nil {
+			paths[e.Condition.Field] = struct{}{}
+		}
+		for _, v := range e.AndExprs {
+			walk(v)
+		}
+		for _, v := range e.OrExprs {
+			walk(v)
+		}
+	}
+	walk(n)
+	out := make([]string, 0, len(paths))
+	for k := range paths {
+		out = append(out, k)
+	}
+	return string(t) + "\x00" + string(b), out
+}
+
+// generation returns the current generation, which must be read before
+// opening the transaction computing the result to cache
+func (c *cache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *cache) get(key string) (bitmap.Bitmap, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).b, true
+}
+
+// put caches the result computed at the generation gen, unless a write
+// happened since
+func (c *cache) put(key string, t protoreflect.FullName, paths []string, b bitmap.Bitmap, gen uint64) {
+	if c.opts.MaxCardinality > 0 && b.Cardinality() > c.opts.MaxCardinality {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).b = b
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, t: t, paths: paths, b: b})
+	for c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// invalidate removes the results of the message type described by md
+// referencing one of the written fields paths
+func (c *cache) invalidate(md protoreflect.MessageDescriptor, written []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if v := e.Value.(*cacheEntry); v.t == md.FullName() && referenced(md, c.names, v.paths, written) {
+			c.remove(e)
+			c.stats.Invalidations++
+		}
+		e = next
+	}
+}
+
+// invalidateUID removes the results containing the uid
+func (c *cache) invalidateUID(uid uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*cacheEntry).b.Contains(uid) {
+			c.remove(e)
+			c.stats.Invalidations++
+		}
+		e = next
+	}
+}
+
+func (c *cache) statistics() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	return s
+}
+
+// referenced reports whether one of the written fields paths is one of the
+// paths, matches one of its wildcards, or is a parent or child of one of
+// them, e.g. a Struct key or a nested message field. The wildcards elements
+// are resolved with mode, as when the paths are found.
+func referenced(md protoreflect.MessageDescriptor, mode preflect.NameMode, paths, written []string) bool {
+	for _, p := range paths {
+		for _, w := range written {
+			if p == w || strings.HasPrefix(w, p+".") || strings.HasPrefix(p, w+".") {
+				return true
+			}
+			if preflect.IsPattern(p) && preflect.MatchPatternNames(md, p, w, mode) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	preflect "go.linka.cloud/protofilters/reflect"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ui := NewUID(nil, All, WithCache(CacheOptions{MaxEntries: 2}))
+	c, ok := ui.(Cached)
+	require.True(t, ok)
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "a", NumberField: 1}))
+	require.NoError(t, ui.Insert(ctx, 2, &test.Test{StringField: "b", NumberField: 2}))
+
+	find := func(f filters.FieldFilterer) []uint64 {
+		uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+		require.NoError(t, err)
+		return uids
+	}
+	str := filters.Where("string_field").StringIN("a", "b")
+	num := filters.Where("number_field").NumberSup(1)
+
+	assert.Equal(t, []uint64{1, 2}, find(str))
+	assert.Equal(t, CacheStats{Misses: 1, Entries: 1}, c.CacheStats())
+	// the equivalent expressions share the cached result
+	assert.Equal(t, []uint64{1, 2}, find(filters.Where("string_field").StringEquals("b").OrWhere("string_field").StringEquals("a")))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, c.CacheStats())
+	assert.Equal(t, []uint64{2}, find(num))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 2}, c.CacheStats())
+
+	// only the results referencing the updated fields are invalidated
+	require.NoError(t, ui.Update(ctx, 1, &test.Test{StringField: "a", NumberField: 1}, &test.Test{StringField: "a", NumberField: 3}))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Invalidations: 1, Entries: 1}, c.CacheStats())
+	assert.Equal(t, []uint64{1, 2}, find(str))
+	assert.Equal(t, []uint64{1, 2}, find(num))
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 3, Invalidations: 1, Entries: 2}, c.CacheStats())
+
+	require.NoError(t, ui.Insert(ctx, 3, &test.Test{StringField: "a"}))
+	assert.Equal(t, []uint64{1, 2, 3}, find(str))
+	assert.Equal(t, []uint64{1, 2}, find(num))
+
+	// the removal only invalidates the results containing the uid
+	require.NoError(t, ui.Remove(ctx, 3))
+	s := c.CacheStats()
+	assert.Equal(t, 1, s.Entries)
+	assert.Equal(t, []uint64{1, 2}, find(str))
+	assert.Equal(t, []uint64{1, 2}, find(num))
+	assert.Equal(t, s.Hits+1, c.CacheStats().Hits)
+
+	// the least recently used result is evicted
+	assert.Equal(t, []uint64{2}, find(filters.Where("string_field").StringEquals("b")))
+	s = c.CacheStats()
+	assert.Equal(t, uint64(1), s.Evictions)
+	assert.Equal(t, 2, s.Entries)
+	find(num)
+	assert.Equal(t, s.Hits+1, c.CacheStats().Hits)
+
+	// the wildcards and nested paths are invalidated by the matching fields
+	nested := filters.Where("message_field.*").StringEquals("x")
+	assert.Empty(t, find(nested))
+	require.NoError(t, ui.Insert(ctx, 4, &test.Test{MessageField: &test.Test{StringField: "x"}}))
+	assert.Equal(t, []uint64{4}, find(nested))
+
+	// the wildcards are resolved with the index names mode
+	ui = NewUID(nil, All, WithCache(CacheOptions{}), WithFieldNames(preflect.JSONNames))
+	deep := filters.Where("*.*.stringField").StringEquals("x")
+	assert.Empty(t, find(deep))
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{MessageField: &test.Test{MessageField: &test.Test{StringField: "x"}}}))
+	assert.Equal(t, []uint64{1}, find(deep))
+}
+
+func TestCacheMaxCardinality(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ui := NewUID(nil, All, WithCache(CacheOptions{MaxCardinality: 1}))
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "a"}))
+	require.NoError(t, ui.Insert(ctx, 2, &test.Test{StringField: "a"}))
+	for range 2 {
+		uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringEquals("a"), FindOptions{}))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1, 2}, uids)
+	}
+	assert.Equal(t, CacheStats{Misses: 2}, ui.(Cached).CacheStats())
+	assert.Equal(t, CacheStats{}, NewUID(nil, All).(Cached).CacheStats())
+}
+
+// snapshotTxer opens the transactions on the live store, or once on the
+// snapshot store, committing a write before returning
+type snapshotTxer struct {
+	live, snapshot UIDStore
+	write          func()
+}
+
+func (s *snapshotTxer) Tx(_ context.Context) (UIDTx, error) {
+	if w := s.write; w != nil {
+		s.write = nil
+		w()
+		return noopUIDTx{UIDStore: s.snapshot}, nil
+	}
+	return noopUIDTx{UIDStore: s.live}, nil
+}
+
+func TestCacheConcurrentWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	x := &snapshotTxer{live: newUIDStore(bitmap.Global), snapshot: newUIDStore(bitmap.Global)}
+	ui := newUIDFromTxer(x, All, WithCache(CacheOptions{}))
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "a"}))
+	require.NoError(t, NewUID(x.snapshot, All).Insert(ctx, 1, &test.Test{StringField: "a"}))
+
+	find := func() []uint64 {
+		uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringEquals("a"), FindOptions{}))
+		require.NoError(t, err)
+		return uids
+	}
+	// the tx does not see the write committed after it is opened
+	x.write = func() {
+		require.NoError(t, ui.Insert(ctx, 2, &test.Test{StringField: "a"}))
+	}
+	assert.Equal(t, []uint64{1}, find())
+	assert.Equal(t, 0, ui.(Cached).CacheStats().Entries)
+	assert.Equal(t, []uint64{1, 2}, find())
+}
+
+// cancelTxer opens the transactions on the store, calling cancel when they
+// are committed
+type cancelTxer struct {
+	UIDStore
+	cancel context.CancelFunc
+}
+
+func (c *cancelTxer) Tx(_ context.Context) (UIDTx, error) {
+	return cancelTx{UIDTx: noopUIDTx{UIDStore: c.UIDStore}, cancel: c.cancel}, nil
+}
+
+type cancelTx struct {
+	UIDTx
+	cancel context.CancelFunc
+}
+
+func (c cancelTx) Commit(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return c.UIDTx.Commit(ctx)
+}
+
+func TestCacheCancelledWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	x := &cancelTxer{UIDStore: newUIDStore(bitmap.Global)}
+	fn := func(ctx context.Context, _ protoreflect.FullName, _ ...protoreflect.FieldDescriptor) (bool, error) {
+		return true, ctx.Err()
+	}
+	ui := newUIDFromTxer(x, fn, WithCache(CacheOptions{}))
+	find := func() []uint64 {
+		uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringEquals("a"), FindOptions{}))
+		require.NoError(t, err)
+		return uids
+	}
+	assert.Empty(t, find())
+
+	// the write context is cancelled once the write is committed
+	wctx, wcancel := context.WithCancel(ctx)
+	x.cancel = wcancel
+	require.NoError(t, ui.Insert(wctx, 1, &test.Test{StringField: "a"}))
+	assert.Equal(t, []uint64{1}, find())
+}
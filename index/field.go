@@ -25,6 +25,7 @@ import (
 	"google.golang.org/protobuf/reflect/protoreflect"
 
 	"go.linka.cloud/protofilters/index/bitmap"
+	preflect "go.linka.cloud/protofilters/reflect"
 )
 
 // Field is an indexed field in the index
@@ -43,10 +44,17 @@ type FieldReader interface {
 	Get(ctx context.Context, f protoreflect.Name) iter.Seq2[Field, error]
 }
 
-func newField(v protoreflect.Value, fds []protoreflect.FieldDescriptor) *field {
+// PathReader is implemented by the FieldReaders able to list the stored
+// fields paths, which is required to find the wildcard paths.
+type PathReader interface {
+	// Paths returns the stored fields paths
+	Paths(ctx context.Context) iter.Seq2[protoreflect.Name, error]
+}
+
+func newField(p bitmap.Provider, v protoreflect.Value, fds []protoreflect.FieldDescriptor) *field {
 	return &field{
 		value:       v,
-		bitmap:      bitmap.NewWith(1024),
+		bitmap:      p.NewWith(1024),
 		descriptors: fds,
 	}
 }
@@ -95,3 +103,57 @@ func (f *field) remove(k string) {
 func (f *field) removeUID(uid uint64) {
 	f.bitmap.Remove(uid)
 }
+
+// keyField describes the values of a google.protobuf.Struct key, or of a
+// google.protobuf.Value or ListValue field, as the Value kind field of
+// their values, e.g. string_value, named after the key or the field.
+type keyField struct {
+	protoreflect.FieldDescriptor
+	// field is the Struct, Value or ListValue field holding the values
+	field protoreflect.FieldDescriptor
+	name  protoreflect.Name
+}
+
+func (f *keyField) Name() protoreflect.Name {
+	return f.name
+}
+
+func (f *keyField) FullName() protoreflect.FullName {
+	return f.field.ContainingMessage().FullName().Append(f.name)
+}
+
+func (f *keyField) ContainingMessage() protoreflect.MessageDescriptor {
+	return f.field.ContainingMessage()
+}
+
+// Options returns the options of the field holding the values, so that
+// they apply to all its keys
+func (f *keyField) Options() protoreflect.ProtoMessage {
+	return f.field.Options()
+}
+
+// dynamicPath returns the descriptors of the values of kind fd at the keys
+// path in the google.protobuf.Struct, Value or ListValue field ending path.
+func dynamicPath(path []protoreflect.FieldDescriptor, keys []string, fd protoreflect.FieldDescriptor) []protoreflect.FieldDescriptor {
+	field := path[len(path)-1]
+	out := append([]protoreflect.FieldDescriptor(nil), path...)
+	if len(keys) == 0 {
+		out[len(out)-1] = &keyField{FieldDescriptor: fd, field: field, name: field.Name()}
+		return out
+	}
+	for _, v := range keys {
+		out = append(out, &keyField{FieldDescriptor: fd, field: field, name: protoreflect.Name(v)})
+	}
+	return out
+}
+
+func isDynamic(fd protoreflect.FieldDescriptor) bool {
+	if fd.Kind() != protoreflect.MessageKind {
+		return false
+	}
+	switch preflect.WKType(fd.Message().FullName()) {
+	case preflect.Struct, preflect.Value, preflect.ListValue:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldConfig is the indexing configuration of a field.
+type FieldConfig struct {
+	Equality bool
+	Range    bool
+	FullText bool
+}
+
+// Indexed reports whether the field values are indexed.
+func (c FieldConfig) Indexed() bool {
+	return c.Equality || c.Range || c.FullText
+}
+
+// ConfigFor returns the indexing configuration of the field path declared
+// in the schema using the (linka.cloud.protofilters.index) field option,
+// falling back to the (linka.cloud.protofilters.index_defaults) option of
+// the field's message.
+// A disabled field excludes all the fields below it, and an option without
+// kind indexes the field for equality.
+func ConfigFor(fds ...protoreflect.FieldDescriptor) FieldConfig {
+	if len(fds) == 0 {
+		return FieldConfig{}
+	}
+	for _, fd := range fds {
+		if o := FieldOptions(fd); o.GetDisabled() {
+			return FieldConfig{}
+		}
+	}
+	fd := fds[len(fds)-1]
+	o := FieldOptions(fd)
+	if o == nil {
+		o = MessageOptions(fd.ContainingMessage())
+	}
+	if o == nil || o.GetDisabled() {
+		return FieldConfig{}
+	}
+	if len(o.GetKind()) == 0 {
+		return FieldConfig{Equality: true}
+	}
+	var c FieldConfig
+	for _, k := range o.GetKind() {
+		switch k {
+		case IndexOptions_RANGE:
+			c.Range = true
+		case IndexOptions_FULLTEXT:
+			c.FullText = true
+		default:
+			c.Equality = true
+		}
+	}
+	return c
+}
+
+// FieldOptions returns the (linka.cloud.protofilters.index) option of the
+// field, or nil if not set.
+func FieldOptions(fd protoreflect.FieldDescriptor) *IndexOptions {
+	return extension(fd.Options(), E_Index)
+}
+
+// MessageOptions returns the (linka.cloud.protofilters.index_defaults) option
+// of the message, or nil if not set.
+func MessageOptions(md protoreflect.MessageDescriptor) *IndexOptions {
+	return extension(md.Options(), E_IndexDefaults)
+}
+
+func extension(opts proto.Message, xt protoreflect.ExtensionType) *IndexOptions {
+	if opts == nil || !proto.HasExtension(opts, xt) {
+		return nil
+	}
+	o, _ := proto.GetExtension(opts, xt).(*IndexOptions)
+	return o
+}
+
+// FromOptions is a Func indexing the fields according to their schema
+// options, see ConfigFor.
+func FromOptions(_ context.Context, _ protoreflect.FullName, fds ...protoreflect.FieldDescriptor) (bool, error) {
+	return ConfigFor(fds...).Indexed(), nil
+}
+
+// FullTextFromOptions is a Func selecting the FULLTEXT fields, meant to be
+// used with WithTrigrams:
+//
+//	NewUID(s, FromOptions, WithTrigrams(FullTextFromOptions))
+func FullTextFromOptions(_ context.Context, _ protoreflect.FullName, fds ...protoreflect.FieldDescriptor) (bool, error) {
+	return ConfigFor(fds...).FullText, nil
+}
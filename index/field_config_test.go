@@ -0,0 +1,102 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index"
+	_ "go.linka.cloud/protofilters/index/bitmap/sroar"
+	"go.linka.cloud/protofilters/tests/pb/indexed"
+)
+
+func TestConfigFor(t *testing.T) {
+	md := (&indexed.Indexed{}).ProtoReflect().Descriptor()
+	nmd := md.Fields().ByName("nested").Message()
+	path := func(names ...string) []protoreflect.FieldDescriptor {
+		var fds []protoreflect.FieldDescriptor
+		m := md
+		for _, v := range names {
+			fd := m.Fields().ByName(protoreflect.Name(v))
+			require.NotNil(t, fd, v)
+			fds = append(fds, fd)
+			m = fd.Message()
+		}
+		return fds
+	}
+	tests := []struct {
+		path []protoreflect.FieldDescriptor
+		want index.FieldConfig
+	}{
+		{path: path(indexed.IndexedFields.Name), want: index.FieldConfig{Equality: true, FullText: true}},
+		{path: path(indexed.IndexedFields.Count), want: index.FieldConfig{Range: true}},
+		{path: path(indexed.IndexedFields.Secret)},
+		{path: path(indexed.IndexedFields.Note), want: index.FieldConfig{Equality: true}},
+		{path: path(indexed.IndexedFields.Nested, indexed.Indexed_NestedFields.Value), want: index.FieldConfig{Equality: true}},
+		{path: path(indexed.IndexedFields.Nested, indexed.Indexed_NestedFields.Other)},
+		{path: path(indexed.IndexedFields.Skipped, indexed.IndexedFields.Name)},
+	}
+	for _, tt := range tests {
+		got := index.ConfigFor(tt.path...)
+		assert.Equal(t, tt.want, got, tt.path[len(tt.path)-1].FullName())
+		assert.Equal(t, tt.want.Indexed(), got.Indexed())
+	}
+	assert.NotNil(t, index.MessageOptions(md))
+	assert.Nil(t, index.MessageOptions(nmd))
+}
+
+func TestFromOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ui := index.NewUID(nil, index.FromOptions, index.WithTrigrams(index.FullTextFromOptions))
+	require.NoError(t, ui.Insert(ctx, 1, &indexed.Indexed{
+		Name:    "hello world",
+		Count:   42,
+		Secret:  "secret",
+		Note:    "note",
+		Nested:  &indexed.Indexed_Nested{Value: "value", Other: "other"},
+		Skipped: &indexed.Indexed{Name: "skipped"},
+	}))
+
+	tests := []struct {
+		f    filters.FieldFilterer
+		want []uint64
+	}{
+		{f: filters.Where(indexed.IndexedFields.Name).StringContains("world"), want: []uint64{1}},
+		{f: filters.Where(indexed.IndexedFields.Count).NumberSup(40), want: []uint64{1}},
+		{f: filters.Where(indexed.IndexedFields.Note).StringEquals("note"), want: []uint64{1}},
+		{f: filters.Where("nested.value").StringEquals("value"), want: []uint64{1}},
+		{f: filters.Where(indexed.IndexedFields.Secret).StringEquals("secret")},
+		{f: filters.Where("nested.other").StringEquals("other")},
+		{f: filters.Where("skipped.name").StringEquals("skipped")},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		for uid, err := range ui.Find(ctx, "linka.cloud.test.indexed.Indexed", tt.f, index.FindOptions{}) {
+			require.NoError(t, err)
+			got = append(got, uid)
+		}
+		assert.Equal(t, tt.want, got, tt.f.Expr().Format())
+	}
+}
@@ -47,14 +47,16 @@ type Index interface {
 }
 
 // New creates a compatibility key-based index backed by the UID index implementation.
-func New(s Store, fn Func) Index {
+func New(s Store, fn Func, opts ...Option) Index {
 	if fn == nil {
 		fn = All
 	}
+	limits := makeOptions(opts...).limits
 	if s == nil {
 		return &keyIndex{
-			uid:      NewUID(nil, fn),
+			uid:      NewUID(nil, fn, opts...),
 			resolver: newUIDKeys(),
+			limits:   limits,
 		}
 	}
 	x, ok := any(s).(Txer)
@@ -62,9 +64,10 @@ func New(s Store, fn Func) Index {
 		x = &fakeTxer{Store: s}
 	}
 	return &keyIndex{
-		uid:      newUIDFromTxer(uidTxer{Txer: x}, fn),
+		uid:      newUIDFromTxer(uidTxer{Txer: x}, fn, opts...),
 		store:    x,
 		resolver: newUIDKeys(),
+		limits:   limits,
 	}
 }
 
@@ -72,6 +75,7 @@ type keyIndex struct {
 	uid      UIDIndex
 	store    Txer
 	resolver *uidKeys
+	limits   filters.Limits
 }
 
 type uidKeys struct {
@@ -151,6 +155,15 @@ func (i *keyIndex) Remove(ctx context.Context, k string) error {
 	return nil
 }
 
+// CacheStats returns the Find results cache statistics, which are zero if
+// the cache is not enabled.
+func (i *keyIndex) CacheStats() CacheStats {
+	if c, ok := i.uid.(Cached); ok {
+		return c.CacheStats()
+	}
+	return CacheStats{}
+}
+
 func (i *keyIndex) Find(ctx context.Context, t protoreflect.FullName, f filters.FieldFilterer) ([]string, []string, error) {
 	var tx Tx
 	if i.store != nil {
@@ -164,7 +177,7 @@ func (i *keyIndex) Find(ctx context.Context, t protoreflect.FullName, f filters.
 
 	var keys []string
 	var collisions []string
-	for uid, err := range i.uid.Find(ctx, t, f, FindOptions{}) {
+	for uid, err := range i.uid.Find(ctx, t, f, FindOptions{Limits: i.limits}) {
 		if err != nil {
 			return nil, nil, err
 		}
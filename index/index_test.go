@@ -28,14 +28,23 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"go.linka.cloud/protofilters"
 	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	"go.linka.cloud/protofilters/index/bitmap/roaring"
+	"go.linka.cloud/protofilters/index/bitmap/set"
 	_ "go.linka.cloud/protofilters/index/bitmap/sroar"
+	preflect "go.linka.cloud/protofilters/reflect"
 	test "go.linka.cloud/protofilters/tests/pb"
 )
 
@@ -857,6 +866,39 @@ func TestUIDIndexFindOptions(t *testing.T) {
 	assert.Equal(t, []uint64{10, 5}, reversed)
 }
 
+func TestUIDIndexFindLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ui := NewUID(nil, All)
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "a"}))
+
+	var errs int
+	for _, err := range ui.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringIN("a", "b"), FindOptions{Limits: filters.Limits{MaxInLength: 1}}) {
+		assert.ErrorIs(t, err, filters.ErrLimitExceeded)
+		errs++
+	}
+	assert.Equal(t, 1, errs)
+	uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringIN("a", "b"), FindOptions{Limits: filters.Limits{MaxInLength: 2}}))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, uids)
+}
+
+func TestIndexFindLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	i := New(nil, All, WithLimits(filters.Limits{MaxInLength: 1}))
+	require.NoError(t, i.Insert(ctx, "1", &test.Test{StringField: "a"}))
+
+	_, _, err := i.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringIN("a", "b"))
+	var lerr *filters.LimitError
+	require.ErrorAs(t, err, &lerr)
+	keys, _, err := i.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringIN("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, keys)
+}
+
 func TestUIDIndexUpdateAndRemove(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -897,6 +939,27 @@ func TestUIDIndexFindEmptyFilter(t *testing.T) {
 	assert.Empty(t, uids)
 }
 
+func TestUIDIndexBitmapProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filter := filters.Where("string_field").StringEquals("value").OrWhere("number_field").NumberEquals(42)
+	for _, p := range []bitmap.Provider{roaring.Provider, set.Provider} {
+		ui := NewUID(nil, All, WithBitmapProvider(p))
+		require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "value"}))
+		require.NoError(t, ui.Insert(ctx, 2, &test.Test{NumberField: 42}))
+		require.NoError(t, ui.Insert(ctx, 3, &test.Test{StringField: "other"}))
+		uids, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filter, FindOptions{}))
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1, 2}, uids)
+	}
+
+	ui := NewUID(newUIDStore(set.Provider), All, WithBitmapProvider(roaring.Provider))
+	require.NoError(t, ui.Insert(ctx, 1, &test.Test{StringField: "value"}))
+	_, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", filter, FindOptions{}))
+	assert.ErrorIs(t, err, bitmap.ErrProviderMismatch)
+}
+
 func collectUIDs(seq iter.Seq2[uint64, error]) ([]uint64, error) {
 	var out []uint64
 	for uid, err := range seq {
@@ -922,3 +985,195 @@ func (c collisionStore) Keys(ctx context.Context, i uint64) ([]string, error) {
 	}
 	return ks, nil
 }
+
+func TestFieldNames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	i := New(nil, All, WithFieldNames(preflect.EitherNames))
+	require.NoError(t, i.Insert(ctx, "1", &test.Test{MessageField: &test.Test{StringField: "a"}}))
+	require.NoError(t, i.Insert(ctx, "2", &test.Test{MessageField: &test.Test{StringField: "b"}}))
+
+	for _, path := range []string{"messageField.stringField", "message_field.stringField", "message_field.string_field"} {
+		keys, _, err := i.Find(ctx, "linka.cloud.test.Test", filters.Where(path).StringEquals("a").OrWhere("messageField.string_field").StringEquals("c"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1"}, keys, path)
+	}
+	_, _, err := i.Find(ctx, "linka.cloud.test.Test", filters.Where("MessageField").Null())
+	assert.Error(t, err)
+
+	i = New(nil, All, WithFieldNames(preflect.JSONNames))
+	require.NoError(t, i.Insert(ctx, "1", &test.Test{StringField: "a"}))
+	keys, _, err := i.Find(ctx, "linka.cloud.test.Test", filters.Where("stringField").StringEquals("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, keys)
+	_, _, err = i.Find(ctx, "linka.cloud.test.Test", filters.Where("string_field").StringEquals("a"))
+	assert.Error(t, err)
+}
+
+func TestFindNot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ms := []*test.Test{
+		{StringField: "hello world", NumberField: 1, OptionalStringField: proto.String("a")},
+		{StringField: "goodbye", NumberField: 2, EnumField: test.Test_Type(42)},
+		{StringField: "world", NumberField: 3, RepeatedStringField: []string{"a", "b"}},
+		{NumberField: 4, MessageField: &test.Test{StringField: "world"}},
+	}
+	ui := NewUID(nil, All, WithTrigrams(All))
+	for i, m := range ms {
+		require.NoError(t, ui.Insert(ctx, uint64(i+1), m))
+	}
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringNotEquals("world"),
+		filters.Where("string_field").StringNotContains("wor"),
+		filters.Where("string_field").StringNotIN("goodbye", "world"),
+		filters.Where("number_field").NumberNotIN(1, 4),
+		filters.Where("optional_string_field").StringNotEquals("a"),
+		filters.Where("enum_field").StringNotEquals("ONE"),
+	}
+	for _, f := range fs {
+		t.Run(f.Expr().Format(), func(t *testing.T) {
+			var want []uint64
+			for i, m := range ms {
+				ok, err := protofilters.Match(m, f)
+				require.NoError(t, err)
+				if ok {
+					want = append(want, uint64(i+1))
+				}
+			}
+			got, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	i := New(nil, All)
+	require.NoError(t, i.Insert(ctx, "1", &test.Test{StringField: "a", MessageField: &test.Test{StringField: "b"}}))
+	require.NoError(t, i.Insert(ctx, "2", &test.Test{RepeatedMessageField: []*test.Test{{MessageField: &test.Test{StringField: "a"}}}}))
+	require.NoError(t, i.Insert(ctx, "3", &test.Test{NumberField: 1, MessageField: &test.Test{NumberField: 2}}))
+
+	tests := []struct {
+		f    filters.FieldFilterer
+		want []string
+	}{
+		{f: filters.Where("*").StringEquals("a"), want: []string{"1"}},
+		{f: filters.Where("*.string_field").StringEquals("b"), want: []string{"1"}},
+		{f: filters.Where("*.string_field").StringEquals("a")},
+		{f: filters.Where("**.string_field").StringEquals("a"), want: []string{"1", "2"}},
+		{f: filters.Where("**.number_field").NumberEquals(2), want: []string{"3"}},
+		{f: filters.Where("**").NumberSup(0), want: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.Expr().Format(), func(t *testing.T) {
+			keys, _, err := i.Find(ctx, "linka.cloud.test.Test", tt.f)
+			require.NoError(t, err)
+			sort.Strings(keys)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+
+	m := &test.Test{MessageField: &test.Test{MessageField: &test.Test{StringField: "deep"}}}
+	i = New(nil, All, WithFieldNames(preflect.JSONNames))
+	require.NoError(t, i.Insert(ctx, "1", m))
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{path: "*.*.stringField", want: []string{"1"}},
+		{path: "**.stringField", want: []string{"1"}},
+		{path: "messageField.*.stringField", want: []string{"1"}},
+		{path: "*.*.string_field"},
+		{path: "**.string_field"},
+	} {
+		f := filters.Where(tt.path).StringEquals("deep")
+		keys, _, err := i.Find(ctx, "linka.cloud.test.Test", f)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, keys, tt.path)
+		ok, err := protofilters.NewMatcher(protofilters.WithFieldNames(preflect.JSONNames)).Match(m, f)
+		require.NoError(t, err)
+		assert.Equal(t, len(tt.want) != 0, ok, tt.path)
+	}
+}
+
+func TestStruct(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	field := func(name string, number int32, typ string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typ),
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("metadata.proto"),
+		Package:    proto.String("linka.cloud.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:  proto.String("Metadata"),
+			Field: []*descriptorpb.FieldDescriptorProto{field("metadata", 1, ".google.protobuf.Struct"), field("attr", 2, ".google.protobuf.Value")},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	md := fd.Messages().Get(0)
+	newMsg := func(metadata map[string]any, attr any) proto.Message {
+		m := dynamicpb.NewMessage(md)
+		s, err := structpb.NewStruct(metadata)
+		require.NoError(t, err)
+		m.Set(md.Fields().ByName("metadata"), protoreflect.ValueOfMessage(s.ProtoReflect()))
+		v, err := structpb.NewValue(attr)
+		require.NoError(t, err)
+		m.Set(md.Fields().ByName("attr"), protoreflect.ValueOfMessage(v.ProtoReflect()))
+		return m
+	}
+
+	i := New(nil, All)
+	require.NoError(t, i.Insert(ctx, "1", newMsg(map[string]any{"region": "eu", "tags": []any{"a", "b"}, "nested": map[string]any{"zone": "z1"}}, "v")))
+	require.NoError(t, i.Insert(ctx, "2", newMsg(map[string]any{"region": 1, "none": nil}, 2)))
+	require.NoError(t, i.Update(ctx, "3", nil, newMsg(map[string]any{"region": "us"}, true)))
+
+	tests := []struct {
+		f    filters.FieldFilterer
+		want []string
+	}{
+		{f: filters.Where("metadata.region").StringEquals("eu"), want: []string{"1"}},
+		{f: filters.Where("metadata.region").StringHasSuffix("s"), want: []string{"3"}},
+		{f: filters.Where("metadata.region").NumberEquals(1), want: []string{"2"}},
+		{f: filters.Where("metadata.region").StringNotEquals("eu"), want: []string{"2", "3"}},
+		{f: filters.Where("metadata.tags").StringEquals("b"), want: []string{"1"}},
+		{f: filters.Where("metadata.nested.zone").StringEquals("z1"), want: []string{"1"}},
+		{f: filters.Where("metadata.none").Null(), want: []string{"2"}},
+		{f: filters.Where("attr").StringEquals("v"), want: []string{"1"}},
+		{f: filters.Where("attr").NumberSup(1), want: []string{"2"}},
+		{f: filters.Where("attr").True(), want: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.Expr().Format(), func(t *testing.T) {
+			keys, _, err := i.Find(ctx, md.FullName(), tt.f)
+			require.NoError(t, err)
+			sort.Strings(keys)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+
+	require.NoError(t, i.Update(ctx, "3", newMsg(map[string]any{"region": "us"}, true), newMsg(map[string]any{"region": "eu"}, true)))
+	keys, _, err := i.Find(ctx, md.FullName(), filters.Where("metadata.region").StringEquals("eu"))
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"1", "3"}, keys)
+	keys, _, err = i.Find(ctx, md.FullName(), filters.Where("metadata.region").StringEquals("us"))
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,91 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	preflect "go.linka.cloud/protofilters/reflect"
+)
+
+// Option configures an index.
+type Option func(o *options)
+
+type options struct {
+	trigrams Func
+	provider bitmap.Provider
+	names    preflect.NameMode
+	cache    *CacheOptions
+	limits   filters.Limits
+}
+
+// WithTrigrams enables a trigram index on the string fields selected by fn.
+// The trigram index narrows the distinct values that regex, contains,
+// prefix and suffix conditions are evaluated against. It keeps in memory
+// the distinct values of the selected fields with the UIDs holding them.
+// fn is called with the indexed message type and the field path.
+func WithTrigrams(fn Func) Option {
+	return func(o *options) {
+		o.trigrams = fn
+	}
+}
+
+// WithBitmapProvider sets the bitmap implementation used by the index,
+// e.g. roaring.Provider. It defaults to bitmap.Global.
+// A store provided to the index must create its bitmaps with the same
+// provider.
+func WithBitmapProvider(p bitmap.Provider) Option {
+	return func(o *options) {
+		o.provider = p
+	}
+}
+
+// WithFieldNames sets the names the filters field paths are resolved by.
+// It defaults to reflect.ProtoNames. The other modes require the indexed
+// message types to be registered in protoregistry.GlobalTypes.
+func WithFieldNames(mode preflect.NameMode) Option {
+	return func(o *options) {
+		o.names = mode
+	}
+}
+
+// WithCache enables a cache of the UIDIndex.Find results, keyed by message
+// type and canonical expression. The cached results are invalidated by the
+// writes touching the fields paths they reference, and by the removal of
+// the UIDs they contain.
+func WithCache(c CacheOptions) Option {
+	return func(o *options) {
+		o.cache = &c
+	}
+}
+
+// WithLimits bounds the filters passed to Index.Find, e.g. from untrusted
+// sources. Exceeding them returns a *filters.LimitError.
+// UIDIndex.Find is bounded by FindOptions.Limits.
+func WithLimits(l filters.Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+func makeOptions(opts ...Option) options {
+	o := options{provider: bitmap.Global}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
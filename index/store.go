@@ -25,6 +25,8 @@ import (
 
 	"github.com/cespare/xxhash/v2"
 	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/index/bitmap"
 )
 
 // Txer is an interface for a transactioner.
@@ -137,17 +139,29 @@ func (f *fieldReader) Get(_ context.Context, n protoreflect.Name) iter.Seq2[Fiel
 	}
 }
 
+func (f *fieldReader) Paths(_ context.Context) iter.Seq2[protoreflect.Name, error] {
+	return func(yield func(protoreflect.Name, error) bool) {
+		for n := range f.m {
+			if !yield(n, nil) {
+				return
+			}
+		}
+	}
+}
+
 func newStore() Store {
 	return &store{
 		fields:   make(map[protoreflect.FullName][]*field),
 		hashKeys: make(map[uint64][]string),
 		keyHash:  make(map[string]uint64),
+		provider: bitmap.Global,
 	}
 }
 
-func newUIDStore() UIDStore {
+func newUIDStore(p bitmap.Provider) UIDStore {
 	return &uidStore{
-		fields: make(map[protoreflect.FullName][]*field),
+		fields:   make(map[protoreflect.FullName][]*field),
+		provider: p,
 	}
 }
 
@@ -155,12 +169,14 @@ type store struct {
 	fields   map[protoreflect.FullName][]*field
 	hashKeys map[uint64][]string
 	keyHash  map[string]uint64
+	provider bitmap.Provider
 	m        sync.RWMutex
 }
 
 type uidStore struct {
-	fields map[protoreflect.FullName][]*field
-	m      sync.RWMutex
+	fields   map[protoreflect.FullName][]*field
+	provider bitmap.Provider
+	m        sync.RWMutex
 }
 
 type uidTxer struct {
@@ -233,7 +249,7 @@ func (s *store) Add(_ context.Context, k string, v protoreflect.Value, fds ...pr
 			return nil
 		}
 	}
-	fi := newField(v, fds)
+	fi := newField(s.provider, v, fds)
 	i := fi.add(k)
 	s.addIndex(k, i)
 	s.fields[n] = append(s.fields[n], fi)
@@ -311,7 +327,7 @@ func (s *uidStore) AddUID(_ context.Context, uid uint64, v protoreflect.Value, f
 			return nil
 		}
 	}
-	fi := newField(v, fds)
+	fi := newField(s.provider, v, fds)
 	fi.addUID(uid)
 	s.fields[n] = append(s.fields[n], fi)
 	return nil
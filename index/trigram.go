@@ -0,0 +1,468 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	preflect "go.linka.cloud/protofilters/reflect"
+)
+
+// trigramIndex keeps, for each indexed string field, the distinct values with
+// the UIDs holding them, and the posting lists of their lowercased byte
+// trigrams, so that the regex, contains, prefix and suffix conditions are
+// only evaluated against the values sharing their trigrams.
+// A field is loaded from the store the first time it is searched, and then
+// kept up to date by the committed writes: the values no longer held by any
+// UID are removed from the postings.
+type trigramIndex struct {
+	fn       Func
+	provider bitmap.Provider
+	mu       sync.Mutex
+	fields   map[trigramKey]*trigramField
+}
+
+type trigramKey struct {
+	t    protoreflect.FullName
+	path protoreflect.Name
+}
+
+type trigramField struct {
+	mu       sync.RWMutex
+	loaded   bool
+	enabled  bool
+	provider bitmap.Provider
+	// fd is the descriptor the values are matched with
+	fd     protoreflect.FieldDescriptor
+	ids    map[string]uint32
+	values map[uint32]*trigramValue
+	// next is the next value id: the ids are never reused so that the
+	// posting lists stay sorted
+	next  uint32
+	posts map[uint32][]uint32
+}
+
+type trigramValue struct {
+	s     string
+	value protoreflect.Value
+	uids  bitmap.Bitmap
+}
+
+// trigramUpdate is a write to apply to the trigram index once committed
+type trigramUpdate struct {
+	uid    uint64
+	value  protoreflect.Value
+	fds    []protoreflect.FieldDescriptor
+	remove bool
+}
+
+func newTrigramIndex(fn Func, p bitmap.Provider) *trigramIndex {
+	if fn == nil {
+		return nil
+	}
+	return &trigramIndex{fn: fn, provider: p, fields: make(map[trigramKey]*trigramField)}
+}
+
+func newTrigramField(p bitmap.Provider, fd protoreflect.FieldDescriptor) *trigramField {
+	return &trigramField{
+		loaded:   true,
+		enabled:  true,
+		provider: p,
+		fd:       fd,
+		ids:      make(map[string]uint32),
+		values:   make(map[uint32]*trigramValue),
+		posts:    make(map[uint32][]uint32),
+	}
+}
+
+// load returns the trigram field of the path, loading it from the store
+// the first time. It returns nil if the path has no value yet.
+func (x *trigramIndex) load(ctx context.Context, txer UIDTxer, t protoreflect.FullName, name protoreflect.Name) (*trigramField, error) {
+	k := trigramKey{t: t, path: name}
+	x.mu.Lock()
+	f, ok := x.fields[k]
+	if !ok {
+		f = &trigramField{}
+		x.fields[k] = f
+	}
+	x.mu.Unlock()
+	f.mu.RLock()
+	loaded := f.loaded
+	f.mu.RUnlock()
+	if loaded {
+		return f, nil
+	}
+	// the writes are not applied while loading, and the ones committed
+	// before are read from a new transaction
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loaded {
+		return f, nil
+	}
+	tx, err := txer.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	fr, err := tx.For(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	var n *trigramField
+	for v, err := range fr.Get(ctx, name) {
+		if err != nil {
+			return nil, err
+		}
+		s, ok := stringValue(v.Value())
+		if !ok {
+			continue
+		}
+		if n == nil {
+			fds := v.Descriptors()
+			if ok, err := x.fn(ctx, t, fds...); err != nil || !ok {
+				if err == nil {
+					f.loaded = true
+				}
+				return f, err
+			}
+			n = newTrigramField(x.provider, fds[len(fds)-1])
+		}
+		b, err := v.Bitmap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the store may keep the values no longer held
+		if b.Cardinality() == 0 {
+			continue
+		}
+		if err := n.value(s, v.Value()).uids.Or(b); err != nil {
+			return nil, err
+		}
+	}
+	if n == nil {
+		return nil, nil
+	}
+	f.loaded, f.enabled, f.provider, f.fd = true, true, n.provider, n.fd
+	f.ids, f.values, f.next, f.posts = n.ids, n.values, n.next, n.posts
+	return f, nil
+}
+
+// apply applies the committed writes to the loaded fields
+func (x *trigramIndex) apply(ups []trigramUpdate) {
+	if x == nil {
+		return
+	}
+	for _, v := range ups {
+		s, ok := stringValue(v.value)
+		if !ok || len(v.fds) == 0 {
+			continue
+		}
+		x.mu.Lock()
+		f := x.fields[trigramKey{t: v.fds[0].ContainingMessage().FullName(), path: joinFieldNames(v.fds)}]
+		x.mu.Unlock()
+		if f == nil {
+			continue
+		}
+		f.mu.Lock()
+		if f.loaded && f.enabled {
+			if v.remove {
+				f.remove(v.uid, s)
+			} else {
+				f.value(s, v.value).uids.Set(v.uid)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
+
+// removeUID removes the uid from all the loaded fields
+func (x *trigramIndex) removeUID(uid uint64) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	fs := make([]*trigramField, 0, len(x.fields))
+	for _, f := range x.fields {
+		fs = append(fs, f)
+	}
+	x.mu.Unlock()
+	for _, f := range fs {
+		f.mu.Lock()
+		if f.loaded && f.enabled {
+			for _, v := range f.values {
+				if v.uids.Contains(uid) {
+					f.remove(uid, v.s)
+				}
+			}
+		}
+		f.mu.Unlock()
+	}
+}
+
+// find adds to b the uids of the values of the field path matching the
+// filter, evaluating it only against the values sharing its trigrams.
+// It reports false if the filter or the field cannot use the trigrams.
+// If pattern is set, the path is skipped if the field does not accept the
+// filter.
+func (x *trigramIndex) find(ctx context.Context, txer UIDTxer, t protoreflect.FullName, name protoreflect.Name, f *filters.Filter, pattern bool, b bitmap.Bitmap) (bool, error) {
+	if x == nil || f == nil || f.Not {
+		return false, nil
+	}
+	q := stringFilterQuery(f.GetString_())
+	if q == nil || q.op == qAll {
+		return false, nil
+	}
+	tf, err := x.load(ctx, txer, t, name)
+	if err != nil || tf == nil {
+		return false, err
+	}
+	tf.mu.RLock()
+	defer tf.mu.RUnlock()
+	if !tf.enabled {
+		return false, nil
+	}
+	if pattern && !preflect.Accepts(tf.fd, f) {
+		return true, nil
+	}
+	ids, all := tf.evalQuery(q)
+	if all {
+		return false, nil
+	}
+	for _, id := range ids {
+		v := tf.values[id]
+		ok, err := matchIndexed(v.value, tf.fd, f)
+		if err != nil {
+			return true, preflect.WithPath(err, string(name))
+		}
+		if !ok {
+			continue
+		}
+		if err := b.Or(v.uids); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// value returns the value s, which is indexed if it is new
+func (f *trigramField) value(s string, value protoreflect.Value) *trigramValue {
+	if id, ok := f.ids[s]; ok {
+		return f.values[id]
+	}
+	id := f.next
+	f.next++
+	f.ids[s] = id
+	v := &trigramValue{s: s, value: value, uids: f.provider.New()}
+	f.values[id] = v
+	for _, t := range trigramsOf(s) {
+		f.posts[t] = append(f.posts[t], id)
+	}
+	return v
+}
+
+// remove removes the uid from the value s, which is removed from the
+// postings if no uid holds it anymore
+func (f *trigramField) remove(uid uint64, s string) {
+	id, ok := f.ids[s]
+	if !ok {
+		return
+	}
+	v := f.values[id]
+	v.uids.Remove(uid)
+	if v.uids.Cardinality() != 0 {
+		return
+	}
+	delete(f.ids, s)
+	delete(f.values, id)
+	for _, t := range trigramsOf(s) {
+		p := f.posts[t]
+		i := sort.Search(len(p), func(i int) bool { return p[i] >= id })
+		if i == len(p) || p[i] != id {
+			continue
+		}
+		if p = append(p[:i], p[i+1:]...); len(p) == 0 {
+			delete(f.posts, t)
+		} else {
+			f.posts[t] = p
+		}
+	}
+}
+
+// trigramsOf returns the distinct lowercased trigrams of s
+func trigramsOf(s string) []uint32 {
+	l := strings.ToLower(s)
+	var out []uint32
+	seen := make(map[uint32]struct{})
+	for i := 0; i+2 < len(l); i++ {
+		t := trigramOf(l[i : i+3])
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// evalQuery returns the sorted ids of the values that may match the query,
+// or true if all the values may match
+func (f *trigramField) evalQuery(q *trigramQuery) ([]uint32, bool) {
+	switch q.op {
+	case qAll:
+		return nil, true
+	case qNone:
+		return nil, false
+	case qAnd:
+		var out []uint32
+		all := true
+		for _, t := range q.trigram {
+			p := f.posts[trigramOf(t)]
+			if all {
+				out, all = append([]uint32(nil), p...), false
+			} else {
+				out = intersect(out, p)
+			}
+			if len(out) == 0 {
+				return nil, false
+			}
+		}
+		for _, s := range q.sub {
+			p, ok := f.evalQuery(s)
+			if ok {
+				continue
+			}
+			if all {
+				out, all = p, false
+			} else {
+				out = intersect(out, p)
+			}
+			if len(out) == 0 {
+				return nil, false
+			}
+		}
+		return out, all
+	default:
+		var out []uint32
+		for _, t := range q.trigram {
+			out = union(out, f.posts[trigramOf(t)])
+		}
+		for _, s := range q.sub {
+			p, all := f.evalQuery(s)
+			if all {
+				return nil, true
+			}
+			out = union(out, p)
+		}
+		return out, false
+	}
+}
+
+// stringFilterQuery returns the trigram query matching the superset of the
+// values accepted by the string filter, or nil if it has no usable condition.
+func stringFilterQuery(f *filters.StringFilter) *trigramQuery {
+	if f == nil {
+		return nil
+	}
+	switch c := f.GetCondition().(type) {
+	case *filters.StringFilter_Regex:
+		re, err := syntax.Parse(c.Regex, syntax.Perl)
+		if err != nil {
+			return nil
+		}
+		return regexpQuery(re)
+	case *filters.StringFilter_Contains:
+		return substringQuery(c.Contains)
+	case *filters.StringFilter_HasPrefix:
+		return substringQuery(c.HasPrefix)
+	case *filters.StringFilter_HasSuffix:
+		return substringQuery(c.HasSuffix)
+	}
+	return nil
+}
+
+func isStringField(fd protoreflect.FieldDescriptor) bool {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return true
+	case protoreflect.MessageKind:
+		return fd.Message().FullName() == "google.protobuf.StringValue"
+	}
+	return false
+}
+
+func stringValue(v protoreflect.Value) (string, bool) {
+	switch i := v.Interface().(type) {
+	case string:
+		return i, true
+	case protoreflect.Message:
+		if !i.IsValid() || i.Descriptor().FullName() != "google.protobuf.StringValue" {
+			return "", false
+		}
+		return i.Get(i.Descriptor().Fields().ByNumber(1)).String(), true
+	}
+	return "", false
+}
+
+func trigramOf(s string) uint32 {
+	return uint32(s[0])<<16 | uint32(s[1])<<8 | uint32(s[2])
+}
+
+func intersect(a, b []uint32) []uint32 {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func union(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
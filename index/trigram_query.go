@@ -0,0 +1,412 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The regexp analysis follows the approach described by Russ Cox in
+// "Regular Expression Matching with a Trigram Index": each sub-expression is
+// summarized by the sets of strings it may match exactly, start or end with,
+// and a boolean trigram query that any match must satisfy.
+// All strings are lowercased as the index stores lowercased trigrams.
+
+const (
+	maxExact = 7
+	maxSet   = 20
+)
+
+type queryOp int
+
+const (
+	qAll queryOp = iota
+	qNone
+	qAnd
+	qOr
+)
+
+// trigramQuery is a boolean query over trigrams: for qAnd all the trigrams
+// and sub-queries must match, for qOr at least one of them.
+type trigramQuery struct {
+	op      queryOp
+	trigram []string
+	sub     []*trigramQuery
+}
+
+var (
+	allQuery  = &trigramQuery{op: qAll}
+	noneQuery = &trigramQuery{op: qNone}
+)
+
+func (q *trigramQuery) and(r *trigramQuery) *trigramQuery {
+	return q.andOr(r, qAnd)
+}
+
+func (q *trigramQuery) or(r *trigramQuery) *trigramQuery {
+	return q.andOr(r, qOr)
+}
+
+func (q *trigramQuery) andOr(r *trigramQuery, op queryOp) *trigramQuery {
+	// the absorbing element of op and its identity
+	zero, one := noneQuery, allQuery
+	if op == qOr {
+		zero, one = allQuery, noneQuery
+	}
+	switch {
+	case q.op == zero.op || r.op == zero.op:
+		return zero
+	case q.op == one.op:
+		return r
+	case r.op == one.op:
+		return q
+	}
+	out := &trigramQuery{op: op}
+	for _, v := range []*trigramQuery{q, r} {
+		if v.op != op && (len(v.trigram) != 1 || len(v.sub) != 0) {
+			out.sub = append(out.sub, v)
+			continue
+		}
+		out.trigram = append(out.trigram, v.trigram...)
+		out.sub = append(out.sub, v.sub...)
+	}
+	out.trigram = stringSet(out.trigram).clean(false)
+	return out
+}
+
+// andTrigrams returns q AND (OR of the trigram sets of the strings).
+// Strings shorter than three bytes have no trigram and carry no information.
+func (q *trigramQuery) andTrigrams(t stringSet) *trigramQuery {
+	if t.minLen() < 3 {
+		return q
+	}
+	or := noneQuery
+	for _, s := range t {
+		var trig stringSet
+		for i := 0; i+2 < len(s); i++ {
+			trig = append(trig, s[i:i+3])
+		}
+		or = or.or(&trigramQuery{op: qAnd, trigram: trig.clean(false)})
+	}
+	return q.and(or)
+}
+
+// substringQuery returns the query matched by the strings containing s.
+func substringQuery(s string) *trigramQuery {
+	return allQuery.andTrigrams(stringSet{strings.ToLower(s)})
+}
+
+// regexpQuery returns the query matched by the strings the regexp may match.
+func regexpQuery(re *syntax.Regexp) *trigramQuery {
+	info := analyze(re.Simplify())
+	info.simplify(true)
+	info.addExact()
+	return info.match
+}
+
+type regexpInfo struct {
+	// canEmpty reports whether the regexp can match the empty string
+	canEmpty bool
+	// exact is the set of strings matched, nil if unknown
+	exact stringSet
+	// prefix and suffix are the possible prefixes and suffixes of the
+	// matched strings, used when exact is unknown
+	prefix stringSet
+	suffix stringSet
+	match  *trigramQuery
+}
+
+func anyMatch() regexpInfo {
+	return regexpInfo{canEmpty: true, prefix: stringSet{""}, suffix: stringSet{""}, match: allQuery}
+}
+
+func anyChar() regexpInfo {
+	return regexpInfo{prefix: stringSet{""}, suffix: stringSet{""}, match: allQuery}
+}
+
+func noMatch() regexpInfo {
+	return regexpInfo{match: noneQuery}
+}
+
+func emptyString() regexpInfo {
+	return regexpInfo{canEmpty: true, exact: stringSet{""}, match: allQuery}
+}
+
+func analyze(re *syntax.Regexp) (info regexpInfo) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return noMatch()
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return emptyString()
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			info = regexpInfo{exact: stringSet{strings.ToLower(string(re.Rune))}, match: allQuery}
+			break
+		}
+		info = emptyString()
+		for _, r := range re.Rune {
+			info = concat(info, foldRune(r))
+		}
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		return anyChar()
+	case syntax.OpCapture:
+		return analyze(re.Sub[0])
+	case syntax.OpConcat:
+		info = emptyString()
+		for _, sub := range re.Sub {
+			info = concat(info, analyze(sub))
+		}
+	case syntax.OpAlternate:
+		info = analyze(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			info = alternate(info, analyze(sub))
+		}
+	case syntax.OpQuest:
+		info = alternate(analyze(re.Sub[0]), emptyString())
+	case syntax.OpStar:
+		return anyMatch()
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return anyMatch()
+		}
+		fallthrough
+	case syntax.OpPlus:
+		// there is at least one occurrence, so the prefixes and suffixes
+		// stay the same, but the exact set is lost
+		info = analyze(re.Sub[0])
+		if info.exact != nil {
+			info.prefix = info.exact
+			info.suffix = append(stringSet(nil), info.exact...)
+			info.exact = nil
+		}
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return noMatch()
+		}
+		n := 0
+		for i := 0; i < len(re.Rune); i += 2 {
+			n += int(re.Rune[i+1] - re.Rune[i])
+		}
+		if n > 100 {
+			return anyChar()
+		}
+		info.match = allQuery
+		info.exact = stringSet{}
+		for i := 0; i < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				info.exact = append(info.exact, strings.ToLower(string(r)))
+			}
+		}
+	default:
+		return anyMatch()
+	}
+	info.simplify(false)
+	return info
+}
+
+// foldRune returns the info of the case-insensitive match of r.
+func foldRune(r rune) regexpInfo {
+	info := regexpInfo{match: allQuery, exact: stringSet{strings.ToLower(string(r))}}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		info.exact = append(info.exact, strings.ToLower(string(f)))
+	}
+	info.exact = info.exact.clean(false)
+	return info
+}
+
+func concat(x, y regexpInfo) regexpInfo {
+	var xy regexpInfo
+	xy.match = x.match.and(y.match)
+	if x.exact != nil && y.exact != nil {
+		xy.exact = x.exact.cross(y.exact)
+	} else {
+		if x.exact != nil {
+			xy.prefix = x.exact.cross(y.prefix)
+		} else {
+			xy.prefix = x.prefix
+			if x.canEmpty {
+				xy.prefix = xy.prefix.union(y.prefix, false)
+			}
+		}
+		if y.exact != nil {
+			xy.suffix = x.suffix.cross(y.exact)
+		} else {
+			xy.suffix = y.suffix
+			if y.canEmpty {
+				xy.suffix = xy.suffix.union(x.suffix, true)
+			}
+		}
+	}
+	// a trigram spanning the boundary of x and y must be present if all the
+	// strings of the cross product are long enough
+	if x.exact == nil && y.exact == nil &&
+		len(x.suffix) <= maxSet && len(y.prefix) <= maxSet &&
+		x.suffix.minLen()+y.prefix.minLen() >= 3 {
+		xy.match = xy.match.andTrigrams(x.suffix.cross(y.prefix))
+	}
+	xy.canEmpty = x.canEmpty && y.canEmpty
+	xy.simplify(false)
+	return xy
+}
+
+func alternate(x, y regexpInfo) regexpInfo {
+	var xy regexpInfo
+	switch {
+	case x.exact != nil && y.exact != nil:
+		xy.exact = x.exact.union(y.exact, false)
+	case x.exact != nil:
+		xy.prefix = x.exact.union(y.prefix, false)
+		xy.suffix = x.exact.union(y.suffix, true)
+		x.addExact()
+	case y.exact != nil:
+		xy.prefix = x.prefix.union(y.exact, false)
+		xy.suffix = x.suffix.union(y.exact, true)
+		y.addExact()
+	default:
+		xy.prefix = x.prefix.union(y.prefix, false)
+		xy.suffix = x.suffix.union(y.suffix, true)
+	}
+	xy.canEmpty = x.canEmpty || y.canEmpty
+	xy.match = x.match.or(y.match)
+	xy.simplify(false)
+	return xy
+}
+
+func (info *regexpInfo) addExact() {
+	if info.exact != nil {
+		info.match = info.match.andTrigrams(info.exact)
+	}
+}
+
+// simplify moves the exact set into the query when it becomes too large or
+// its strings too long, and trims the prefix and suffix sets.
+func (info *regexpInfo) simplify(force bool) {
+	if info.exact != nil {
+		info.exact = info.exact.clean(false)
+		if len(info.exact) > maxExact || info.exact.minLen() >= 4 || (force && info.exact.minLen() >= 3) {
+			info.addExact()
+			for _, s := range info.exact {
+				if len(s) < 3 {
+					info.prefix = append(info.prefix, s)
+					info.suffix = append(info.suffix, s)
+					continue
+				}
+				info.prefix = append(info.prefix, s[:2])
+				info.suffix = append(info.suffix, s[len(s)-2:])
+			}
+			info.exact = nil
+		}
+	}
+	if info.exact == nil {
+		info.prefix = info.simplifySet(info.prefix, false)
+		info.suffix = info.simplifySet(info.suffix, true)
+	}
+}
+
+func (info *regexpInfo) simplifySet(t stringSet, isSuffix bool) stringSet {
+	t = t.clean(isSuffix)
+	info.match = info.match.andTrigrams(t)
+	// keep only the strings' last (or first) n-1 bytes
+	for n := 3; n == 3 || len(t) > maxSet; n-- {
+		w := 0
+		for _, s := range t {
+			if len(s) >= n {
+				if isSuffix {
+					s = s[len(s)-n+1:]
+				} else {
+					s = s[:n-1]
+				}
+			}
+			if w == 0 || t[w-1] != s {
+				t[w] = s
+				w++
+			}
+		}
+		t = t[:w].clean(isSuffix)
+	}
+	// "ab" as a prefix makes "abc" redundant
+	has := strings.HasPrefix
+	if isSuffix {
+		has = strings.HasSuffix
+	}
+	w := 0
+	for _, s := range t {
+		if w == 0 || !has(s, t[w-1]) {
+			t[w] = s
+			w++
+		}
+	}
+	return t[:w]
+}
+
+// stringSet is a set of strings, kept sorted and deduplicated by clean.
+type stringSet []string
+
+func (s stringSet) clean(isSuffix bool) stringSet {
+	if isSuffix {
+		sort.Slice(s, func(i, j int) bool { return reverse(s[i]) < reverse(s[j]) })
+	} else {
+		sort.Strings(s)
+	}
+	w := 0
+	for _, v := range s {
+		if w == 0 || s[w-1] != v {
+			s[w] = v
+			w++
+		}
+	}
+	return s[:w]
+}
+
+func (s stringSet) minLen() int {
+	if len(s) == 0 {
+		return 0
+	}
+	m := len(s[0])
+	for _, v := range s[1:] {
+		m = min(m, len(v))
+	}
+	return m
+}
+
+func (s stringSet) union(t stringSet, isSuffix bool) stringSet {
+	out := append(append(stringSet{}, s...), t...)
+	return out.clean(isSuffix)
+}
+
+func (s stringSet) cross(t stringSet) stringSet {
+	out := stringSet{}
+	for _, a := range s {
+		for _, b := range t {
+			out = append(out, a+b)
+		}
+	}
+	return out.clean(false)
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,162 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/index/bitmap"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestTrigramRegexpQuery(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	const alphabet = "abcABC_-."
+	values := make([]string, 2000)
+	for i := range values {
+		b := make([]byte, r.Intn(12))
+		for j := range b {
+			b[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		values[i] = string(b)
+	}
+	values = append(values, "hello world", "Hello World", "say hello", "helo", "ſabc")
+	f := newTrigramField(bitmap.Global, nil)
+	for i, v := range values {
+		f.value(v, protoreflect.ValueOfString(v)).uids.Set(uint64(i))
+	}
+	tests := []struct {
+		re     string
+		narrow bool
+	}{
+		{re: "hello", narrow: true},
+		{re: "(?i)HELLO", narrow: true},
+		{re: "^hel+o", narrow: true},
+		{re: "abc", narrow: true},
+		{re: "(?i)sabc", narrow: true},
+		{re: "a.c"},
+		{re: "ab(c|a)b", narrow: true},
+		{re: "(abc|bca)+", narrow: true},
+		{re: "[ab]ca[bc]", narrow: true},
+		{re: "a[bB]c.*_", narrow: true},
+		{re: "ab?c"},
+		{re: "a*b*"},
+		{re: "x{2,}"},
+		{re: "abc|^c"},
+		{re: "(?s).*"},
+		{re: "[^a]bc"},
+		{re: "world$", narrow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.re, func(t *testing.T) {
+			re, err := syntax.Parse(tt.re, syntax.Perl)
+			require.NoError(t, err)
+			rx := regexp.MustCompile(tt.re)
+			ids, all := f.evalQuery(regexpQuery(re))
+			assert.Equal(t, !tt.narrow, all)
+			if all {
+				return
+			}
+			has := func(v string) bool {
+				_, ok := slices.BinarySearch(ids, f.ids[v])
+				return ok
+			}
+			for _, v := range values {
+				if rx.MatchString(v) {
+					assert.True(t, has(v), v)
+				}
+			}
+			assert.Less(t, len(ids), len(f.ids))
+		})
+	}
+}
+
+func TestTrigramFind(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values := []string{"hello world", "Hello there", "goodbye world", "help", "", "say HELLO"}
+	plain := NewUID(nil, All)
+	var trigrams []protoreflect.Name
+	ui := NewUID(nil, All, WithTrigrams(func(ctx context.Context, name protoreflect.FullName, fds ...protoreflect.FieldDescriptor) (bool, error) {
+		trigrams = append(trigrams, joinFieldNames(fds))
+		return name == "linka.cloud.test.Test", nil
+	}))
+	for i, v := range values {
+		m := &test.Test{StringField: v, StringValueField: wrapperspb.String(v), RepeatedStringField: []string{v, "x"}}
+		require.NoError(t, plain.Insert(ctx, uint64(i+1), m))
+		require.NoError(t, ui.Insert(ctx, uint64(i+1), m))
+	}
+	require.NoError(t, ui.Update(ctx, 2, &test.Test{StringField: "Hello there"}, &test.Test{StringField: "hello again"}))
+	require.NoError(t, plain.Update(ctx, 2, &test.Test{StringField: "Hello there"}, &test.Test{StringField: "hello again"}))
+
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringRegex("hel+o"),
+		filters.Where("string_field").StringRegex("(?i)^HELLO"),
+		filters.Where("string_field").StringRegex("wor|the"),
+		filters.Where("string_field").StringContains("ell"),
+		filters.Where("string_field").StringIContains("HELLO"),
+		filters.Where("string_field").StringNotContains("hello"),
+		filters.Where("string_field").StringHasPrefix("hello"),
+		filters.Where("string_field").StringHasSuffix("world"),
+		filters.Where("string_value_field").StringContains("there"),
+		filters.Where("repeated_string_field").StringRegex("bye"),
+	}
+	for _, f := range fs {
+		t.Run(f.Expr().Format(), func(t *testing.T) {
+			want, err := collectUIDs(plain.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+			require.NoError(t, err)
+			got, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+	assert.Contains(t, trigrams, protoreflect.Name("string_field"))
+	assert.NotContains(t, trigrams, protoreflect.Name("number_field"))
+
+	// the values no longer held are removed from the postings
+	tf := ui.(*uidIndex).trigrams.fields[trigramKey{t: "linka.cloud.test.Test", path: "string_field"}]
+	require.NotNil(t, tf)
+	assert.NotContains(t, tf.ids, "Hello there")
+	assert.Contains(t, tf.ids, "goodbye world")
+	require.NoError(t, ui.Remove(ctx, 3))
+	require.NoError(t, plain.Remove(ctx, 3))
+	assert.NotContains(t, tf.ids, "goodbye world")
+	ids, _ := tf.evalQuery(stringFilterQuery(filters.Where("string_field").StringContains("world").Expr().Condition.Filter.GetString_()))
+	assert.Len(t, ids, 1)
+	require.NoError(t, ui.Update(ctx, 1, &test.Test{StringField: "hello world"}, &test.Test{StringField: "goodbye world"}))
+	require.NoError(t, plain.Update(ctx, 1, &test.Test{StringField: "hello world"}, &test.Test{StringField: "goodbye world"}))
+	assert.Contains(t, tf.ids, "goodbye world")
+	for _, f := range fs {
+		want, err := collectUIDs(plain.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+		require.NoError(t, err)
+		got, err := collectUIDs(ui.Find(ctx, "linka.cloud.test.Test", f, FindOptions{}))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, f.Expr().Format())
+	}
+}
@@ -1,13 +1,13 @@
 package index
 
 import (
-	"container/heap"
 	"context"
+	"fmt"
 	"iter"
-	"sort"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 
 	"go.linka.cloud/protofilters/filters"
 	"go.linka.cloud/protofilters/index/bitmap"
@@ -18,6 +18,9 @@ type FindOptions struct {
 	Offset  uint64
 	Limit   uint64
 	Reverse bool
+	// Limits bounds the filters, e.g. from untrusted sources.
+	// Exceeding them returns a *filters.LimitError.
+	Limits filters.Limits
 }
 
 // UIDIndex is a protobuf message index keyed by UID.
@@ -29,33 +32,63 @@ type UIDIndex interface {
 }
 
 type uidIndex struct {
-	store UIDTxer
-	fn    Func
+	store    UIDTxer
+	fn       Func
+	trigrams *trigramIndex
+	provider bitmap.Provider
+	names    preflect.NameMode
+	cache    *cache
 }
 
 // NewUID creates a new UID index using the given store and index function.
-func NewUID(s UIDStore, fn Func) UIDIndex {
+func NewUID(s UIDStore, fn Func, opts ...Option) UIDIndex {
 	if fn == nil {
 		fn = All
 	}
 	if s == nil {
-		s = newUIDStore()
+		s = newUIDStore(makeOptions(opts...).provider)
 	}
 	x, ok := any(s).(UIDTxer)
 	if !ok {
 		x = &fakeUIDTxer{UIDStore: s}
 	}
-	return &uidIndex{store: x, fn: fn}
+	return newUIDFromTxer(x, fn, opts...)
 }
 
-func newUIDFromTxer(x UIDTxer, fn Func) UIDIndex {
+func newUIDFromTxer(x UIDTxer, fn Func, opts ...Option) UIDIndex {
 	if fn == nil {
 		fn = All
 	}
-	return &uidIndex{store: x, fn: fn}
+	o := makeOptions(opts...)
+	i := &uidIndex{store: x, fn: fn, trigrams: newTrigramIndex(o.trigrams, o.provider), provider: o.provider, names: o.names}
+	if o.cache != nil {
+		i.cache = newCache(*o.cache, o.names)
+	}
+	return i
+}
+
+// CacheStats returns the Find results cache statistics, which are zero if
+// the cache is not enabled.
+func (i *uidIndex) CacheStats() CacheStats {
+	if i.cache == nil {
+		return CacheStats{}
+	}
+	return i.cache.statistics()
 }
 
-func (i *uidIndex) index(ctx context.Context, tx UIDTx, uid uint64, m protoreflect.Message, fds ...protoreflect.FieldDescriptor) error {
+// addUID adds the uid to the value, recording the trigram index update
+// to apply once committed
+func (i *uidIndex) addUID(ctx context.Context, tx UIDTx, ups *[]trigramUpdate, uid uint64, v protoreflect.Value, fds ...protoreflect.FieldDescriptor) error {
+	if err := tx.AddUID(ctx, uid, v, fds...); err != nil {
+		return err
+	}
+	if i.trigrams != nil {
+		*ups = append(*ups, trigramUpdate{uid: uid, value: v, fds: fds})
+	}
+	return nil
+}
+
+func (i *uidIndex) index(ctx context.Context, tx UIDTx, ups *[]trigramUpdate, uid uint64, m protoreflect.Message, fds ...protoreflect.FieldDescriptor) error {
 	f := m.Descriptor().Fields()
 	name := m.Descriptor().FullName()
 	for j := 0; j < f.Len(); j++ {
@@ -72,7 +105,7 @@ func (i *uidIndex) index(ctx context.Context, tx UIDTx, uid uint64, m protorefle
 		if fd.IsList() {
 			if fd.Kind() == protoreflect.MessageKind {
 				for j2 := 0; j2 < rval.List().Len(); j2++ {
-					if err := i.index(ctx, tx, uid, rval.List().Get(j2).Message(), path...); err != nil {
+					if err := i.index(ctx, tx, ups, uid, rval.List().Get(j2).Message(), path...); err != nil {
 						return err
 					}
 				}
@@ -80,7 +113,7 @@ func (i *uidIndex) index(ctx context.Context, tx UIDTx, uid uint64, m protorefle
 			}
 			list := rval.List()
 			for j2 := 0; j2 < list.Len(); j2++ {
-				if err := tx.AddUID(ctx, uid, list.Get(j2), path...); err != nil {
+				if err := i.addUID(ctx, tx, ups, uid, list.Get(j2), path...); err != nil {
 					return err
 				}
 			}
@@ -89,11 +122,25 @@ func (i *uidIndex) index(ctx context.Context, tx UIDTx, uid uint64, m protorefle
 		if fd.IsMap() {
 			continue
 		}
+		if isDynamic(fd) {
+			if !ok || !rval.Message().IsValid() {
+				continue
+			}
+			// the Struct and Value leaves are indexed under their keys paths
+			preflect.RangeValues(rval.Message(), func(keys []string, vfd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+				err = i.addUID(ctx, tx, ups, uid, v, dynamicPath(path, keys, vfd)...)
+				return err == nil
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		if fd.Kind() == protoreflect.MessageKind && !preflect.IsWKType(fd.Message().FullName()) {
 			if !rval.Message().IsValid() {
 				continue
 			}
-			if err := i.index(ctx, tx, uid, rval.Message(), path...); err != nil {
+			if err := i.index(ctx, tx, ups, uid, rval.Message(), path...); err != nil {
 				return err
 			}
 			continue
@@ -104,7 +151,7 @@ func (i *uidIndex) index(ctx context.Context, tx UIDTx, uid uint64, m protorefle
 		if !ok {
 			continue
 		}
-		if err := tx.AddUID(ctx, uid, rval, path...); err != nil {
+		if err := i.addUID(ctx, tx, ups, uid, rval, path...); err != nil {
 			return err
 		}
 	}
@@ -117,10 +164,24 @@ func (i *uidIndex) Insert(ctx context.Context, uid uint64, m proto.Message) erro
 		return err
 	}
 	defer tx.Close()
-	if err := i.index(ctx, tx, uid, m.ProtoReflect()); err != nil {
+	var ups []trigramUpdate
+	if err := i.index(ctx, tx, &ups, uid, m.ProtoReflect()); err != nil {
+		return err
+	}
+	var values map[string]fieldValues
+	if i.cache != nil {
+		if values, err = i.collectValues(ctx, m.ProtoReflect()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	i.trigrams.apply(ups)
+	if i.cache != nil {
+		i.cache.invalidate(m.ProtoReflect().Descriptor(), changedPaths(nil, values))
+	}
+	return nil
 }
 
 func (i *uidIndex) Update(ctx context.Context, uid uint64, old, m proto.Message) error {
@@ -129,6 +190,7 @@ func (i *uidIndex) Update(ctx context.Context, uid uint64, old, m proto.Message)
 		return err
 	}
 	defer tx.Close()
+	var ups []trigramUpdate
 	oldValues := map[string]fieldValues{}
 	newValues := map[string]fieldValues{}
 	if old != nil {
@@ -146,19 +208,48 @@ func (i *uidIndex) Update(ctx context.Context, uid uint64, old, m proto.Message)
 		if err != nil {
 			return err
 		}
-		if err := applyUIDDiff(ctx, fr, tx, uid, oldValues, newValues); err != nil {
+		if err := i.applyUIDDiff(ctx, fr, tx, &ups, uid, oldValues, newValues); err != nil {
 			return err
 		}
 	} else {
 		for _, fv := range newValues {
 			for _, v := range fv.values {
-				if err := tx.AddUID(ctx, uid, v, fv.fds...); err != nil {
+				if err := i.addUID(ctx, tx, &ups, uid, v, fv.fds...); err != nil {
 					return err
 				}
 			}
 		}
 	}
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	i.trigrams.apply(ups)
+	if i.cache != nil {
+		msg := m
+		if msg == nil {
+			msg = old
+		}
+		if msg != nil {
+			i.cache.invalidate(msg.ProtoReflect().Descriptor(), changedPaths(oldValues, newValues))
+		}
+	}
+	return nil
+}
+
+// changedPaths returns the fields paths whose values differ
+func changedPaths(oldValues, newValues map[string]fieldValues) []string {
+	var out []string
+	for key, nv := range newValues {
+		if remove, add := diffValues(oldValues[key].values, nv.values); len(remove) != 0 || len(add) != 0 {
+			out = append(out, key)
+		}
+	}
+	for key := range oldValues {
+		if _, ok := newValues[key]; !ok {
+			out = append(out, key)
+		}
+	}
+	return out
 }
 
 func (i *uidIndex) collectValues(ctx context.Context, m protoreflect.Message) (map[string]fieldValues, error) {
@@ -201,6 +292,16 @@ func (i *uidIndex) collectValuesInto(ctx context.Context, out map[string]fieldVa
 		if fd.IsMap() {
 			continue
 		}
+		if isDynamic(fd) {
+			if !ok || !rval.Message().IsValid() {
+				continue
+			}
+			preflect.RangeValues(rval.Message(), func(keys []string, vfd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+				out = appendValue(out, dynamicPath(path, keys, vfd), v)
+				return true
+			})
+			continue
+		}
 		if fd.Kind() == protoreflect.MessageKind && !preflect.IsWKType(fd.Message().FullName()) {
 			if !rval.Message().IsValid() {
 				continue
@@ -221,7 +322,7 @@ func (i *uidIndex) collectValuesInto(ctx context.Context, out map[string]fieldVa
 	return nil
 }
 
-func applyUIDDiff(ctx context.Context, fr FieldReader, tx UIDTx, uid uint64, oldValues, newValues map[string]fieldValues) error {
+func (i *uidIndex) applyUIDDiff(ctx context.Context, fr FieldReader, tx UIDTx, ups *[]trigramUpdate, uid uint64, oldValues, newValues map[string]fieldValues) error {
 	seen := map[string]struct{}{}
 	for key := range oldValues {
 		seen[key] = struct{}{}
@@ -237,9 +338,12 @@ func applyUIDDiff(ctx context.Context, fr FieldReader, tx UIDTx, uid uint64, old
 			if err := removeUIDValue(ctx, fr, uid, v, ov.fds...); err != nil {
 				return err
 			}
+			if i.trigrams != nil {
+				*ups = append(*ups, trigramUpdate{uid: uid, value: v, fds: ov.fds, remove: true})
+			}
 		}
 		for _, v := range add {
-			if err := tx.AddUID(ctx, uid, v, nv.fds...); err != nil {
+			if err := i.addUID(ctx, tx, ups, uid, v, nv.fds...); err != nil {
 				return err
 			}
 		}
@@ -278,7 +382,14 @@ func (i *uidIndex) Remove(ctx context.Context, uid uint64) error {
 	if err := tx.ClearUID(ctx, uid); err != nil {
 		return err
 	}
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	i.trigrams.removeUID(uid)
+	if i.cache != nil {
+		i.cache.invalidateUID(uid)
+	}
+	return nil
 }
 
 func (i *uidIndex) doFind(ctx context.Context, tx UIDTx, t protoreflect.FullName, f *filters.FieldFilter) (bitmap.Bitmap, error) {
@@ -286,30 +397,191 @@ func (i *uidIndex) doFind(ctx context.Context, tx UIDTx, t protoreflect.FullName
 	if err != nil {
 		return nil, err
 	}
-	b := bitmap.NewWith(1024)
-	for v, err := range fds.Get(ctx, protoreflect.Name(f.Field)) {
+	b := i.provider.NewWith(1024)
+	if !preflect.IsPattern(f.Field) {
+		if err := i.findPath(ctx, t, fds, protoreflect.Name(f.Field), f.Filter, false, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	pr, ok := fds.(PathReader)
+	if !ok {
+		return nil, fmt.Errorf("index: %s: the store does not support wildcard paths", f.Field)
+	}
+	// the pattern elements are resolved with the index names mode
+	// against the fields of the indexed paths
+	var md protoreflect.MessageDescriptor
+	if i.names != preflect.ProtoNames {
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(t)
 		if err != nil {
 			return nil, err
 		}
+		md = mt.Descriptor()
+	}
+	for name, err := range pr.Paths(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		if !preflect.MatchPatternNames(md, f.Field, string(name), i.names) {
+			continue
+		}
+		if err := i.findPath(ctx, t, fds, name, f.Filter, true, b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// findPath adds to b the uids of the values of the field path matching the
+// filter, scanning only the values sharing its trigrams when the path is
+// indexed by the trigram index. If pattern is set, the path is skipped if
+// the field does not accept the filter.
+func (i *uidIndex) findPath(ctx context.Context, t protoreflect.FullName, fds FieldReader, name protoreflect.Name, f *filters.Filter, pattern bool, b bitmap.Bitmap) error {
+	if ok, err := i.trigrams.find(ctx, i.store, t, name, f, pattern, b); ok || err != nil {
+		return err
+	}
+	if ok, err := i.findNot(ctx, t, fds, name, f, pattern, b); ok || err != nil {
+		return err
+	}
+	for v, err := range fds.Get(ctx, name) {
+		if err != nil {
+			return err
+		}
 		ds := v.Descriptors()
 		fd := ds[len(ds)-1]
-		ok, err := preflect.Match(v.Value(), fd, f.Filter)
+		if pattern && !preflect.Accepts(fd, f) {
+			return nil
+		}
+		ok, err := matchIndexed(v.Value(), fd, f)
 		if err != nil {
-			return nil, err
+			return preflect.WithPath(err, string(name))
 		}
 		if !ok {
 			continue
 		}
 		b2, err := v.Bitmap(ctx)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := b.Or(b2); err != nil {
+			return err
 		}
-		b.Or(b2)
 	}
-	return b, nil
+	return nil
+}
+
+// findNot adds to b the uids of the values of the singular field path
+// matching the negated filter, i.e. the uids holding a value of the path but
+// the ones matching the filter, which may then use the trigram index.
+// It reports false if the filter is not negated or the path not singular.
+func (i *uidIndex) findNot(ctx context.Context, t protoreflect.FullName, fds FieldReader, name protoreflect.Name, f *filters.Filter, pattern bool, b bitmap.Bitmap) (bool, error) {
+	if !f.GetNot() || f.GetMatch() == nil {
+		return false, nil
+	}
+	all := i.provider.NewWith(1024)
+	for v, err := range fds.Get(ctx, name) {
+		if err != nil {
+			return false, err
+		}
+		ds := v.Descriptors()
+		if !negatable(ds) {
+			return false, nil
+		}
+		if pattern && !preflect.Accepts(ds[len(ds)-1], f) {
+			return true, nil
+		}
+		b2, err := v.Bitmap(ctx)
+		if err != nil {
+			return false, err
+		}
+		if err := all.Or(b2); err != nil {
+			return false, err
+		}
+	}
+	p := f.CloneVT()
+	p.Not = false
+	match := i.provider.NewWith(1024)
+	if err := i.findPath(ctx, t, fds, name, p, pattern, match); err != nil {
+		return true, err
+	}
+	if err := all.AndNot(match); err != nil {
+		return true, err
+	}
+	return true, b.Or(all)
+}
+
+// negatable reports whether the negated filters match the values of the
+// path not matching the filters, the path holding at most one value per
+// message: the Struct and Value fields may hold lists, and the unknown enum
+// values match neither a filter nor its negation
+func negatable(fds []protoreflect.FieldDescriptor) bool {
+	for _, fd := range fds {
+		if fd.IsList() || fd.Kind() == protoreflect.EnumKind {
+			return false
+		}
+		if _, ok := fd.(*keyField); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchIndexed matches the indexed value of the field fd
+func matchIndexed(v protoreflect.Value, fd protoreflect.FieldDescriptor, f *filters.Filter) (bool, error) {
+	if kf, ok := fd.(*keyField); ok {
+		return preflect.MatchValue(v, kf.FieldDescriptor, f)
+	}
+	return preflect.Match(v, fd, f)
+}
+
+// protoNames returns the expression with its fields paths resolved with the
+// index names mode and named after the proto names the fields are indexed by
+func (i *uidIndex) protoNames(t protoreflect.FullName, expr *filters.Expression) (*filters.Expression, error) {
+	if i.names == preflect.ProtoNames {
+		return expr, nil
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(t)
+	if err != nil {
+		return nil, err
+	}
+	out := expr.CloneVT()
+	var rename func(e *filters.Expression) error
+	rename = func(e *filters.Expression) error {
+		// the wildcard paths are resolved against the indexed paths
+		if e.Condition != nil && !preflect.IsPattern(e.Condition.Field) {
+			// the Struct keys are kept as is
+			head, tail, _ := preflect.SplitDynamic(mt.Descriptor(), e.Condition.Field, i.names)
+			fds, err := preflect.LookupNames(mt.Descriptor(), head, i.names)
+			if err != nil {
+				return err
+			}
+			e.Condition.Field = string(joinFieldNames(fds))
+			if tail != "" {
+				e.Condition.Field += "." + tail
+			}
+		}
+		for _, v := range e.AndExprs {
+			if err := rename(v); err != nil {
+				return err
+			}
+		}
+		for _, v := range e.OrExprs {
+			if err := rename(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := rename(out); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func (i *uidIndex) find(ctx context.Context, tx UIDTx, t protoreflect.FullName, f filters.FieldFilterer) (bitmap.Bitmap, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	expr := f.Expr()
 	b, err := i.doFind(ctx, tx, t, expr.Condition)
 	if err != nil {
@@ -320,15 +592,41 @@ func (i *uidIndex) find(ctx context.Context, tx UIDTx, t protoreflect.FullName,
 		if err != nil {
 			return nil, err
 		}
-		b.And(b2)
+		if err := b.And(b2); err != nil {
+			return nil, err
+		}
 	}
 	for _, v := range expr.OrExprs {
 		b2, err := i.find(ctx, tx, t, v)
 		if err != nil {
 			return nil, err
 		}
-		b.Or(b2)
+		if err := b.Or(b2); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// findCached returns the cached result of the expression if any, or finds
+// and caches it under the generation gen, read before opening the tx
+func (i *uidIndex) findCached(ctx context.Context, tx UIDTx, t protoreflect.FullName, expr *filters.Expression, gen uint64) (bitmap.Bitmap, error) {
+	if i.cache == nil {
+		return i.find(ctx, tx, t, expr)
 	}
+	key, paths := cacheKey(t, expr)
+	if key == "" {
+		return i.find(ctx, tx, t, expr)
+	}
+	b, ok := i.cache.get(key)
+	if ok {
+		return b, nil
+	}
+	b, err := i.find(ctx, tx, t, expr)
+	if err != nil {
+		return nil, err
+	}
+	i.cache.put(key, t, paths, b, gen)
 	return b, nil
 }
 
@@ -337,130 +635,58 @@ func (i *uidIndex) Find(ctx context.Context, t protoreflect.FullName, f filters.
 		if f == nil || f.Expr() == nil {
 			return
 		}
+		if err := opts.Limits.Check(f); err != nil {
+			yield(0, err)
+			return
+		}
+		expr, err := i.protoNames(t, f.Expr())
+		if err != nil {
+			yield(0, err)
+			return
+		}
+		// the writes committed after the tx is opened must invalidate
+		// its result
+		var gen uint64
+		if i.cache != nil {
+			gen = i.cache.generation()
+		}
 		tx, err := i.store.Tx(ctx)
 		if err != nil {
 			yield(0, err)
 			return
 		}
 		defer tx.Close()
-		b, err := i.find(ctx, tx, t, f)
+		b, err := i.findCached(ctx, tx, t, expr, gen)
 		if err != nil {
 			yield(0, err)
 			return
 		}
 
-		if !opts.Reverse {
-			var skipped uint64
-			var emitted uint64
-			for uid := range b.Iter() {
-				if skipped < opts.Offset {
-					skipped++
-					continue
-				}
-				if opts.Limit > 0 && emitted >= opts.Limit {
-					return
-				}
-				emitted++
-				if !yield(uid, nil) {
-					return
-				}
+		var it iter.Seq[uint64]
+		var skip uint64
+		if opts.Reverse {
+			it, skip = b.ReverseIter(), opts.Offset
+		} else {
+			// seek the first UID to return instead of skipping the offset
+			start, ok := b.Select(opts.Offset)
+			if !ok {
+				return
 			}
-			return
+			it = b.IterFrom(start)
 		}
-
-		uids := collectOrderedUIDs(b, opts)
 		var emitted uint64
-		var skipped uint64
-		for idx := len(uids) - 1; idx >= 0; idx-- {
-			if skipped < opts.Offset {
-				skipped++
+		for uid := range it {
+			if skip > 0 {
+				skip--
 				continue
 			}
 			if opts.Limit > 0 && emitted >= opts.Limit {
 				return
 			}
 			emitted++
-			if !yield(uids[idx], nil) {
+			if !yield(uid, nil) {
 				return
 			}
 		}
 	}
 }
-
-func collectOrderedUIDs(b bitmap.Bitmap, opts FindOptions) []uint64 {
-	if opts.Limit > 0 {
-		k := opts.Offset + opts.Limit
-		if k > 0 {
-			if opts.Reverse {
-				h := make(minHeap, 0, k)
-				for uid := range b.Iter() {
-					if uint64(h.Len()) < k {
-						heap.Push(&h, uid)
-						continue
-					}
-					if uid <= h[0] {
-						continue
-					}
-					h[0] = uid
-					heap.Fix(&h, 0)
-				}
-				uids := make([]uint64, len(h))
-				copy(uids, h)
-				sort.Slice(uids, func(a, b int) bool { return uids[a] < uids[b] })
-				return uids
-			}
-
-			h := make(maxHeap, 0, k)
-			for uid := range b.Iter() {
-				if uint64(h.Len()) < k {
-					heap.Push(&h, uid)
-					continue
-				}
-				if uid >= h[0] {
-					continue
-				}
-				h[0] = uid
-				heap.Fix(&h, 0)
-			}
-			uids := make([]uint64, len(h))
-			copy(uids, h)
-			sort.Slice(uids, func(a, b int) bool { return uids[a] < uids[b] })
-			return uids
-		}
-	}
-
-	uids := make([]uint64, 0, b.Cardinality())
-	for uid := range b.Iter() {
-		uids = append(uids, uid)
-	}
-	sort.Slice(uids, func(a, b int) bool { return uids[a] < uids[b] })
-	return uids
-}
-
-type minHeap []uint64
-
-func (h minHeap) Len() int           { return len(h) }
-func (h minHeap) Less(i, j int) bool { return h[i] < h[j] }
-func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-func (h *minHeap) Push(x any)        { *h = append(*h, x.(uint64)) }
-func (h *minHeap) Pop() any {
-	old := *h
-	n := len(old)
-	v := old[n-1]
-	*h = old[:n-1]
-	return v
-}
-
-type maxHeap []uint64
-
-func (h maxHeap) Len() int           { return len(h) }
-func (h maxHeap) Less(i, j int) bool { return h[i] > h[j] }
-func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-func (h *maxHeap) Push(x any)        { *h = append(*h, x.(uint64)) }
-func (h *maxHeap) Pop() any {
-	old := *h
-	n := len(old)
-	v := old[n-1]
-	*h = old[:n-1]
-	return v
-}
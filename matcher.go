@@ -17,11 +17,15 @@
 package protofilters
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
 
 	"google.golang.org/protobuf/proto"
 	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 
 	"go.linka.cloud/protofilters/filters"
 	"go.linka.cloud/protofilters/reflect"
@@ -37,6 +41,16 @@ type Matcher interface {
 	MatchFilters(m proto.Message, fs ...*filters.FieldFilter) (bool, error)
 
 	MatchExpression(msg proto.Message, expr *filters.Expression) (bool, error)
+	// MatchContext matches to proto.Message against the protofilters.FieldsFilterer,
+	// returning the context error if it is done before the result is known
+	MatchContext(ctx context.Context, m proto.Message, f filters.FieldFilterer) (bool, error)
+	// MatchBytes matches the wire-format encoded message described by md
+	// against the protofilters.FieldsFilterer, decoding only the values of
+	// the fields reached by the evaluated conditions
+	MatchBytes(md pref.MessageDescriptor, b []byte, f filters.FieldFilterer) (bool, error)
+	// MatchExplain matches to proto.Message against the protofilters.FieldsFilterer,
+	// returning the trace of the match
+	MatchExplain(m proto.Message, f filters.FieldFilterer) (*Explanation, error)
 }
 
 // CachingMatcher is a Matcher that cache messages field path lookup results
@@ -46,9 +60,56 @@ type CachingMatcher interface {
 	Clear()
 }
 
+// FilterMatcher is implemented by the messages with a matcher generated by
+// protoc-gen-go-filters, used by the Matcher instead of reflection when the
+// field paths are resolved by their proto names.
+type FilterMatcher interface {
+	// MatchFilter matches the message against the expression.
+	// It returns reflect.ErrUnsupported if the expression must be matched
+	// by reflection.
+	MatchFilter(expr *filters.Expression) (bool, error)
+}
+
+// MatcherOption configures a Matcher
+type MatcherOption func(m *matcher)
+
+// WithFieldNames sets the names the field paths are resolved by.
+// It defaults to reflect.ProtoNames.
+func WithFieldNames(mode reflect.NameMode) MatcherOption {
+	return func(m *matcher) {
+		m.names = mode
+	}
+}
+
+// WithResolver sets the resolver of the types of the messages packed in the
+// google.protobuf.Any fields. It defaults to protoregistry.GlobalTypes.
+// The paths continue in the packed messages either with their explicit type
+// name, e.g. payload.(my.pkg.Event).user_id, matching only the messages of
+// this type, or implicitly, e.g. payload.user_id, matching the messages
+// containing the path whatever their type, the Any fields taking
+// precedence. The type URL is selected with the @type element,
+// e.g. payload.@type.
+func WithResolver(r protoregistry.MessageTypeResolver) MatcherOption {
+	return func(m *matcher) {
+		m.resolver = r
+	}
+}
+
+// WithLimits sets the limits enforced when matching the filters, e.g. from
+// untrusted sources. Exceeding them returns a *filters.LimitError.
+func WithLimits(l filters.Limits) MatcherOption {
+	return func(m *matcher) {
+		m.limits = l
+	}
+}
+
 // NewMatcher creates a CachingMatcher
-func NewMatcher() CachingMatcher {
-	return &matcher{cache: make(map[pref.FullName]map[string][]pref.FieldDescriptor)}
+func NewMatcher(opts ...MatcherOption) CachingMatcher {
+	m := &matcher{cache: make(map[pref.FullName]map[string]*lookup), resolver: protoregistry.GlobalTypes}
+	for _, v := range opts {
+		v(m)
+	}
+	return m
 }
 
 var defaultMatcher = NewMatcher()
@@ -63,14 +124,24 @@ func MatchFilters(msg proto.Message, fs ...*filters.FieldFilter) (bool, error) {
 	return defaultMatcher.MatchFilters(msg, fs...)
 }
 
+// MatchContext is a convenient method calling MatchContext on the defaultMatcher
+func MatchContext(ctx context.Context, msg proto.Message, f filters.FieldFilterer) (bool, error) {
+	return defaultMatcher.MatchContext(ctx, msg, f)
+}
+
 // Deprecated: MatchExpression match proto.Message against the given expression, Match should be used instead
 func MatchExpression(msg proto.Message, expr *filters.Expression) (bool, error) {
 	return defaultMatcher.MatchExpression(msg, expr)
 }
 
 type matcher struct {
-	mu    sync.RWMutex
-	cache map[pref.FullName]map[string][]pref.FieldDescriptor
+	mu       sync.RWMutex
+	cache    map[pref.FullName]map[string]*lookup
+	names    reflect.NameMode
+	resolver protoregistry.MessageTypeResolver
+	limits   filters.Limits
+	// reflective disables the generated matchers
+	reflective bool
 }
 
 // Deprecated: MatchExpression match proto.Message against the given expression, Match should be used instead
@@ -79,17 +150,77 @@ func (m *matcher) MatchExpression(msg proto.Message, expr *filters.Expression) (
 }
 
 func (m *matcher) Match(msg proto.Message, f filters.FieldFilterer) (bool, error) {
+	return m.MatchContext(context.Background(), msg, f)
+}
+
+func (m *matcher) MatchContext(ctx context.Context, msg proto.Message, f filters.FieldFilterer) (bool, error) {
 	if msg == nil {
 		return false, errors.New("message is null")
 	}
 	if f == nil || f.Expr() == nil {
 		return true, nil
 	}
-	return m.matchExpression(msg, f.Expr())
+	if err := m.limits.Check(f); err != nil {
+		return false, err
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	// the generated matchers do not count the scanned list elements
+	if fm, ok := msg.(FilterMatcher); ok && m.names == reflect.ProtoNames && !m.reflective && m.limits.MaxListElements == 0 {
+		ok, err := fm.MatchFilter(f.Expr())
+		if !errors.Is(err, reflect.ErrUnsupported) {
+			return ok, err
+		}
+	}
+	return m.matchExpression(newMatchState(ctx, m.limits), msg, f.Expr())
+}
+
+// matchState is the state of a match, checking its context and limits
+type matchState struct {
+	ctx context.Context
+	max int
+	// scanned is the number of list elements scanned
+	scanned int
+	// explain records the values read in values, at the fields path
+	explain bool
+	path    []string
+	values  []ExplainedValue
+}
+
+func newMatchState(ctx context.Context, l filters.Limits) *matchState {
+	return &matchState{ctx: ctx, max: l.MaxListElements}
+}
+
+// done returns the context error, if any
+func (s *matchState) done() error {
+	if s == nil {
+		return nil
+	}
+	return s.ctx.Err()
+}
+
+// scan counts a scanned list element
+func (s *matchState) scan() error {
+	if s == nil {
+		return nil
+	}
+	s.scanned++
+	if s.max > 0 && s.scanned > s.max {
+		return &filters.LimitError{Limit: "MaxListElements", Max: s.max}
+	}
+	// checking the context has a cost
+	if s.scanned%64 == 0 {
+		return s.ctx.Err()
+	}
+	return nil
 }
 
-func (m *matcher) matchExpression(msg proto.Message, expr *filters.Expression) (bool, error) {
-	ok, err := m.matchFieldFilter(msg, expr.Condition)
+func (m *matcher) matchExpression(s *matchState, msg proto.Message, expr *filters.Expression) (bool, error) {
+	if err := s.done(); err != nil {
+		return false, err
+	}
+	ok, err := m.matchFieldFilter(s, msg, expr.Condition)
 	if err != nil {
 		return false, err
 	}
@@ -98,7 +229,7 @@ func (m *matcher) matchExpression(msg proto.Message, expr *filters.Expression) (
 	}
 	if ok {
 		for _, v := range expr.AndExprs {
-			ok, err = m.matchExpression(msg, v)
+			ok, err = m.matchExpression(s, msg, v)
 			if err != nil {
 				return false, err
 			}
@@ -111,7 +242,7 @@ func (m *matcher) matchExpression(msg proto.Message, expr *filters.Expression) (
 		return true, nil
 	}
 	for _, v := range expr.OrExprs {
-		ok, err = m.matchExpression(msg, v)
+		ok, err = m.matchExpression(s, msg, v)
 		if err != nil {
 			return false, err
 		}
@@ -140,7 +271,7 @@ func (m *matcher) match(msg proto.Message, f *filters.FieldsFilter) (bool, error
 	}
 
 	for path, filter := range f.Filters {
-		ok, err := m.matchFieldFilter(msg, &filters.FieldFilter{Field: path, Filter: filter})
+		ok, err := m.matchFieldFilter(nil, msg, &filters.FieldFilter{Field: path, Filter: filter})
 		if err != nil {
 			return false, err
 		}
@@ -151,19 +282,23 @@ func (m *matcher) match(msg proto.Message, f *filters.FieldsFilter) (bool, error
 	return true, nil
 }
 
-func (m *matcher) matchFieldFilter(msg proto.Message, ff *filters.FieldFilter) (bool, error) {
+func (m *matcher) matchFieldFilter(s *matchState, msg proto.Message, ff *filters.FieldFilter) (bool, error) {
 	if ff == nil {
 		return true, nil
 	}
-	return m.matchFilter(msg, ff.Field, ff.Filter)
+	return m.matchFilter(s, msg, ff.Field, ff.Filter)
 }
 
-func (m *matcher) doMatch(msg pref.Message, filter *filters.Filter, fds []pref.FieldDescriptor, iterating bool) (bool, error) {
+func (m *matcher) doMatch(s *matchState, msg pref.Message, filter *filters.Filter, fds []pref.FieldDescriptor, iterating bool) (bool, error) {
 	if len(fds) == 0 {
 		return false, errors.New("field path is empty")
 	}
 	fd := fds[0]
 	fds = fds[1:]
+	if s.explaining() {
+		s.path = append(s.path, string(fd.Name()))
+		defer func() { s.path = s.path[:len(s.path)-1] }()
+	}
 	if isUnsetRealOneofField(msg, fd) {
 		return false, nil
 	}
@@ -171,7 +306,13 @@ func (m *matcher) doMatch(msg pref.Message, filter *filters.Filter, fds []pref.F
 	if len(fds) != 0 {
 		if fd.Kind() == pref.MessageKind && fd.IsList() && !iterating {
 			for j := 0; j < rval.List().Len(); j++ {
-				ok, err := m.doMatch(rval.List().Get(j).Message(), filter, fds, true)
+				if err := s.scan(); err != nil {
+					return false, err
+				}
+				if s.explaining() {
+					s.path[len(s.path)-1] = fmt.Sprintf("%s[%d]", fd.Name(), j)
+				}
+				ok, err := m.doMatch(s, rval.List().Get(j).Message(), filter, fds, true)
 				if err != nil {
 					return false, err
 				}
@@ -182,16 +323,20 @@ func (m *matcher) doMatch(msg pref.Message, filter *filters.Filter, fds []pref.F
 			return false, nil
 		}
 		if fd.Kind() == pref.MessageKind {
-			return m.doMatch(rval.Message(), filter, fds, false)
+			return m.doMatch(s, rval.Message(), filter, fds, false)
 		}
 	}
 	if fd.IsList() {
 		list := rval.List()
 		for i := 0; i < list.Len(); i++ {
+			if err := s.scan(); err != nil {
+				return false, err
+			}
 			match, err := reflect.Match(list.Get(i), fd, filter)
 			if err != nil {
 				return false, err
 			}
+			s.record(list.Get(i), fd, i, match)
 			if filter.GetNot() && !match {
 				return false, nil
 			}
@@ -211,6 +356,7 @@ func (m *matcher) doMatch(msg pref.Message, filter *filters.Filter, fds []pref.F
 	if err != nil {
 		return false, err
 	}
+	s.record(rval, fd, -1, ok)
 	return ok, nil
 }
 
@@ -222,20 +368,145 @@ func isUnsetRealOneofField(msg pref.Message, fd pref.FieldDescriptor) bool {
 	return !msg.Has(fd)
 }
 
-func (m *matcher) matchFilter(msg proto.Message, path string, filter *filters.Filter) (bool, error) {
-	fds, err := m.lookup(msg, path)
+func (m *matcher) matchFilter(s *matchState, msg proto.Message, path string, filter *filters.Filter) (bool, error) {
+	ok, err := m.fields(msg.ProtoReflect(), path, func(msg pref.Message, fds []pref.FieldDescriptor, pattern bool) (bool, error) {
+		// the wildcards only match the fields accepting the filter
+		if pattern && !reflect.Accepts(fds[len(fds)-1], filter) {
+			return false, nil
+		}
+		return m.doMatch(s, msg, filter, fds, false)
+	})
+	return ok, reflect.WithPath(err, path)
+}
+
+// fields calls fn with the messages and the fields paths the path resolves
+// to, unpacking the google.protobuf.Any fields, until fn returns true or an
+// error
+func (m *matcher) fields(msg pref.Message, path string, fn func(msg pref.Message, fds []pref.FieldDescriptor, pattern bool) (bool, error)) (bool, error) {
+	l, err := m.lookup(msg.Descriptor(), path)
 	if err != nil {
 		return false, err
 	}
-	return m.doMatch(msg.ProtoReflect(), filter, fds, false)
+	paths := l.paths
+	if l.recursive != "" {
+		paths = reflect.ExpandMessage(msg, l.recursive, m.names)
+	}
+	if l.unpack == nil && l.keys == nil {
+		for _, fds := range paths {
+			ok, err := fn(msg, fds, l.pattern)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	msgs := []pref.Message{msg}
+	if len(l.paths) != 0 {
+		msgs = nil
+		for _, fds := range paths {
+			// the unset Struct and Value fields hold null values
+			msgs = appendMessages(msgs, msg, fds, l.keys != nil)
+		}
+	}
+	if l.keys != nil {
+		var values []pref.Message
+		for _, v := range msgs {
+			values = reflect.AppendValues(values, v, l.keys)
+		}
+		for _, v := range values {
+			fd := reflect.ValueField(v)
+			ok, err := fn(v, []pref.FieldDescriptor{fd}, false)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	for _, v := range msgs {
+		msg, err := m.unpack(v, l.unpack.typ)
+		if err != nil {
+			return false, err
+		}
+		if msg == nil {
+			continue
+		}
+		if l.unpack.typ == nil {
+			// the implicitly unpacked messages not containing the path
+			// do not match
+			if _, err := m.lookup(msg.Descriptor(), l.unpack.path); err != nil {
+				continue
+			}
+		}
+		ok, err := m.fields(msg, l.unpack.path, fn)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// appendMessages appends the messages the fields path leads to, the
+// unset ones only if unset is set
+func appendMessages(out []pref.Message, msg pref.Message, fds []pref.FieldDescriptor, unset bool) []pref.Message {
+	fd := fds[0]
+	if isUnsetRealOneofField(msg, fd) || (!unset && !msg.Has(fd)) {
+		return out
+	}
+	var msgs []pref.Message
+	if fd.IsList() {
+		list := msg.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			msgs = append(msgs, list.Get(i).Message())
+		}
+	} else {
+		msgs = append(msgs, msg.Get(fd).Message())
+	}
+	if len(fds) == 1 {
+		return append(out, msgs...)
+	}
+	for _, v := range msgs {
+		out = appendMessages(out, v, fds[1:], unset)
+	}
+	return out
+}
+
+// unpack returns the message packed in the google.protobuf.Any message, or
+// nil if it is empty or not of the given type
+func (m *matcher) unpack(msg pref.Message, typ pref.MessageType) (pref.Message, error) {
+	fields := msg.Descriptor().Fields()
+	url := msg.Get(fields.ByName("type_url")).String()
+	if url == "" {
+		return nil, nil
+	}
+	if typ == nil {
+		var err error
+		if typ, err = m.resolver.FindMessageByURL(url); err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+	} else if reflect.AnyTypeName(url) != typ.Descriptor().FullName() {
+		return nil, nil
+	}
+	out := typ.New()
+	o := proto.UnmarshalOptions{}
+	if r, ok := m.resolver.(protoregistry.ExtensionTypeResolver); ok {
+		o.Resolver = r
+	}
+	if err := o.Unmarshal(msg.Get(fields.ByName("value")).Bytes(), out.Interface()); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return out, nil
 }
 
 func (m *matcher) MatchFilters(msg proto.Message, fs ...*filters.FieldFilter) (bool, error) {
 	if msg == nil {
 		return false, errors.New("message is null")
 	}
+	s := newMatchState(context.Background(), m.limits)
 	for _, ff := range fs {
-		ok, err := m.matchFieldFilter(msg, ff)
+		if err := m.limits.Check(&filters.Expression{Condition: ff}); err != nil {
+			return false, err
+		}
+		ok, err := m.matchFieldFilter(s, msg, ff)
 		if err != nil {
 			return false, err
 		}
@@ -248,33 +519,103 @@ func (m *matcher) MatchFilters(msg proto.Message, fs ...*filters.FieldFilter) (b
 
 func (m *matcher) Clear() {
 	m.mu.Lock()
-	m.cache = make(map[pref.FullName]map[string][]pref.FieldDescriptor)
+	m.cache = make(map[pref.FullName]map[string]*lookup)
 	m.mu.Unlock()
 }
 
-func (m *matcher) lookup(msg proto.Message, path string) ([]pref.FieldDescriptor, error) {
+// lookup is a resolved field path
+type lookup struct {
+	// paths are the fields paths matching the path, which may contain
+	// wildcards
+	paths   [][]pref.FieldDescriptor
+	pattern bool
+	// recursive is the pattern expanded in the matched messages if it
+	// contains recursive wildcards, paths only holding its expansion in
+	// the message type
+	recursive string
+	// unpack is set if the path continues in the messages packed in the
+	// google.protobuf.Any fields ending the paths, or in the message itself
+	// if there is no paths
+	unpack *unpack
+	// keys are set if the path continues in the google.protobuf.Struct,
+	// Value or ListValue fields ending the paths, or in the message itself
+	// if there is no paths
+	keys []string
+}
+
+type unpack struct {
+	// typ is the explicit type of the packed messages, if any
+	typ  pref.MessageType
+	path string
+}
+
+// lookup returns the resolved path in the message described by md
+func (m *matcher) lookup(md pref.MessageDescriptor, path string) (*lookup, error) {
 	if m.cache == nil {
 		m.mu.Lock()
-		m.cache = make(map[pref.FullName]map[string][]pref.FieldDescriptor)
+		m.cache = make(map[pref.FullName]map[string]*lookup)
 		m.mu.Unlock()
 	}
-	typ := msg.ProtoReflect().Descriptor().FullName()
+	typ := md.FullName()
 	m.mu.RLock()
 	fields := m.cache[typ]
-	fd, ok := fields[path]
+	l, ok := fields[path]
 	m.mu.RUnlock()
 	if ok {
-		return fd, nil
+		return l, nil
 	}
-	fds, err := reflect.Lookup(msg.ProtoReflect(), path)
+	l, err := m.resolve(md, path)
 	if err != nil {
 		return nil, err
 	}
 	m.mu.Lock()
 	if m.cache[typ] == nil {
-		m.cache[typ] = make(map[string][]pref.FieldDescriptor)
+		m.cache[typ] = make(map[string]*lookup)
 	}
-	m.cache[typ][path] = fds
+	m.cache[typ][path] = l
 	m.mu.Unlock()
-	return fds, nil
+	return l, nil
+}
+
+func (m *matcher) resolve(md pref.MessageDescriptor, path string) (*lookup, error) {
+	head, tail, dyn := reflect.SplitDynamic(md, path, m.names)
+	l := &lookup{pattern: reflect.IsPattern(head)}
+	if reflect.IsRecursivePattern(head) {
+		l.recursive = head
+	}
+	if head != "" || dyn == nil {
+		paths, err := reflect.Expand(md, head, m.names)
+		if err != nil {
+			return nil, err
+		}
+		l.paths = paths
+	}
+	if dyn == nil {
+		return l, nil
+	}
+	if reflect.WKType(dyn.FullName()) != reflect.Any {
+		l.keys = []string{}
+		if tail != "" {
+			l.keys = strings.Split(tail, ".")
+		}
+		return l, nil
+	}
+	name, rest, err := reflect.ParseAny(tail)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l.unpack = &unpack{path: rest}
+	if name == "" {
+		return l, nil
+	}
+	mt, err := m.resolver.FindMessageByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+	}
+	// validates the path in the packed messages
+	if _, err := m.lookup(mt.Descriptor(), rest); err != nil {
+		return nil, err
+	}
+	l.unpack.typ = mt
+	return l, nil
 }
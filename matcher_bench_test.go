@@ -1,8 +1,12 @@
 package protofilters
 
 import (
+	"context"
+	"slices"
 	"testing"
 
+	"google.golang.org/protobuf/proto"
+
 	"go.linka.cloud/protofilters/filters"
 	test "go.linka.cloud/protofilters/tests/pb"
 )
@@ -52,12 +56,55 @@ func BenchmarkMatchScan1M(b *testing.B) {
 
 	msgs := benchmarkBuildMatchMessages(total, matchEvery)
 	simple := filters.Where("string_field").StringEquals("match")
-	m := NewMatcher()
+	complex := filters.Where("string_field").StringEquals("match").
+		AndWhere("number_field").NumberSup(10).
+		OrWhere("bool_field").True()
 
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		benchMatchSink = benchmarkMatchScanWithMatcher(b, m, msgs, simple)
+	reflective := NewMatcher().(*matcher)
+	reflective.reflective = true
+	for _, v := range []struct {
+		name string
+		m    Matcher
+	}{
+		{name: "generated", m: NewMatcher()},
+		{name: "reflect", m: reflective},
+	} {
+		b.Run(v.name+"/simple", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchMatchSink = benchmarkMatchScanWithMatcher(b, v.m, msgs, simple)
+			}
+		})
+		b.Run(v.name+"/complex", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				benchMatchSink = benchmarkMatchScanWithMatcher(b, v.m, msgs, complex)
+			}
+		})
+	}
+	for _, v := range []struct {
+		name string
+		opts []FilterOption
+	}{
+		{name: "filter/ordered"},
+		{name: "filter/unordered", opts: []FilterOption{WithUnordered()}},
+	} {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				count := 0
+				for _, err := range Filter(context.Background(), slices.Values(msgs), complex, v.opts...) {
+					if err != nil {
+						b.Fatal(err)
+					}
+					count++
+				}
+				benchMatchSink = count
+			}
+		})
 	}
 }
 
@@ -106,3 +153,39 @@ func benchmarkMatchScanWithMatcher(b *testing.B, m Matcher, msgs []*test.Test, f
 	}
 	return count
 }
+
+func BenchmarkMatchBytes(b *testing.B) {
+	msg := &test.Test{
+		StringField:          "match",
+		RepeatedStringField:  []string{"one", "two", "three"},
+		RepeatedMessageField: []*test.Test{{StringField: "one"}, {StringField: "two"}},
+		MessageField:         &test.Test{StringField: "nested", RepeatedStringField: []string{"one", "two"}},
+	}
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	md := msg.ProtoReflect().Descriptor()
+	f := filters.Where("string_field").StringEquals("match")
+
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var m test.Test
+			if err := proto.Unmarshal(buf, &m); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := Match(&m, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := MatchBytes(md, buf, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
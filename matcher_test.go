@@ -17,17 +17,26 @@
 package protofilters
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/reflect"
 	test "go.linka.cloud/protofilters/tests/pb"
 )
 
@@ -147,6 +156,18 @@ func TestOptionalsNil(t *testing.T) {
 			f:    filters.Where("optional_string_field").Null(),
 			ok:   false,
 		},
+		{
+			name: "empty enum optional equals",
+			m:    &test.Test{},
+			f:    filters.Where("optional_enum_field").StringEquals("ONE"),
+			ok:   false,
+		},
+		{
+			name: "empty enum optional not equals",
+			m:    &test.Test{},
+			f:    filters.Where("optional_enum_field").StringNotEquals("ONE"),
+			ok:   true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -230,6 +251,10 @@ func TestString(t *testing.T) {
 	assert.False(Match(m, filters.Where("string_value_field").StringHasPrefix("noop")))
 	assert.True(Match(m, filters.Where("string_value_field").StringHasSuffix("ever")))
 	assert.False(Match(m, filters.Where("string_value_field").StringHasSuffix("noop")))
+	assert.True(Match(m, filters.Where("string_value_field").StringContains("tev")))
+	assert.False(Match(m, filters.Where("string_value_field").StringContains("TEV")))
+	assert.True(Match(m, filters.Where("string_value_field").StringIContains("TEV")))
+	assert.False(Match(m, filters.Where("string_value_field").StringNotContains("tev")))
 	assert.True(Match(m, filters.Where("string_value_field").StringInf("whenever")))
 	assert.False(Match(m, filters.Where("string_value_field").StringSup("whenever")))
 }
@@ -638,3 +663,425 @@ func TestSimpleOrFalse(t *testing.T) {
 		}},
 	}))
 }
+
+func TestFieldNames(t *testing.T) {
+	m := &test.Test{StringField: "a", MessageField: &test.Test{NumberField: 1}}
+	tests := []struct {
+		mode reflect.NameMode
+		path string
+		err  bool
+	}{
+		{mode: reflect.ProtoNames, path: "message_field.number_field"},
+		{mode: reflect.ProtoNames, path: "messageField.numberField", err: true},
+		{mode: reflect.JSONNames, path: "messageField.numberField"},
+		{mode: reflect.JSONNames, path: "message_field.number_field", err: true},
+		{mode: reflect.EitherNames, path: "messageField.number_field"},
+		{mode: reflect.EitherNames, path: "MessageField.NumberField", err: true},
+		{mode: reflect.PermissiveNames, path: "MessageField.NUMBER_FIELD"},
+		{mode: reflect.PermissiveNames, path: "message.number", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ok, err := NewMatcher(WithFieldNames(tt.mode)).Match(m, filters.Where(tt.path).NumberEquals(1))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	m := &test.Test{
+		StringField: "a",
+		MessageField: &test.Test{
+			StringField:  "b",
+			NumberField:  1,
+			MessageField: &test.Test{StringField: "deep"},
+		},
+		RepeatedMessageField: []*test.Test{{StringField: "c"}, {StringField: "d", RepeatedStringField: []string{"e"}}},
+		Choice:               &test.Test_OneofMessageField{OneofMessageField: &test.Test{StringField: "f"}},
+	}
+	tests := []struct {
+		path  string
+		value string
+		want  bool
+	}{
+		{path: "*", value: "a", want: true},
+		{path: "*", value: "b"},
+		{path: "*.string_field", value: "b", want: true},
+		{path: "*.string_field", value: "c", want: true},
+		{path: "*.string_field", value: "f", want: true},
+		{path: "*.string_field", value: "a"},
+		{path: "**.string_field", value: "a", want: true},
+		{path: "**.string_field", value: "d", want: true},
+		{path: "**.repeated_string_field", value: "e", want: true},
+		// the recursive wildcard descends into the set messages at any depth
+		{path: "**.string_field", value: "deep", want: true},
+		{path: "*.*.string_field", value: "deep", want: true},
+		{path: "**.message_field.string_field", value: "deep", want: true},
+		{path: "message_field.*", value: "b", want: true},
+		{path: "message_field.*", value: "c"},
+		{path: "**", value: "e", want: true},
+		{path: "**.unknown", value: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.value, func(t *testing.T) {
+			ok, err := Match(m, filters.Where(tt.path).StringEquals(tt.value))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+	// the wildcards only match the fields accepting the filter
+	ok, err := Match(m, filters.Where("**.number_field").NumberEquals(1))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Match(m, filters.Where("*").NumberEquals(1))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = Match(m, filters.Where("message_field.*").NumberEquals(1))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// envelope returns the descriptor of a message carrying Any payloads and
+// Struct metadata
+func envelope(t *testing.T) pref.MessageDescriptor {
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("envelope.proto"),
+		Package:    proto.String("linka.cloud.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/any.proto", "google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Envelope"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("payload"),
+					JsonName: proto.String("payload"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.Any"),
+				},
+				{
+					Name:     proto.String("payloads"),
+					JsonName: proto.String("payloads"),
+					Number:   proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.Any"),
+				},
+				{
+					Name:     proto.String("metadata"),
+					JsonName: proto.String("metadata"),
+					Number:   proto.Int32(3),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.Struct"),
+				},
+				{
+					Name:     proto.String("attr"),
+					JsonName: proto.String("attr"),
+					Number:   proto.Int32(4),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".google.protobuf.Value"),
+				},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Messages().Get(0)
+}
+
+func TestAny(t *testing.T) {
+	md := envelope(t)
+	pack := func(m proto.Message) pref.Value {
+		a, err := anypb.New(m)
+		require.NoError(t, err)
+		return pref.ValueOfMessage(a.ProtoReflect())
+	}
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("payload"), pack(&test.Test{StringField: "a", MessageField: &test.Test{NumberField: 1}}))
+	list := m.Mutable(md.Fields().ByName("payloads")).List()
+	list.Append(pack(wrapperspb.String("b")))
+	list.Append(pack(&test.Test{StringField: "c"}))
+	tests := []struct {
+		f    filters.FieldFilterer
+		want bool
+	}{
+		{f: filters.Where("payload.(linka.cloud.test.Test).string_field").StringEquals("a"), want: true},
+		{f: filters.Where("payload.(linka.cloud.test.Test).string_field").StringNotEquals("b"), want: true},
+		{f: filters.Where("payload.(linka.cloud.test.Test).message_field.number_field").NumberEquals(1), want: true},
+		{f: filters.Where("payload.string_field").StringEquals("a"), want: true},
+		{f: filters.Where("payload.string_field").StringEquals("b")},
+		{f: filters.Where("payload.@type").StringHasSuffix("/linka.cloud.test.Test"), want: true},
+		{f: filters.Where("payload.type_url").StringHasSuffix("/linka.cloud.test.Test"), want: true},
+		{f: filters.Where("payloads.(linka.cloud.test.Test).string_field").StringEquals("c"), want: true},
+		{f: filters.Where("payloads.(google.protobuf.StringValue).value").StringEquals("b"), want: true},
+		{f: filters.Where("payloads.(google.protobuf.StringValue).value").StringEquals("c")},
+		// the implicitly unpacked messages not containing the path do not match
+		{f: filters.Where("payloads.string_field").StringEquals("c"), want: true},
+		{f: filters.Where("payloads.string_field").StringNotEquals("c")},
+		{f: filters.Where("payloads.@type").StringHasSuffix("StringValue"), want: true},
+		{f: filters.Where("payload.(linka.cloud.test.Test).**.number_field").NumberEquals(1), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.Expr().Format(), func(t *testing.T) {
+			ok, err := Match(m, tt.f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+	// the packed messages are matched themselves
+	ok, err := Match(pack(&test.Test{StringField: "a"}).Message().Interface(), filters.Where("string_field").StringEquals("a"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, v := range []string{
+		"payload.(linka.cloud.test.Test).unknown",
+		"payload.(linka.cloud.test.Unknown).string_field",
+		"payload.(linka.cloud.test.Test",
+		"payload.(linka.cloud.test.Test)",
+	} {
+		_, err := Match(m, filters.Where(v).StringEquals("a"))
+		assert.Error(t, err, v)
+	}
+	_, err = NewMatcher(WithResolver(&protoregistry.Types{})).Match(m, filters.Where("payload.string_field").StringEquals("a"))
+	assert.Error(t, err)
+}
+
+func TestStruct(t *testing.T) {
+	md := envelope(t)
+	metadata, err := structpb.NewStruct(map[string]any{
+		"region":  "eu",
+		"count":   3,
+		"enabled": true,
+		"tags":    []any{"a", "b"},
+		"nested":  map[string]any{"zone": "z1"},
+		"none":    nil,
+		"items":   []any{map[string]any{"id": "1"}, map[string]any{"id": "2"}},
+	})
+	require.NoError(t, err)
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("metadata"), pref.ValueOfMessage(metadata.ProtoReflect()))
+	m.Set(md.Fields().ByName("attr"), pref.ValueOfMessage(structpb.NewStringValue("v").ProtoReflect()))
+	tests := []struct {
+		f    filters.FieldFilterer
+		want bool
+	}{
+		{f: filters.Where("metadata.region").StringEquals("eu"), want: true},
+		{f: filters.Where("metadata.region").StringNotEquals("eu")},
+		{f: filters.Where("metadata.region").StringNotEquals("us"), want: true},
+		{f: filters.Where("metadata.count").NumberSup(2), want: true},
+		{f: filters.Where("metadata.enabled").True(), want: true},
+		// the filters do not match the values of another kind
+		{f: filters.Where("metadata.count").StringEquals("3")},
+		{f: filters.Where("metadata.region").NumberNotEquals(1), want: true},
+		{f: filters.Where("metadata.tags").StringEquals("b"), want: true},
+		{f: filters.Where("metadata.tags").StringEquals("c")},
+		{f: filters.Where("metadata.nested.zone").StringHasPrefix("z"), want: true},
+		{f: filters.Where("metadata.items.id").StringEquals("2"), want: true},
+		{f: filters.Where("metadata.none").Null(), want: true},
+		{f: filters.Where("metadata.missing").Null(), want: true},
+		{f: filters.Where("metadata.region").Null()},
+		{f: filters.Where("metadata.region").NotNull(), want: true},
+		{f: filters.Where("metadata.missing").NotNull()},
+		{f: filters.Where("metadata.missing").StringEquals("x")},
+		{f: filters.Where("metadata.missing").StringNotEquals("x"), want: true},
+		{f: filters.Where("metadata.region.sub").StringEquals("eu")},
+		{f: filters.Where("attr").StringEquals("v"), want: true},
+		{f: filters.Where("metadata").NotNull(), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.f.Expr().Format(), func(t *testing.T) {
+			ok, err := Match(m, tt.f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+	ok, err := Match(dynamicpb.NewMessage(md), filters.Where("metadata.region").Null())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = Match(m, filters.Where("metadata.region").TimeAfter(time.Now()))
+	assert.Error(t, err)
+}
+
+func TestGenerated(t *testing.T) {
+	e := test.Test_TWO
+	now := time.Now()
+	msgs := []*test.Test{
+		nil,
+		{},
+		{
+			StringField:          "whatever",
+			NumberField:          42,
+			BoolField:            true,
+			EnumField:            test.Test_ONE,
+			MessageField:         &test.Test{StringField: "nested", OptionalNumberField: proto.Int64(0)},
+			RepeatedStringField:  []string{"one", "two"},
+			RepeatedMessageField: []*test.Test{{StringField: "one"}, {EnumField: test.Test_TWO}},
+			NumberValueField:     wrapperspb.Int64(42),
+			StringValueField:     wrapperspb.String("whatever"),
+			BoolValueField:       wrapperspb.Bool(false),
+			TimeValueField:       timestamppb.New(now),
+			DurationValueField:   durationpb.New(time.Second),
+			OptionalStringField:  proto.String(""),
+			OptionalNumberField:  proto.Int64(42),
+			OptionalBoolField:    proto.Bool(false),
+			OptionalEnumField:    &e,
+			Choice:               &test.Test_OneofMessageField{OneofMessageField: &test.Test{StringField: "oneof"}},
+		},
+		{EnumField: test.Test_Type(42), Choice: &test.Test_OneofStringField{}},
+		{Choice: &test.Test_OneofNumberField{OneofNumberField: 42}},
+	}
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringEquals("whatever"),
+		filters.Where("string_field").StringNotEquals("whatever"),
+		filters.Where("string_field").StringRegex("^what"),
+		filters.Where("string_field").StringRegex("("),
+		filters.Where("string_field").NumberEquals(0),
+		filters.Where("string_field").Null(),
+		filters.Where("string_field.string_field").StringEquals(""),
+		filters.Where("number_field").NumberSup(10),
+		filters.Where("number_field").NumberNotIN(0, 42),
+		filters.Where("bool_field").True(),
+		filters.Where("bool_field").StringEquals("true"),
+		filters.Where("enum_field").StringEquals("ONE"),
+		filters.Where("enum_field").StringNotEquals("ONE"),
+		filters.Where("enum_field").NumberEquals(0),
+		filters.Where("message_field").Null(),
+		filters.Where("message_field").NotNull(),
+		filters.Where("message_field").StringEquals(""),
+		filters.Where("message_field.string_field").StringEquals("nested"),
+		filters.Where("message_field.optional_number_field").Null(),
+		filters.Where("message_field.message_field.string_field").StringNotEquals("nested"),
+		filters.Where("message_field.unknown").StringEquals(""),
+		filters.Where("repeated_string_field").StringEquals("two"),
+		filters.Where("repeated_string_field").StringNotEquals("two"),
+		filters.Where("repeated_string_field").Null(),
+		filters.Where("repeated_message_field").Null(),
+		filters.Where("repeated_message_field.string_field").StringEquals("one"),
+		filters.Where("repeated_message_field.enum_field").StringNotEquals("TWO"),
+		filters.Where("repeated_message_field.unknown").StringEquals(""),
+		filters.Where("number_value_field").NumberEquals(0),
+		filters.Where("number_value_field").NumberNotEquals(42),
+		filters.Where("number_value_field").Null(),
+		filters.Where("string_value_field").StringEquals(""),
+		filters.Where("string_value_field").StringNotEquals("whatever"),
+		filters.Where("bool_value_field").False(),
+		filters.Where("bool_value_field").NotNull(),
+		filters.Where("time_value_field").TimeEquals(now),
+		filters.Where("time_value_field").TimeNotEquals(now),
+		filters.Where("time_value_field").TimeBefore(now.Add(time.Hour)),
+		filters.Where("time_value_field").Null(),
+		filters.Where("time_value_field").NumberEquals(0),
+		filters.Where("duration_value_field").DurationEquals(time.Second),
+		filters.Where("duration_value_field").DurationInf(time.Minute),
+		filters.Where("duration_value_field").TimeEquals(now),
+		filters.Where("optional_string_field").StringEquals(""),
+		filters.Where("optional_string_field").StringNotEquals("a"),
+		filters.Where("optional_string_field").Null(),
+		filters.Where("optional_number_field").NumberEquals(42),
+		filters.Where("optional_number_field").NumberNotEquals(42),
+		filters.Where("optional_number_field").NotNull(),
+		filters.Where("optional_bool_field").False(),
+		filters.Where("optional_enum_field").StringEquals("TWO"),
+		filters.Where("optional_enum_field").StringNotEquals("TWO"),
+		filters.Where("optional_enum_field").Null(),
+		filters.Where("oneof_string_field").StringEquals(""),
+		filters.Where("oneof_string_field").StringNotEquals("a"),
+		filters.Where("oneof_string_field").NumberEquals(0),
+		filters.Where("oneof_string_field.string_field").StringEquals(""),
+		filters.Where("oneof_number_field").NumberEquals(42),
+		filters.Where("oneof_message_field").NotNull(),
+		filters.Where("oneof_message_field.string_field").StringNotEquals("oneof"),
+		filters.Where("oneof_message_field.unknown").StringEquals(""),
+		filters.Where("*").StringEquals("whatever"),
+		filters.Where("unknown").StringEquals(""),
+		filters.Where("string_field").StringEquals("whatever").AndWhere("number_field").NumberEquals(42).OrWhere("bool_field").True(),
+		filters.Where("string_field").StringEquals("whatever").AndWhere("unknown").True(),
+	}
+	generated := NewMatcher()
+	reflective := NewMatcher().(*matcher)
+	reflective.reflective = true
+	for _, msg := range msgs {
+		for _, f := range fs {
+			for _, f := range []filters.FieldFilterer{f, filters.Not(f)} {
+				want, wantErr := reflective.Match(msg, f)
+				got, err := generated.Match(msg, f)
+				if wantErr != nil {
+					assert.Error(t, err, f.Expr().Format())
+					continue
+				}
+				if assert.NoError(t, err, f.Expr().Format()) {
+					assert.Equal(t, want, got, "%v: %s", msg, f.Expr().Format())
+				}
+			}
+		}
+	}
+	ok, err := msgs[2].MatchFilter(filters.Where("message_field.string_field").StringEquals("nested").Expr())
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = msgs[2].MatchFilter(filters.Where("*").StringEquals("whatever").Expr())
+	assert.ErrorIs(t, err, reflect.ErrUnsupported)
+}
+
+func TestFilterBuilder(t *testing.T) {
+	assert := assert.New(t)
+	m := &test.Test{
+		NumberField:          42,
+		EnumField:            test.Test_ONE,
+		MessageField:         &test.Test{StringField: "nested"},
+		RepeatedMessageField: []*test.Test{{}, {BoolField: true}},
+		TimeValueField:       timestamppb.Now(),
+	}
+	f := test.TestFilterBy
+	assert.Equal("message_field.message_field.string_field", f.MessageField().MessageField().StringField.Equals("").Field)
+	assert.True(MatchFilters(m,
+		f.NumberField.Sup(3),
+		f.EnumField.Equals("ONE"),
+		f.MessageField().StringField.Equals("nested"),
+		f.MessageField().MessageField().Null(),
+		f.RepeatedMessageField().BoolField.True(),
+		f.TimeValueField.Before(time.Now().Add(time.Hour)),
+		f.OptionalNumberField.Null(),
+	))
+	assert.False(MatchFilters(m, f.OneofMessageField().StringField.Equals("")))
+	_, err := MatchFilters(m, f.Null())
+	assert.Error(err)
+}
+
+func TestMatchContext(t *testing.T) {
+	m := &test.Test{
+		RepeatedStringField:  []string{"a", "b", "c"},
+		RepeatedMessageField: []*test.Test{{StringField: "a"}, {StringField: "b"}},
+	}
+	f := filters.Where("repeated_string_field").StringEquals("c")
+	ok, err := MatchContext(context.Background(), m, f)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = MatchContext(ctx, m, f)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	l := NewMatcher(WithLimits(filters.Limits{MaxListElements: 2, MaxDepth: 1}))
+	_, err = l.Match(m, f)
+	assert.ErrorIs(t, err, filters.ErrLimitExceeded)
+	ok, err = l.Match(m, filters.Where("repeated_string_field").StringEquals("b"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = l.Match(m, filters.Where("repeated_message_field.string_field").StringEquals("b"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = l.Match(m, filters.Where("string_field").StringEquals("").And(filters.Where("bool_field").False()))
+	var e *filters.LimitError
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, "MaxDepth", e.Limit)
+	_, err = l.MatchFilters(m, f.Expr().Condition)
+	assert.ErrorIs(t, err, filters.ErrLimitExceeded)
+}
@@ -0,0 +1,323 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package mongo translates the filters expressions to MongoDB query
+// documents.
+package mongo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// KeyFunc returns the document key of the field.
+type KeyFunc func(fd protoreflect.FieldDescriptor) string
+
+// ProtoNames selects the fields by their name, e.g. string_field.
+func ProtoNames(fd protoreflect.FieldDescriptor) string {
+	return string(fd.Name())
+}
+
+// JSONNames selects the fields by their JSON name, e.g. stringField.
+func JSONNames(fd protoreflect.FieldDescriptor) string {
+	return fd.JSONName()
+}
+
+// Option configures the query.
+type Option func(o *options)
+
+type options struct {
+	keys KeyFunc
+}
+
+// WithKeys sets the function returning the fields document keys.
+// It defaults to ProtoNames.
+func WithKeys(fn KeyFunc) Option {
+	return func(o *options) {
+		o.keys = fn
+	}
+}
+
+// Query returns the query document selecting the documents of the message
+// described by md matched by the filter.
+// A nil expression returns an empty query document, matching all the
+// documents.
+// The paths through repeated fields are matched with $elemMatch, and the
+// negated filters on repeated scalar fields are not supported.
+// The documents are expected to hold the zero values of the non optional
+// fields, the enums as numbers, the timestamps as dates and the durations
+// as nanoseconds.
+func Query(md protoreflect.MessageDescriptor, f filters.FieldFilterer, opts ...Option) (map[string]any, error) {
+	o := options{keys: ProtoNames}
+	for _, v := range opts {
+		v(&o)
+	}
+	if f == nil || f.Expr() == nil {
+		return map[string]any{}, nil
+	}
+	b := &builder{md: md, keys: o.keys}
+	return b.expr(f.Expr())
+}
+
+type builder struct {
+	md   protoreflect.MessageDescriptor
+	keys KeyFunc
+}
+
+func (b *builder) expr(e *filters.Expression) (map[string]any, error) {
+	var conj []any
+	if e.Condition != nil {
+		q, err := b.fieldFilter(e.Condition)
+		if err != nil {
+			return nil, err
+		}
+		conj = append(conj, q)
+	}
+	for _, v := range e.AndExprs {
+		q, err := b.expr(v)
+		if err != nil {
+			return nil, err
+		}
+		conj = append(conj, q)
+	}
+	var q map[string]any
+	switch len(conj) {
+	case 0:
+		q = map[string]any{}
+	case 1:
+		q = conj[0].(map[string]any)
+	default:
+		q = map[string]any{"$and": conj}
+	}
+	if len(e.OrExprs) == 0 {
+		return q, nil
+	}
+	disj := []any{q}
+	for _, v := range e.OrExprs {
+		q, err := b.expr(v)
+		if err != nil {
+			return nil, err
+		}
+		disj = append(disj, q)
+	}
+	return map[string]any{"$or": disj}, nil
+}
+
+func (b *builder) fieldFilter(ff *filters.FieldFilter) (map[string]any, error) {
+	var fds []protoreflect.FieldDescriptor
+	md := b.md
+	for _, v := range strings.Split(ff.GetField(), ".") {
+		if md == nil {
+			return nil, fmt.Errorf("mongo: %s: not a message path", ff.GetField())
+		}
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			return nil, fmt.Errorf("mongo: %w", &filters.UnknownFieldError{Message: string(md.FullName()), Path: v})
+		}
+		if fd.IsMap() {
+			return nil, fmt.Errorf("mongo: %s: map fields are not supported", ff.GetField())
+		}
+		fds = append(fds, fd)
+		md = fd.Message()
+	}
+	q, err := b.path(fds, ff.GetFilter())
+	if err != nil {
+		return nil, fmt.Errorf("mongo: %s: %w", ff.GetField(), err)
+	}
+	return q, nil
+}
+
+// path returns the query document applying the filter to the fields path,
+// the fields up to the first repeated one being joined with dots
+func (b *builder) path(fds []protoreflect.FieldDescriptor, f *filters.Filter) (map[string]any, error) {
+	var keys []string
+	for i, fd := range fds {
+		keys = append(keys, b.keys(fd))
+		if !fd.IsList() {
+			continue
+		}
+		key := strings.Join(keys, ".")
+		if i == len(fds)-1 {
+			if f.GetNot() {
+				return nil, fmt.Errorf("negated filters on repeated fields are not supported")
+			}
+			c, err := b.condition(fd, f)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{key: map[string]any{"$elemMatch": c}}, nil
+		}
+		q, err := b.path(fds[i+1:], f)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{key: map[string]any{"$elemMatch": q}}, nil
+	}
+	fd := fds[len(fds)-1]
+	c, err := b.condition(fd, f)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.GetMatch().(*filters.Filter_Null); !ok && f.GetNot() {
+		c = map[string]any{"$not": c}
+		// the filters never match unset oneof fields
+		if o := fd.ContainingOneof(); o != nil && !o.IsSynthetic() {
+			c["$exists"] = true
+		}
+	}
+	return map[string]any{strings.Join(keys, "."): c}, nil
+}
+
+// condition returns the operators document of the filter, not negated
+// except for the null filter
+func (b *builder) condition(fd protoreflect.FieldDescriptor, f *filters.Filter) (map[string]any, error) {
+	switch m := f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		if f.GetNot() {
+			return map[string]any{"$exists": true, "$ne": nil}, nil
+		}
+		// matches both the missing and the null values
+		return map[string]any{"$eq": nil}, nil
+	case *filters.Filter_String_:
+		if fd.Kind() == protoreflect.EnumKind {
+			return enumCondition(fd, m.String_)
+		}
+		return stringCondition(m.String_)
+	case *filters.Filter_Number:
+		switch c := m.Number.GetCondition().(type) {
+		case *filters.NumberFilter_Equals:
+			return map[string]any{"$eq": c.Equals}, nil
+		case *filters.NumberFilter_Inf:
+			return map[string]any{"$lt": c.Inf}, nil
+		case *filters.NumberFilter_Sup:
+			return map[string]any{"$gt": c.Sup}, nil
+		case *filters.NumberFilter_In_:
+			return map[string]any{"$in": values(c.In.GetValues())}, nil
+		}
+	case *filters.Filter_Bool:
+		return map[string]any{"$eq": m.Bool.GetEquals()}, nil
+	case *filters.Filter_Time:
+		switch c := m.Time.GetCondition().(type) {
+		case *filters.TimeFilter_Equals:
+			return map[string]any{"$eq": c.Equals.AsTime()}, nil
+		case *filters.TimeFilter_Before:
+			return map[string]any{"$lt": c.Before.AsTime()}, nil
+		case *filters.TimeFilter_After:
+			return map[string]any{"$gt": c.After.AsTime()}, nil
+		}
+	case *filters.Filter_Duration:
+		switch c := m.Duration.GetCondition().(type) {
+		case *filters.DurationFilter_Equals:
+			return map[string]any{"$eq": int64(c.Equals.AsDuration())}, nil
+		case *filters.DurationFilter_Inf:
+			return map[string]any{"$lt": int64(c.Inf.AsDuration())}, nil
+		case *filters.DurationFilter_Sup:
+			return map[string]any{"$gt": int64(c.Sup.AsDuration())}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported filter %q", f.Format())
+}
+
+func stringCondition(f *filters.StringFilter) (map[string]any, error) {
+	regex := func(re string) map[string]any {
+		return map[string]any{"$regex": re, "$options": "i"}
+	}
+	ci := f.GetCaseInsensitive()
+	switch c := f.GetCondition().(type) {
+	case *filters.StringFilter_Equals:
+		if ci {
+			return regex("^" + regexp.QuoteMeta(c.Equals) + "$"), nil
+		}
+		return map[string]any{"$eq": c.Equals}, nil
+	case *filters.StringFilter_HasPrefix:
+		if ci {
+			return regex("^" + regexp.QuoteMeta(c.HasPrefix)), nil
+		}
+		return map[string]any{"$regex": "^" + regexp.QuoteMeta(c.HasPrefix)}, nil
+	case *filters.StringFilter_HasSuffix:
+		if ci {
+			return regex(regexp.QuoteMeta(c.HasSuffix) + "$"), nil
+		}
+		return map[string]any{"$regex": regexp.QuoteMeta(c.HasSuffix) + "$"}, nil
+	case *filters.StringFilter_Contains:
+		if ci {
+			return regex(regexp.QuoteMeta(c.Contains)), nil
+		}
+		return map[string]any{"$regex": regexp.QuoteMeta(c.Contains)}, nil
+	case *filters.StringFilter_Regex:
+		// as when matching messages, the regex filters are case sensitive
+		return map[string]any{"$regex": c.Regex}, nil
+	case *filters.StringFilter_In_:
+		if ci {
+			var alts []string
+			for _, v := range c.In.GetValues() {
+				alts = append(alts, regexp.QuoteMeta(v))
+			}
+			return regex("^(?:" + strings.Join(alts, "|") + ")$"), nil
+		}
+		return map[string]any{"$in": values(c.In.GetValues())}, nil
+	case *filters.StringFilter_Inf:
+		if !ci {
+			return map[string]any{"$lt": c.Inf}, nil
+		}
+	case *filters.StringFilter_Sup:
+		if !ci {
+			return map[string]any{"$gt": c.Sup}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported string filter")
+}
+
+// enumCondition matches the enum numbers of the names
+func enumCondition(fd protoreflect.FieldDescriptor, f *filters.StringFilter) (map[string]any, error) {
+	var names []string
+	switch c := f.GetCondition().(type) {
+	case *filters.StringFilter_Equals:
+		names = []string{c.Equals}
+	case *filters.StringFilter_In_:
+		names = c.In.GetValues()
+	default:
+		return nil, fmt.Errorf("only equality is supported on enum names")
+	}
+	if f.GetCaseInsensitive() {
+		return nil, fmt.Errorf("case insensitive equality is not supported on enum names")
+	}
+	var numbers []any
+	for _, v := range names {
+		ev := fd.Enum().Values().ByName(protoreflect.Name(v))
+		if ev == nil {
+			return nil, fmt.Errorf("%s has no value %q", fd.Enum().FullName(), v)
+		}
+		numbers = append(numbers, int32(ev.Number()))
+	}
+	if _, ok := f.GetCondition().(*filters.StringFilter_Equals); ok {
+		return map[string]any{"$eq": numbers[0]}, nil
+	}
+	return map[string]any{"$in": numbers}, nil
+}
+
+func values[T any](s []T) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,188 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mongo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/mongo"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+var md = (&test.Test{}).ProtoReflect().Descriptor()
+
+type M = map[string]any
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		f    filters.FieldFilterer
+		want M
+	}{
+		{
+			name: "string equals",
+			f:    filters.Where("string_field").StringEquals("a"),
+			want: M{"string_field": M{"$eq": "a"}},
+		},
+		{
+			name: "string not equals",
+			f:    filters.Where("string_field").StringNotEquals("a"),
+			want: M{"string_field": M{"$not": M{"$eq": "a"}}},
+		},
+		{
+			name: "string case insensitive equals",
+			f:    filters.Where("string_field").StringIEquals("a.b"),
+			want: M{"string_field": M{"$regex": `^a\.b$`, "$options": "i"}},
+		},
+		{
+			name: "string prefix",
+			f:    filters.Where("string_field").StringHasPrefix("a+"),
+			want: M{"string_field": M{"$regex": `^a\+`}},
+		},
+		{
+			name: "string case insensitive suffix",
+			f:    filters.Where("string_field").StringIHasSuffix("a"),
+			want: M{"string_field": M{"$regex": "a$", "$options": "i"}},
+		},
+		{
+			name: "string regex",
+			f:    filters.Where("string_field").StringRegex("^a+$"),
+			want: M{"string_field": M{"$regex": "^a+$"}},
+		},
+		{
+			name: "string in",
+			f:    filters.Where("string_field").StringIN("a", "b"),
+			want: M{"string_field": M{"$in": []any{"a", "b"}}},
+		},
+		{
+			name: "string inf",
+			f:    filters.Where("string_field").StringInf("a"),
+			want: M{"string_field": M{"$lt": "a"}},
+		},
+		{
+			name: "number sup",
+			f:    filters.Where("number_field").NumberSup(1),
+			want: M{"number_field": M{"$gt": 1.0}},
+		},
+		{
+			name: "number not in",
+			f:    filters.Where("number_field").NumberNotIN(1, 2),
+			want: M{"number_field": M{"$not": M{"$in": []any{1.0, 2.0}}}},
+		},
+		{
+			name: "bool",
+			f:    filters.Where("bool_field").True(),
+			want: M{"bool_field": M{"$eq": true}},
+		},
+		{
+			name: "enum name",
+			f:    filters.Where("enum_field").StringIN("ONE", "TWO"),
+			want: M{"enum_field": M{"$in": []any{int32(1), int32(2)}}},
+		},
+		{
+			name: "null",
+			f:    filters.Where("message_field").Null(),
+			want: M{"message_field": M{"$eq": nil}},
+		},
+		{
+			name: "not null",
+			f:    filters.Where("optional_string_field").NotNull(),
+			want: M{"optional_string_field": M{"$exists": true, "$ne": nil}},
+		},
+		{
+			name: "not oneof",
+			f:    filters.Where("oneof_string_field").StringNotEquals("a"),
+			want: M{"oneof_string_field": M{"$exists": true, "$not": M{"$eq": "a"}}},
+		},
+		{
+			name: "time",
+			f:    filters.Where("time_value_field").TimeAfter(time.Unix(1, 0)),
+			want: M{"time_value_field": M{"$gt": time.Unix(1, 0).UTC()}},
+		},
+		{
+			name: "duration",
+			f:    filters.Where("duration_value_field").DurationInf(time.Second),
+			want: M{"duration_value_field": M{"$lt": int64(time.Second)}},
+		},
+		{
+			name: "nested",
+			f:    filters.Where("message_field.string_field").StringEquals("a"),
+			want: M{"message_field.string_field": M{"$eq": "a"}},
+		},
+		{
+			name: "repeated scalar",
+			f:    filters.Where("repeated_string_field").StringHasPrefix("a"),
+			want: M{"repeated_string_field": M{"$elemMatch": M{"$regex": "^a"}}},
+		},
+		{
+			name: "repeated message",
+			f:    filters.Where("message_field.repeated_message_field.number_field").NumberNotEquals(1),
+			want: M{"message_field.repeated_message_field": M{"$elemMatch": M{"number_field": M{"$not": M{"$eq": 1.0}}}}},
+		},
+		{
+			name: "nested repeated",
+			f:    filters.Where("repeated_message_field.repeated_string_field").StringEquals("a"),
+			want: M{"repeated_message_field": M{"$elemMatch": M{"repeated_string_field": M{"$elemMatch": M{"$eq": "a"}}}}},
+		},
+		{
+			name: "and",
+			f:    filters.Where("string_field").StringEquals("a").AndWhere("number_field").NumberEquals(1),
+			want: M{"$and": []any{M{"string_field": M{"$eq": "a"}}, M{"number_field": M{"$eq": 1.0}}}},
+		},
+		{
+			name: "or",
+			f:    filters.Where("string_field").StringEquals("a").AndWhere("number_field").NumberEquals(1).OrWhere("bool_field").True(),
+			want: M{"$or": []any{
+				M{"$and": []any{M{"string_field": M{"$eq": "a"}}, M{"number_field": M{"$eq": 1.0}}}},
+				M{"bool_field": M{"$eq": true}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mongo.Query(md, tt.f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	q, err := mongo.Query(md, nil)
+	require.NoError(t, err)
+	assert.Equal(t, M{}, q)
+	q, err = mongo.Query(md, filters.Where("message_field.string_field").StringEquals("a"), mongo.WithKeys(mongo.JSONNames))
+	require.NoError(t, err)
+	assert.Equal(t, M{"messageField.stringField": M{"$eq": "a"}}, q)
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("unknown").StringEquals("a"),
+		filters.Where("string_field.value").StringEquals("a"),
+		filters.Where("repeated_string_field").StringNotEquals("a"),
+		filters.Where("string_field").StringIInf("a"),
+		filters.Where("enum_field").StringEquals("THREE"),
+		filters.Where("enum_field").StringHasPrefix("O"),
+		filters.Where("string_field"),
+	} {
+		_, err := mongo.Query(md, f)
+		assert.Error(t, err, f.Expr().Format())
+	}
+}
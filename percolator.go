@@ -0,0 +1,673 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/reflect"
+)
+
+// Percolator finds the stored filters matching a message.
+// Filters are indexed by field path using hash buckets for their equality
+// conditions, interval trees for their range conditions and prefix tries for
+// their prefix conditions, so that only the filters which may match a message
+// are evaluated.
+type Percolator struct {
+	m     *matcher
+	mu    sync.RWMutex
+	rules map[string]*rule
+	types map[pref.FullName]*percolatorIndex
+}
+
+type rule struct {
+	id     string
+	t      pref.FullName
+	expr   *filters.Expression
+	probes []probe
+}
+
+type probeKind int
+
+const (
+	probeEquals probeKind = iota
+	probeRange
+	probePrefix
+)
+
+// probe is an indexed condition on a field path: one of the rule probes must
+// be satisfied by a message for the rule to match it
+type probe struct {
+	kind probeKind
+	path string
+	// key is the equality key or the prefix
+	key string
+	// low and high are the range inclusive bounds
+	low, high float64
+}
+
+// anchor is a field path and value literal key
+type anchor struct {
+	path string
+	key  string
+}
+
+type percolatorIndex struct {
+	// paths counts the probes using each field path
+	paths    map[string]int
+	equals   map[anchor]map[string]struct{}
+	ranges   map[string]*intervalTree
+	prefixes map[string]*trie
+	always   map[string]struct{}
+}
+
+// NewPercolator creates an empty Percolator.
+func NewPercolator() *Percolator {
+	return &Percolator{
+		m:     &matcher{cache: make(map[pref.FullName]map[string]*lookup), resolver: protoregistry.GlobalTypes},
+		rules: make(map[string]*rule),
+		types: make(map[pref.FullName]*percolatorIndex),
+	}
+}
+
+// Add stores the filter for the messages of type t under the given id,
+// replacing any previous filter using the same id.
+// A nil filter matches all the messages of type t.
+// The filter paths are validated if t is registered in the global registry.
+func (p *Percolator) Add(id string, t pref.FullName, f filters.FieldFilterer) error {
+	var expr *filters.Expression
+	if f != nil {
+		expr = f.Expr()
+	}
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(t); err == nil && expr != nil {
+		if err := p.validate(mt.New().Interface(), expr); err != nil {
+			return err
+		}
+	}
+	r := &rule{id: id, t: t, expr: expr}
+	if probes, ok := expressionProbes(expr); ok {
+		r.probes = probes
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(id)
+	p.rules[id] = r
+	x, ok := p.types[t]
+	if !ok {
+		x = &percolatorIndex{
+			paths:    make(map[string]int),
+			equals:   make(map[anchor]map[string]struct{}),
+			ranges:   make(map[string]*intervalTree),
+			prefixes: make(map[string]*trie),
+			always:   make(map[string]struct{}),
+		}
+		p.types[t] = x
+	}
+	if r.probes == nil {
+		x.always[id] = struct{}{}
+		return nil
+	}
+	for _, v := range r.probes {
+		x.paths[v.path]++
+		switch v.kind {
+		case probeEquals:
+			a := anchor{path: v.path, key: v.key}
+			ids, ok := x.equals[a]
+			if !ok {
+				ids = make(map[string]struct{})
+				x.equals[a] = ids
+			}
+			ids[id] = struct{}{}
+		case probeRange:
+			t, ok := x.ranges[v.path]
+			if !ok {
+				t = &intervalTree{rules: make(map[string][]interval)}
+				x.ranges[v.path] = t
+			}
+			t.add(id, v.low, v.high)
+		case probePrefix:
+			t, ok := x.prefixes[v.path]
+			if !ok {
+				t = &trie{}
+				x.prefixes[v.path] = t
+			}
+			t.add(v.key, id)
+		}
+	}
+	return nil
+}
+
+// Remove removes the filter stored with the given id.
+func (p *Percolator) Remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(id)
+}
+
+func (p *Percolator) remove(id string) {
+	r, ok := p.rules[id]
+	if !ok {
+		return
+	}
+	delete(p.rules, id)
+	x := p.types[r.t]
+	delete(x.always, id)
+	for _, v := range r.probes {
+		if x.paths[v.path]--; x.paths[v.path] == 0 {
+			delete(x.paths, v.path)
+		}
+		switch v.kind {
+		case probeEquals:
+			a := anchor{path: v.path, key: v.key}
+			delete(x.equals[a], id)
+			if len(x.equals[a]) == 0 {
+				delete(x.equals, a)
+			}
+		case probeRange:
+			if t := x.ranges[v.path]; t.remove(id) {
+				delete(x.ranges, v.path)
+			}
+		case probePrefix:
+			if t := x.prefixes[v.path]; t.remove(v.key, id) {
+				delete(x.prefixes, v.path)
+			}
+		}
+	}
+	if len(x.always) == 0 && len(x.paths) == 0 {
+		delete(p.types, r.t)
+	}
+}
+
+// Percolate returns the sorted ids of the filters matching the message.
+func (p *Percolator) Percolate(msg proto.Message) ([]string, error) {
+	if msg == nil {
+		return nil, errors.New("message is null")
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ids, err := p.percolate(msg)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(ids))
+	for id := range ids {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (p *Percolator) percolate(msg proto.Message) (map[string]struct{}, error) {
+	x, ok := p.types[msg.ProtoReflect().Descriptor().FullName()]
+	if !ok {
+		return nil, nil
+	}
+	candidates, err := p.candidates(x, msg)
+	if err != nil {
+		return nil, err
+	}
+	for id := range candidates {
+		expr := p.rules[id].expr
+		if expr == nil {
+			continue
+		}
+		ok, err := p.m.matchExpression(nil, msg, expr)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			delete(candidates, id)
+		}
+	}
+	return candidates, nil
+}
+
+// candidates returns the filters which may match the message.
+func (p *Percolator) candidates(x *percolatorIndex, msg proto.Message) (map[string]struct{}, error) {
+	out := make(map[string]struct{})
+	for id := range x.always {
+		out[id] = struct{}{}
+	}
+	for path := range x.paths {
+		rt := x.ranges[path]
+		pt := x.prefixes[path]
+		_, err := p.m.fields(msg.ProtoReflect(), path, func(msg pref.Message, fds []pref.FieldDescriptor, _ bool) (bool, error) {
+			for _, v := range fieldValues(msg, fds, nil) {
+				if k := v.key(); k != "" {
+					for id := range x.equals[anchor{path: path, key: k}] {
+						out[id] = struct{}{}
+					}
+				}
+				if rt != nil && (v.kind == numberValue || v.kind == timeValue) {
+					rt.stab(v.n, out)
+				}
+				if pt != nil && v.kind == stringValue {
+					pt.walk(v.s, out)
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (p *Percolator) validate(msg proto.Message, expr *filters.Expression) error {
+	if expr == nil {
+		return nil
+	}
+	if expr.Condition != nil {
+		if _, err := p.m.lookup(msg.ProtoReflect().Descriptor(), expr.Condition.Field); err != nil {
+			return err
+		}
+	}
+	for _, v := range expr.AndExprs {
+		if err := p.validate(msg, v); err != nil {
+			return err
+		}
+	}
+	for _, v := range expr.OrExprs {
+		if err := p.validate(msg, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expressionProbes returns the probes one of which must be satisfied by a
+// message for the expression to match, or false if there is no such set.
+func expressionProbes(expr *filters.Expression) ([]probe, bool) {
+	if expr == nil {
+		return nil, false
+	}
+	// the condition and the and expressions are all required, so any of them
+	// can anchor the conjunction: prefer equalities as the most selective
+	var out []probe
+	var ok bool
+	pick := func(probes []probe, found bool) bool {
+		if !found {
+			return false
+		}
+		if !ok {
+			out, ok = probes, true
+		}
+		for _, v := range probes {
+			if v.kind != probeEquals {
+				return false
+			}
+		}
+		out = probes
+		return true
+	}
+	if !pick(filterProbes(expr.Condition)) {
+		for _, v := range expr.AndExprs {
+			if pick(expressionProbes(v)) {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	for _, v := range expr.OrExprs {
+		probes, ok := expressionProbes(v)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, probes...)
+	}
+	return out, true
+}
+
+func filterProbes(ff *filters.FieldFilter) ([]probe, bool) {
+	if ff == nil || ff.Filter == nil || ff.Filter.Not {
+		return nil, false
+	}
+	var out []probe
+	equals := func(k string) {
+		out = append(out, probe{kind: probeEquals, path: ff.Field, key: k})
+	}
+	between := func(low, high float64) {
+		out = append(out, probe{kind: probeRange, path: ff.Field, low: low, high: high})
+	}
+	switch f := ff.Filter.Match.(type) {
+	case *filters.Filter_String_:
+		if f.String_.GetCaseInsensitive() {
+			return nil, false
+		}
+		switch c := f.String_.GetCondition().(type) {
+		case *filters.StringFilter_Equals:
+			equals(stringKey(c.Equals))
+		case *filters.StringFilter_In_:
+			for _, v := range c.In.GetValues() {
+				equals(stringKey(v))
+			}
+		case *filters.StringFilter_HasPrefix:
+			out = append(out, probe{kind: probePrefix, path: ff.Field, key: c.HasPrefix})
+		}
+	case *filters.Filter_Number:
+		switch c := f.Number.GetCondition().(type) {
+		case *filters.NumberFilter_Equals:
+			equals(numberKey(c.Equals))
+		case *filters.NumberFilter_In_:
+			for _, v := range c.In.GetValues() {
+				equals(numberKey(v))
+			}
+		case *filters.NumberFilter_Sup:
+			between(c.Sup, math.Inf(1))
+		case *filters.NumberFilter_Inf:
+			between(math.Inf(-1), c.Inf)
+		}
+	case *filters.Filter_Bool:
+		equals(boolKey(f.Bool.GetEquals()))
+	// times and durations are indexed by their seconds, which keeps the ordering
+	// and is exactly represented by a float64 for any valid timestamp
+	case *filters.Filter_Time:
+		switch c := f.Time.GetCondition().(type) {
+		case *filters.TimeFilter_Equals:
+			between(float64(c.Equals.GetSeconds()), float64(c.Equals.GetSeconds()))
+		case *filters.TimeFilter_Before:
+			between(math.Inf(-1), float64(c.Before.GetSeconds()))
+		case *filters.TimeFilter_After:
+			between(float64(c.After.GetSeconds()), math.Inf(1))
+		}
+	case *filters.Filter_Duration:
+		switch c := f.Duration.GetCondition().(type) {
+		case *filters.DurationFilter_Equals:
+			between(float64(c.Equals.GetSeconds()), float64(c.Equals.GetSeconds()))
+		case *filters.DurationFilter_Inf:
+			between(math.Inf(-1), float64(c.Inf.GetSeconds()))
+		case *filters.DurationFilter_Sup:
+			between(float64(c.Sup.GetSeconds()), math.Inf(1))
+		}
+	}
+	if len(out) == 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	numberValue
+	boolValue
+	// timeValue is the seconds of a timestamp or a duration
+	timeValue
+)
+
+// value is a scalar read from a message field
+type value struct {
+	kind valueKind
+	s    string
+	n    float64
+	b    bool
+}
+
+// key returns the value equality key, if any
+func (v value) key() string {
+	switch v.kind {
+	case stringValue:
+		return stringKey(v.s)
+	case numberValue:
+		return numberKey(v.n)
+	case boolValue:
+		return boolKey(v.b)
+	}
+	return ""
+}
+
+// fieldValues returns the values found at the field path, as seen by the
+// matcher. It may return values the matcher would not read, but never misses
+// one.
+func fieldValues(msg pref.Message, fds []pref.FieldDescriptor, out []value) []value {
+	if len(fds) == 0 {
+		return out
+	}
+	fd := fds[0]
+	if isUnsetRealOneofField(msg, fd) {
+		return out
+	}
+	rval := msg.Get(fd)
+	if len(fds) > 1 {
+		if fd.Kind() != pref.MessageKind {
+			return out
+		}
+		if fd.IsList() {
+			for i := 0; i < rval.List().Len(); i++ {
+				out = fieldValues(rval.List().Get(i).Message(), fds[1:], out)
+			}
+			return out
+		}
+		// the matcher reads the zero values of unset messages
+		return fieldValues(rval.Message(), fds[1:], out)
+	}
+	if fd.IsMap() {
+		return out
+	}
+	if fd.IsList() {
+		for i := 0; i < rval.List().Len(); i++ {
+			out = scalarValues(fd, rval.List().Get(i), out)
+		}
+		return out
+	}
+	return scalarValues(fd, rval, out)
+}
+
+func scalarValues(fd pref.FieldDescriptor, v pref.Value, out []value) []value {
+	switch fd.Kind() {
+	case pref.StringKind:
+		return append(out, value{kind: stringValue, s: v.String()})
+	case pref.BoolKind:
+		return append(out, value{kind: boolValue, b: v.Bool()})
+	case pref.EnumKind:
+		out = append(out, value{kind: numberValue, n: float64(v.Enum())})
+		if e := fd.Enum().Values().ByNumber(v.Enum()); e != nil {
+			out = append(out, value{kind: stringValue, s: string(e.Name())})
+		}
+		return out
+	case pref.Int32Kind, pref.Sint32Kind, pref.Int64Kind, pref.Sint64Kind,
+		pref.Sfixed32Kind, pref.Fixed32Kind, pref.Sfixed64Kind, pref.Fixed64Kind:
+		return append(out, value{kind: numberValue, n: float64(v.Int())})
+	case pref.Uint32Kind, pref.Uint64Kind:
+		return append(out, value{kind: numberValue, n: float64(v.Uint())})
+	case pref.FloatKind, pref.DoubleKind:
+		return append(out, value{kind: numberValue, n: v.Float()})
+	case pref.MessageKind:
+		// unset messages are read as their zero values
+		m := v.Message()
+		vfd := fd.Message().Fields().ByNumber(1)
+		switch reflect.WKType(fd.Message().FullName()) {
+		case reflect.StringValue:
+			return append(out, value{kind: stringValue, s: m.Get(vfd).String()})
+		case reflect.BoolValue:
+			return append(out, value{kind: boolValue, b: m.Get(vfd).Bool()})
+		case reflect.DoubleValue, reflect.FloatValue:
+			return append(out, value{kind: numberValue, n: m.Get(vfd).Float()})
+		case reflect.Int64Value, reflect.Int32Value:
+			return append(out, value{kind: numberValue, n: float64(m.Get(vfd).Int())})
+		case reflect.UInt64Value, reflect.UInt32Value:
+			return append(out, value{kind: numberValue, n: float64(m.Get(vfd).Uint())})
+		case reflect.Timestamp, reflect.Duration:
+			return append(out, value{kind: timeValue, n: float64(m.Get(vfd).Int())})
+		}
+	}
+	return out
+}
+
+func stringKey(s string) string {
+	return "s:" + s
+}
+
+func numberKey(f float64) string {
+	if f == 0 {
+		// -0 == 0
+		f = 0
+	}
+	return "n:" + strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func boolKey(b bool) string {
+	return "b:" + strconv.FormatBool(b)
+}
+
+type interval struct {
+	low, high float64
+	id        string
+}
+
+// intervalTree is a static interval tree rebuilt on the first stab following
+// a change: the intervals are sorted by their lower bound, each subtree root
+// being the middle of its range and holding the subtree greatest upper bound.
+type intervalTree struct {
+	mu    sync.Mutex
+	rules map[string][]interval
+	dirty bool
+	items []interval
+	max   []float64
+}
+
+func (t *intervalTree) add(id string, low, high float64) {
+	t.mu.Lock()
+	t.rules[id] = append(t.rules[id], interval{low: low, high: high, id: id})
+	t.dirty = true
+	t.mu.Unlock()
+}
+
+// remove removes the rule intervals and reports whether the tree is empty.
+func (t *intervalTree) remove(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.rules, id)
+	t.dirty = true
+	return len(t.rules) == 0
+}
+
+// stab adds to out the rules having an interval containing v.
+func (t *intervalTree) stab(v float64, out map[string]struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dirty {
+		t.build()
+	}
+	t.stabRange(0, len(t.items), v, out)
+}
+
+func (t *intervalTree) stabRange(lo, hi int, v float64, out map[string]struct{}) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	if t.max[mid] < v {
+		return
+	}
+	t.stabRange(lo, mid, v, out)
+	if it := t.items[mid]; it.low > v {
+		return
+	} else if v <= it.high {
+		out[it.id] = struct{}{}
+	}
+	t.stabRange(mid+1, hi, v, out)
+}
+
+func (t *intervalTree) build() {
+	t.items = t.items[:0]
+	for _, v := range t.rules {
+		t.items = append(t.items, v...)
+	}
+	sort.Slice(t.items, func(i, j int) bool {
+		return t.items[i].low < t.items[j].low
+	})
+	t.max = make([]float64, len(t.items))
+	t.buildRange(0, len(t.items))
+	t.dirty = false
+}
+
+func (t *intervalTree) buildRange(lo, hi int) float64 {
+	if lo >= hi {
+		return math.Inf(-1)
+	}
+	mid := (lo + hi) / 2
+	m := math.Max(t.items[mid].high, math.Max(t.buildRange(lo, mid), t.buildRange(mid+1, hi)))
+	t.max[mid] = m
+	return m
+}
+
+// trie is a byte-wise prefix tree of the rules prefixes.
+type trie struct {
+	children map[byte]*trie
+	ids      map[string]struct{}
+}
+
+func (t *trie) add(prefix, id string) {
+	n := t
+	for i := 0; i < len(prefix); i++ {
+		c, ok := n.children[prefix[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[byte]*trie)
+			}
+			c = &trie{}
+			n.children[prefix[i]] = c
+		}
+		n = c
+	}
+	if n.ids == nil {
+		n.ids = make(map[string]struct{})
+	}
+	n.ids[id] = struct{}{}
+}
+
+// remove removes the rule prefix and reports whether the node is empty.
+func (t *trie) remove(prefix, id string) bool {
+	if len(prefix) == 0 {
+		delete(t.ids, id)
+	} else if c, ok := t.children[prefix[0]]; ok && c.remove(prefix[1:], id) {
+		delete(t.children, prefix[0])
+	}
+	return len(t.ids) == 0 && len(t.children) == 0
+}
+
+// walk adds to out the rules having a prefix of s.
+func (t *trie) walk(s string, out map[string]struct{}) {
+	n := t
+	for i := 0; ; i++ {
+		for id := range n.ids {
+			out[id] = struct{}{}
+		}
+		if i == len(s) {
+			return
+		}
+		c, ok := n.children[s[i]]
+		if !ok {
+			return
+		}
+		n = c
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+func TestPercolator(t *testing.T) {
+	now := time.Now()
+	p := NewPercolator()
+	require.NoError(t, p.Add("eq", testType, filters.Where("number_field").NumberEquals(1)))
+	require.NoError(t, p.Add("sup", testType, filters.Where("number_field").NumberSup(1)))
+	require.NoError(t, p.Add("inf", testType, filters.Where("number_value_field").NumberInf(10)))
+	require.NoError(t, p.Add("prefix", testType, filters.Where("string_field").StringHasPrefix("hel")))
+	require.NoError(t, p.Add("nested", testType, filters.Where("repeated_message_field.string_field").StringHasPrefix("wor")))
+	require.NoError(t, p.Add("after", testType, filters.Where("time_value_field").TimeAfter(now)))
+	require.NoError(t, p.Add("duration", testType, filters.Where("duration_value_field").DurationInf(time.Second)))
+	require.NoError(t, p.Add("not", testType, filters.Where("number_field").NumberNotEquals(1)))
+
+	ids, err := p.Percolate(&test.Test{
+		NumberField:          2,
+		NumberValueField:     wrapperspb.Int64(11),
+		StringField:          "hello",
+		RepeatedMessageField: []*test.Test{{StringField: "hello"}, {StringField: "world"}},
+		TimeValueField:       timestamppb.New(now.Add(time.Nanosecond)),
+		DurationValueField:   durationpb.New(time.Second),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"after", "nested", "not", "prefix", "sup"}, ids)
+
+	ids, err = p.Percolate(&test.Test{NumberField: 1, StringField: "he"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"duration", "eq", "inf"}, ids)
+
+	x := p.types[testType]
+	cs, err := p.candidates(x, &test.Test{NumberField: 1, StringField: "he"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"duration": {}, "eq": {}, "inf": {}, "not": {}, "sup": {}}, cs)
+
+	for _, id := range []string{"eq", "sup", "inf", "prefix", "nested", "after", "duration", "not"} {
+		p.Remove(id)
+	}
+	assert.Empty(t, p.types)
+	assert.Empty(t, p.rules)
+
+	_, err = p.Percolate(nil)
+	assert.Error(t, err)
+	ids, err = p.Percolate(&wrapperspb.StringValue{})
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestPercolatorCandidates(t *testing.T) {
+	p := NewPercolator()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, p.Add(fmt.Sprint(i), testType, filters.Where("string_field").StringNotEquals("").And(filters.Where("number_field").NumberEquals(float64(i)).OrWhere("string_field").StringIN(fmt.Sprint(i)))))
+	}
+	require.NoError(t, p.Add("range", testType, filters.Where("number_field").NumberSup(0).AndWhere("number_field").NumberInf(2)))
+	cs, err := p.candidates(p.types[testType], &test.Test{NumberField: 1, StringField: "2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"1": {}, "2": {}, "range": {}}, cs)
+}
+
+func TestPercolatorAny(t *testing.T) {
+	md := envelope(t)
+	p := NewPercolator()
+	require.NoError(t, p.Add("explicit", md.FullName(), filters.Where("payload.(linka.cloud.test.Test).string_field").StringEquals("a")))
+	require.NoError(t, p.Add("implicit", md.FullName(), filters.Where("payload.number_field").NumberSup(0)))
+	require.NoError(t, p.Add("type", md.FullName(), filters.Where("payload.@type").StringHasSuffix("Test")))
+	a, err := anypb.New(&test.Test{StringField: "a"})
+	require.NoError(t, err)
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("payload"), pref.ValueOfMessage(a.ProtoReflect()))
+	ids, err := p.Percolate(m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"explicit", "type"}, ids)
+}
+
+func TestPercolatorMatcher(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	strs := []string{"", "a", "ab", "b", "ONE"}
+	nums := []float64{-1, 0, 1, 2, 1.5}
+	base := time.Unix(1000, 0)
+	randMsg := func() *test.Test {
+		m := &test.Test{
+			StringField:         strs[r.Intn(len(strs))],
+			NumberField:         int64(nums[r.Intn(len(nums))]),
+			EnumField:           test.Test_Type(r.Intn(3)),
+			RepeatedStringField: strs[:r.Intn(len(strs))],
+		}
+		if r.Intn(2) == 0 {
+			m.NumberValueField = wrapperspb.Int64(int64(nums[r.Intn(len(nums))]))
+		}
+		if r.Intn(2) == 0 {
+			m.TimeValueField = timestamppb.New(base.Add(time.Duration(r.Intn(5)-2) * time.Second / 2))
+		}
+		if r.Intn(2) == 0 {
+			m.Choice = &test.Test_OneofNumberField{OneofNumberField: int64(nums[r.Intn(len(nums))])}
+		}
+		return m
+	}
+	numFields := []string{"number_field", "number_value_field", "enum_field", "oneof_number_field"}
+	strFields := []string{"string_field", "repeated_string_field", "enum_field"}
+	randFilter := func() filters.Builder {
+		n := nums[r.Intn(len(nums))]
+		switch r.Intn(7) {
+		case 0:
+			return filters.Where(strFields[r.Intn(len(strFields))]).StringEquals(strs[r.Intn(len(strs))])
+		case 1:
+			return filters.Where(strFields[r.Intn(len(strFields))]).StringHasPrefix(strs[r.Intn(len(strs))])
+		case 2:
+			return filters.Where(numFields[r.Intn(len(numFields))]).NumberEquals(n)
+		case 3:
+			return filters.Where(numFields[r.Intn(len(numFields))]).NumberSup(n)
+		case 4:
+			return filters.Where(numFields[r.Intn(len(numFields))]).NumberInf(n)
+		case 5:
+			d := time.Duration(r.Intn(5)-2) * time.Second / 2
+			if r.Intn(2) == 0 {
+				return filters.Where("time_value_field").TimeBefore(base.Add(d))
+			}
+			return filters.Where("time_value_field").TimeAfter(base.Add(d))
+		default:
+			return filters.Where(strFields[r.Intn(len(strFields))]).StringNotHasPrefix(strs[r.Intn(len(strs))])
+		}
+	}
+	p := NewPercolator()
+	rules := make(map[string]filters.FieldFilterer)
+	for i := 0; i < 300; i++ {
+		f := randFilter()
+		switch r.Intn(3) {
+		case 0:
+			f = f.And(randFilter())
+		case 1:
+			f = f.Or(randFilter())
+		}
+		id := fmt.Sprint(i)
+		rules[id] = f
+		require.NoError(t, p.Add(id, testType, f))
+	}
+	for i := 0; i < 300; i++ {
+		if i%50 == 0 {
+			id := fmt.Sprint(r.Intn(len(rules)))
+			delete(rules, id)
+			p.Remove(id)
+		}
+		m := randMsg()
+		want := []string{}
+		for id, f := range rules {
+			ok, err := Match(m, f)
+			require.NoError(t, err)
+			if ok {
+				want = append(want, id)
+			}
+		}
+		got, err := p.Percolate(m)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, want, got)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package reflect
+
+import (
+	"fmt"
+	"strings"
+
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TypeURL is the path element selecting the type URL of a
+// google.protobuf.Any field, e.g. payload.@type
+const TypeURL = "@type"
+
+// SplitDynamic splits the path at the first field holding dynamic values:
+// a google.protobuf.Any unpacked by the path, a google.protobuf.Struct
+// descended into by the path, or a google.protobuf.Value or ListValue.
+// The head is the path up to and including the field, empty if md is the
+// dynamic message, and the tail the path in the dynamic message, which
+// starts for the Any messages with the unpacked type name in parentheses
+// if explicit, e.g. (my.pkg.Event).user_id.
+// The dynamic message descriptor dyn is nil if the path does not contain
+// any dynamic field, and the wildcards do not cross them.
+func SplitDynamic(md pref.MessageDescriptor, path string, mode NameMode) (head, tail string, dyn pref.MessageDescriptor) {
+	split := func(i int) (string, string, pref.MessageDescriptor) {
+		if i == 0 {
+			return "", path, md
+		}
+		if i > len(path) {
+			return path, "", md
+		}
+		return path[:i-1], path[i:], md
+	}
+	for i := 0; md != nil; {
+		if WKType(md.FullName()) == Value || WKType(md.FullName()) == ListValue {
+			return split(i)
+		}
+		if i >= len(path) {
+			break
+		}
+		field := path[i:]
+		if j := strings.IndexByte(field, '.'); j >= 0 {
+			field = field[:j]
+		}
+		fd := lookupField(md, field, mode)
+		if WKType(md.FullName()) == Struct || (WKType(md.FullName()) == Any && fd == nil) {
+			return split(i)
+		}
+		if fd == nil {
+			break
+		}
+		md = fd.Message()
+		if (fd.IsList() && fd.Kind() != pref.MessageKind) || fd.IsMap() {
+			md = nil
+		}
+		i += len(field) + 1
+	}
+	return path, "", nil
+}
+
+// ParseAny returns the explicit type name and the path in the unpacked
+// message of a google.protobuf.Any tail returned by SplitDynamic.
+// The name is empty if the type is implicit.
+func ParseAny(tail string) (name pref.FullName, path string, err error) {
+	if strings.HasPrefix(tail, "(") {
+		i := strings.IndexByte(tail, ')')
+		if i < 0 {
+			return "", "", fmt.Errorf("%s: missing closing parenthesis", tail)
+		}
+		name, tail = pref.FullName(tail[1:i]), tail[i+1:]
+		if !name.IsValid() {
+			return "", "", fmt.Errorf("%s: invalid type name", name)
+		}
+		if tail != "" && !strings.HasPrefix(tail, ".") {
+			return "", "", fmt.Errorf("%s: expected a field path after the type name", tail)
+		}
+		tail = strings.TrimPrefix(tail, ".")
+	}
+	if tail == "" {
+		return "", "", fmt.Errorf("missing field path in the unpacked message")
+	}
+	return name, tail, nil
+}
+
+// AnyTypeName returns the message full name of the type URL,
+// e.g. type.googleapis.com/my.pkg.Event returns my.pkg.Event
+func AnyTypeName(url string) pref.FullName {
+	if i := strings.LastIndexByte(url, '/'); i >= 0 {
+		url = url[i+1:]
+	}
+	return pref.FullName(url)
+}
+
+// nullValue stands for the missing google.protobuf.Value
+var nullValue = structpb.NewNullValue().ProtoReflect()
+
+// AppendValues appends the google.protobuf.Value messages at the keys path
+// in the google.protobuf.Struct, Value or ListValue message, descending into
+// the ListValues elements, and a null Value for the missing keys.
+func AppendValues(out []pref.Message, msg pref.Message, keys []string) []pref.Message {
+	fields := msg.Descriptor().Fields()
+	switch WKType(msg.Descriptor().FullName()) {
+	case Struct:
+		if len(keys) == 0 {
+			return out
+		}
+		v := msg.Get(fields.ByName("fields")).Map().Get(pref.ValueOfString(keys[0]).MapKey())
+		if !v.IsValid() {
+			return append(out, nullValue)
+		}
+		return AppendValues(out, v.Message(), keys[1:])
+	case ListValue:
+		list := msg.Get(fields.ByName("values")).List()
+		for i := 0; i < list.Len(); i++ {
+			out = AppendValues(out, list.Get(i).Message(), keys)
+		}
+		return out
+	case Value:
+		fd := ValueField(msg)
+		switch {
+		case fd == nil:
+			return append(out, nullValue)
+		case fd.Message() != nil:
+			if fd.Name() == "struct_value" && len(keys) == 0 {
+				return append(out, msg)
+			}
+			return AppendValues(out, msg.Get(fd).Message(), keys)
+		case len(keys) == 0:
+			return append(out, msg)
+		default:
+			return append(out, nullValue)
+		}
+	}
+	return out
+}
+
+// ValueField returns the set kind field of the google.protobuf.Value
+// message, or nil if none is set.
+func ValueField(msg pref.Message) pref.FieldDescriptor {
+	return msg.WhichOneof(msg.Descriptor().Oneofs().ByName("kind"))
+}
+
+// RangeValues calls fn with the keys paths, the kind fields and the values
+// of the scalar leaves of the google.protobuf.Struct, Value or ListValue
+// message, the ListValues elements being leaves of the ListValue path,
+// until fn returns false.
+func RangeValues(msg pref.Message, fn func(keys []string, fd pref.FieldDescriptor, v pref.Value) bool) {
+	rangeValues(msg, nil, fn)
+}
+
+func rangeValues(msg pref.Message, keys []string, fn func(keys []string, fd pref.FieldDescriptor, v pref.Value) bool) bool {
+	fields := msg.Descriptor().Fields()
+	switch WKType(msg.Descriptor().FullName()) {
+	case Struct:
+		ok := true
+		msg.Get(fields.ByName("fields")).Map().Range(func(k pref.MapKey, v pref.Value) bool {
+			ok = rangeValues(v.Message(), append(keys[:len(keys):len(keys)], k.String()), fn)
+			return ok
+		})
+		return ok
+	case ListValue:
+		list := msg.Get(fields.ByName("values")).List()
+		for i := 0; i < list.Len(); i++ {
+			if !rangeValues(list.Get(i).Message(), keys, fn) {
+				return false
+			}
+		}
+		return true
+	case Value:
+		fd := ValueField(msg)
+		if fd == nil {
+			return true
+		}
+		if fd.Message() != nil {
+			return rangeValues(msg.Get(fd).Message(), keys, fn)
+		}
+		return fn(keys, fd, msg.Get(fd))
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package reflect
+
+import (
+	"errors"
+
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// The following are used by the matchers generated by protoc-gen-go-filters,
+// which match the messages fields without reflection.
+
+// ErrUnsupported is returned by the generated matchers for the fields paths
+// and the filters they do not support, which must be matched by the
+// reflective matcher.
+var ErrUnsupported = errors.New("not supported by the generated matcher")
+
+// FieldMatcher is implemented by the messages with a generated matcher.
+type FieldMatcher interface {
+	// MatchFieldFilter matches the field path against the filter
+	MatchFieldFilter(path string, f *filters.Filter) (bool, error)
+}
+
+// Presence is the presence of a scalar field value.
+type Presence int
+
+const (
+	// Implicit is the presence of the fields without the optional keyword.
+	Implicit Presence = iota
+	// Present is the presence of the set optional fields.
+	Present
+	// Absent is the presence of the unset optional fields.
+	Absent
+)
+
+// MatchExpression matches the expression using the field matcher.
+func MatchExpression(m FieldMatcher, expr *filters.Expression) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	ok := true
+	if expr.Condition != nil {
+		var err error
+		if ok, err = m.MatchFieldFilter(expr.Condition.Field, expr.Condition.Filter); err != nil {
+			return false, WithPath(err, expr.Condition.Field)
+		}
+	}
+	if ok {
+		for _, v := range expr.AndExprs {
+			o, err := MatchExpression(m, v)
+			if err != nil {
+				return false, err
+			}
+			if !o {
+				ok = false
+				break
+			}
+		}
+	}
+	if ok {
+		return true, nil
+	}
+	for _, v := range expr.OrExprs {
+		o, err := MatchExpression(m, v)
+		if err != nil {
+			return false, err
+		}
+		if o {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MatchRepeated matches the repeated scalar field values: one of them must
+// match the filter, and the negated filters never match.
+func MatchRepeated[T any](values []T, f *filters.Filter, match func(v T, f *filters.Filter) (bool, error)) (bool, error) {
+	for _, v := range values {
+		ok, err := match(v, f)
+		if err != nil {
+			return false, err
+		}
+		if f.GetNot() && !ok {
+			return false, nil
+		}
+		if !f.GetNot() && ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MatchString matches a string field value.
+func MatchString(v string, p Presence, f *filters.Filter) (bool, error) {
+	switch f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		return matchPresence(p, f)
+	case *filters.Filter_String_:
+		match, err := matchStringFilter(f.GetString_(), v, p != Absent)
+		return checkNot(f, match, err)
+	}
+	return false, ErrUnsupported
+}
+
+// MatchNumber matches a numeric field value.
+func MatchNumber(v float64, p Presence, f *filters.Filter) (bool, error) {
+	switch f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		return matchPresence(p, f)
+	case *filters.Filter_Number:
+		match, err := matchNumberFilter(f.GetNumber(), v, p != Absent)
+		return checkNot(f, match, err)
+	}
+	return false, ErrUnsupported
+}
+
+// MatchBool matches a bool field value.
+func MatchBool(v bool, p Presence, f *filters.Filter) (bool, error) {
+	switch f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		return matchPresence(p, f)
+	case *filters.Filter_Bool:
+		match, err := matchBoolFilter(f.GetBool(), v, p != Absent)
+		return checkNot(f, match, err)
+	}
+	return false, ErrUnsupported
+}
+
+// MatchEnum matches an enum field value, by name with the string filters.
+func MatchEnum(v pref.EnumNumber, ed pref.EnumDescriptor, p Presence, f *filters.Filter) (bool, error) {
+	switch f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		return matchPresence(p, f)
+	case *filters.Filter_Number:
+		return MatchNumber(float64(v), p, f)
+	case *filters.Filter_String_:
+		if p == Absent {
+			return checkNot(f, false, nil)
+		}
+		e := ed.Values().ByNumber(v)
+		if e == nil {
+			return false, nil
+		}
+		match, err := matchStringFilter(f.GetString_(), string(e.Name()), true)
+		return checkNot(f, match, err)
+	}
+	return false, ErrUnsupported
+}
+
+// MatchTime matches a google.protobuf.Timestamp field value.
+func MatchTime(seconds int64, nanos int32, f *filters.Filter) (bool, error) {
+	if _, ok := f.GetMatch().(*filters.Filter_Time); !ok {
+		return false, ErrUnsupported
+	}
+	match, err := matchTimeFilter(f.GetTime(), seconds, nanos, true)
+	return checkNot(f, match, err)
+}
+
+// MatchDuration matches a google.protobuf.Duration field value.
+func MatchDuration(seconds int64, nanos int32, f *filters.Filter) (bool, error) {
+	if _, ok := f.GetMatch().(*filters.Filter_Duration); !ok {
+		return false, ErrUnsupported
+	}
+	match, err := matchDurationFilter(f.GetDuration(), seconds, nanos, true)
+	return checkNot(f, match, err)
+}
+
+// MatchNull matches a message field against the null filter.
+func MatchNull(null bool, f *filters.Filter) (bool, error) {
+	if _, ok := f.GetMatch().(*filters.Filter_Null); !ok {
+		return false, ErrUnsupported
+	}
+	return checkNot(f, null, nil)
+}
+
+// matchPresence matches the null filter, which is only supported by the
+// optional fields
+func matchPresence(p Presence, f *filters.Filter) (bool, error) {
+	if p == Implicit {
+		return false, ErrUnsupported
+	}
+	return checkNot(f, p == Absent, nil)
+}
@@ -17,30 +17,44 @@
 package reflect
 
 import (
-	"fmt"
 	"strings"
 
 	pref "google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// NameMode selects the names the fields paths elements are resolved by.
+type NameMode int
+
+const (
+	// ProtoNames resolves the fields by their proto name, e.g. created_at.
+	ProtoNames NameMode = iota
+	// JSONNames strictly resolves the fields by their JSON name, e.g.
+	// createdAt.
+	JSONNames
+	// EitherNames resolves the fields by their proto name, or by their JSON
+	// name.
+	EitherNames
+	// PermissiveNames resolves the fields as EitherNames, falling back to
+	// a case insensitive comparison of both names.
+	PermissiveNames
 )
 
 func Lookup(msg pref.Message, path string) ([]pref.FieldDescriptor, error) {
-	md0 := msg.Descriptor()
+	return LookupNames(msg.Descriptor(), path, ProtoNames)
+}
+
+// LookupNames returns the fields descriptors of the path in the message
+// described by md, resolving the fields names with mode.
+func LookupNames(md0 pref.MessageDescriptor, path string, mode NameMode) ([]pref.FieldDescriptor, error) {
 	md := md0
 	fds, ok := rangeFields(path, func(field string) (pref.FieldDescriptor, bool) {
 		// Search the field within the message.
 		if md == nil {
 			return nil, false // not within a message
 		}
-		fd := md.Fields().ByName(pref.Name(field))
-		// The real field name of a group is the message name.
-		if fd == nil {
-			gd := md.Fields().ByName(pref.Name(strings.ToLower(field)))
-			if gd != nil && gd.Kind() == pref.GroupKind && string(gd.Message().Name()) == field {
-				fd = gd
-			}
-		} else if fd.Kind() == pref.GroupKind && string(fd.Message().Name()) != field {
-			fd = nil
-		}
+		fd := lookupField(md, field, mode)
 		if fd == nil {
 			return nil, false // message does not have this field
 		}
@@ -54,11 +68,47 @@ func Lookup(msg pref.Message, path string) ([]pref.FieldDescriptor, error) {
 		return fd, true
 	})
 	if !ok {
-		return nil, fmt.Errorf("%s does not contain '%s'", md0.FullName(), path)
+		return nil, &filters.UnknownFieldError{Message: string(md0.FullName()), Path: path}
 	}
 	return fds, nil
 }
 
+func lookupField(md pref.MessageDescriptor, field string, mode NameMode) pref.FieldDescriptor {
+	if field == TypeURL && WKType(md.FullName()) == Any {
+		return md.Fields().ByName("type_url")
+	}
+	if mode == JSONNames {
+		return md.Fields().ByJSONName(field)
+	}
+	if fd := lookupProtoName(md, field); fd != nil || mode == ProtoNames {
+		return fd
+	}
+	if fd := md.Fields().ByJSONName(field); fd != nil || mode == EitherNames {
+		return fd
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); strings.EqualFold(string(fd.Name()), field) || strings.EqualFold(fd.JSONName(), field) {
+			return fd
+		}
+	}
+	return nil
+}
+
+func lookupProtoName(md pref.MessageDescriptor, field string) pref.FieldDescriptor {
+	fd := md.Fields().ByName(pref.Name(field))
+	// The real field name of a group is the message name.
+	if fd == nil {
+		gd := md.Fields().ByName(pref.Name(strings.ToLower(field)))
+		if gd != nil && gd.Kind() == pref.GroupKind && string(gd.Message().Name()) == field {
+			fd = gd
+		}
+	} else if fd.Kind() == pref.GroupKind && string(fd.Message().Name()) != field {
+		fd = nil
+	}
+	return fd
+}
+
 // rangeFields is like strings.Split(path, "."), but avoids allocations by
 // iterating over each field in place and calling a iterator function.
 // (taken from "google.golang.org/protobuf/types/known/fieldmaskpb")
@@ -17,7 +17,7 @@
 package reflect
 
 import (
-	"fmt"
+	"errors"
 	"regexp"
 	"strings"
 
@@ -46,6 +46,9 @@ var WKTypes = []WKType{
 	BoolValue,
 	StringValue,
 	BytesValue,
+	Struct,
+	Value,
+	ListValue,
 }
 
 func IsWKType(t pref.FullName) bool {
@@ -69,9 +72,31 @@ const (
 	BoolValue   WKType = "google.protobuf.BoolValue"
 	StringValue WKType = "google.protobuf.StringValue"
 	BytesValue  WKType = "google.protobuf.BytesValue"
+	Struct      WKType = "google.protobuf.Struct"
+	Value       WKType = "google.protobuf.Value"
+	ListValue   WKType = "google.protobuf.ListValue"
+	// Any is not matched as a value, but unpacked by the paths
+	Any WKType = "google.protobuf.Any"
 )
 
+// WithPath sets the field path of the *filters.TypeMismatchError and
+// *filters.RegexError returned by Match, which do not know it.
+func WithPath(err error, path string) error {
+	var tm *filters.TypeMismatchError
+	if errors.As(err, &tm) && tm.Path == "" {
+		tm.Path = path
+	}
+	var re *filters.RegexError
+	if errors.As(err, &re) && re.Path == "" {
+		re.Path = path
+	}
+	return err
+}
+
 func Match(val pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, error) {
+	if WKType(fd.ContainingMessage().FullName()) == Value {
+		return MatchValue(val, fd, f)
+	}
 	switch f.GetMatch().(type) {
 	case *filters.Filter_String_:
 		return matchString(val, fd, f)
@@ -89,13 +114,48 @@ func Match(val pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, er
 	return false, nil
 }
 
+// MatchValue matches the value of the google.protobuf.Value kind field fd:
+// the null filter matches the null values, and the other filters do not
+// match the values of another kind, as the unset optional fields.
+func MatchValue(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, error) {
+	var kind pref.Kind
+	switch f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		// the null_value field is the only enum field
+		return checkNot(f, fd.Kind() == pref.EnumKind, nil)
+	case *filters.Filter_String_:
+		kind = pref.StringKind
+	case *filters.Filter_Number:
+		kind = pref.DoubleKind
+	case *filters.Filter_Bool:
+		kind = pref.BoolKind
+	case *filters.Filter_Time:
+		return false, &filters.TypeMismatchError{Kind: string(Value), FilterKind: "time"}
+	case *filters.Filter_Duration:
+		return false, &filters.TypeMismatchError{Kind: string(Value), FilterKind: "duration"}
+	default:
+		return false, nil
+	}
+	if fd.Kind() != kind {
+		return checkNot(f, false, nil)
+	}
+	switch kind {
+	case pref.StringKind:
+		return matchString(rval, fd, f)
+	case pref.DoubleKind:
+		return matchNumber(rval, fd, f)
+	default:
+		return matchBool(rval, fd, f)
+	}
+}
+
 func matchString(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, error) {
 	var value string
 	hasValue := true
 	valueSet := false
 	if fd.Kind() != pref.StringKind && fd.Kind() != pref.EnumKind {
 		if fd.Kind() != pref.MessageKind || WKType(fd.Message().FullName()) != StringValue {
-			return false, fmt.Errorf("cannot use string filter on %s", fd.Kind().String())
+			return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "string"}
 		}
 		// return early as the condition will always be false
 		if !rval.IsValid() {
@@ -105,6 +165,10 @@ func matchString(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (b
 		valueSet = true
 	}
 	if fd.Kind() == pref.EnumKind {
+		// unset optional enum
+		if !rval.IsValid() {
+			return checkNot(f, false, nil)
+		}
 		e := fd.Enum().Values().ByNumber(rval.Enum())
 		if e == nil {
 			return false, nil
@@ -131,7 +195,7 @@ func matchNumber(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (b
 	hasValue := true
 	if !rval.IsValid() {
 		if !fd.HasOptionalKeyword() {
-			return false, fmt.Errorf("cannot use number filter on %s", fd.Kind().String())
+			return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "number"}
 		}
 		hasValue = false
 	}
@@ -161,10 +225,10 @@ func matchNumber(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (b
 			case UInt64Value, UInt32Value:
 				val = float64(rval.Message().Get(fd.Message().Fields().Get(0)).Uint())
 			default:
-				return false, fmt.Errorf("cannot use number filter on %s", fd.Kind().String())
+				return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "number"}
 			}
 		default:
-			return false, fmt.Errorf("cannot use number filter on %s", fd.Kind().String())
+			return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "number"}
 		}
 	}
 	match, err := matchNumberFilter(f.GetNumber(), val, hasValue)
@@ -176,7 +240,7 @@ func matchBool(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (boo
 	hasValue := true
 	if fd.Kind() != pref.BoolKind {
 		if fd.Kind() != pref.MessageKind || WKType(fd.Message().FullName()) != BoolValue {
-			return false, fmt.Errorf("cannot use bool filter on %s", fd.Kind().String())
+			return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "bool"}
 		}
 		// return early as the condition will always be false
 		if !rval.IsValid() {
@@ -201,7 +265,7 @@ func matchNull(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (boo
 		match = rval.List().Len() == 0
 	default:
 		if !fd.HasOptionalKeyword() {
-			return false, fmt.Errorf("cannot use null filter on %s", fd.Kind().String())
+			return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "null"}
 		}
 		match = !rval.IsValid()
 	}
@@ -210,7 +274,7 @@ func matchNull(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (boo
 
 func matchTime(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, error) {
 	if fd.Kind() != pref.MessageKind || WKType(fd.Message().FullName()) != Timestamp {
-		return false, fmt.Errorf("cannot use time filter on %s", fd.Kind().String())
+		return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "time"}
 	}
 	if !rval.IsValid() {
 		return checkNot(f, false, nil)
@@ -223,7 +287,7 @@ func matchTime(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (boo
 
 func matchDuration(rval pref.Value, fd pref.FieldDescriptor, f *filters.Filter) (bool, error) {
 	if fd.Kind() != pref.MessageKind || WKType(fd.Message().FullName()) != Duration {
-		return false, fmt.Errorf("cannot use duration filter on %s", fd.Kind().String())
+		return false, &filters.TypeMismatchError{Kind: fd.Kind().String(), FilterKind: "duration"}
 	}
 	if !rval.IsValid() {
 		return checkNot(f, false, nil)
@@ -255,10 +319,15 @@ func matchStringFilter(f *filters.StringFilter, value string, hasValue bool) (bo
 			return strings.HasSuffix(strings.ToLower(value), strings.ToLower(f.GetHasSuffix())), nil
 		}
 		return strings.HasSuffix(value, f.GetHasSuffix()), nil
+	case *filters.StringFilter_Contains:
+		if insensitive {
+			return strings.Contains(strings.ToLower(value), strings.ToLower(f.GetContains())), nil
+		}
+		return strings.Contains(value, f.GetContains()), nil
 	case *filters.StringFilter_Regex:
 		reg, err := regexp.Compile(f.GetRegex())
 		if err != nil {
-			return false, err
+			return false, &filters.RegexError{Regex: f.GetRegex(), Err: err}
 		}
 		return reg.MatchString(value), nil
 	case *filters.StringFilter_In_:
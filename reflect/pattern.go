@@ -0,0 +1,317 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package reflect
+
+import (
+	"strings"
+
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+const (
+	// Wildcard matches any field at one level of a path, e.g. *.name
+	Wildcard = "*"
+	// RecursiveWildcard matches any number of levels of a path,
+	// e.g. **.owner_id, or at least one if it ends the path
+	// e.g. message.**
+	RecursiveWildcard = "**"
+)
+
+// IsPattern reports whether the path contains wildcards.
+func IsPattern(path string) bool {
+	for _, v := range strings.Split(path, ".") {
+		if v == Wildcard || v == RecursiveWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand returns the fields paths of the message described by md matching
+// the pattern, whose other elements are resolved with mode.
+// The recursive wildcard does not descend into the well-known types, which
+// are matched as values. As the recursive messages have no depth limit,
+// Expand descends into each message type at most once per path: the paths
+// of a message matching a recursive wildcard at any depth are returned by
+// ExpandMessage.
+// The path without wildcards is returned as a single path.
+func Expand(md pref.MessageDescriptor, pattern string, mode NameMode) ([][]pref.FieldDescriptor, error) {
+	if !IsPattern(pattern) {
+		fds, err := LookupNames(md, pattern, mode)
+		if err != nil {
+			return nil, err
+		}
+		return [][]pref.FieldDescriptor{fds}, nil
+	}
+	e := &expander{mode: mode, seen: make(map[string]struct{}), types: make(map[pref.FullName]bool)}
+	e.expand(md, nil, strings.Split(pattern, "."), nil)
+	return e.out, nil
+}
+
+// ExpandMessage returns the fields paths of the message matching the
+// pattern, as Expand does, the recursive wildcard descending into the set
+// message fields at any depth.
+func ExpandMessage(msg pref.Message, pattern string, mode NameMode) [][]pref.FieldDescriptor {
+	e := &expander{mode: mode, seen: make(map[string]struct{})}
+	e.expand(msg.Descriptor(), msg, strings.Split(pattern, "."), nil)
+	return e.out
+}
+
+// IsRecursivePattern reports whether the path contains recursive wildcards.
+func IsRecursivePattern(path string) bool {
+	for _, v := range strings.Split(path, ".") {
+		if v == RecursiveWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+type expander struct {
+	mode NameMode
+	out  [][]pref.FieldDescriptor
+	seen map[string]struct{}
+	// types are the message types descended into by the current path,
+	// without message
+	types map[pref.FullName]bool
+}
+
+// expand expands the pattern parts in the message described by md, and in
+// the message msg if not nil
+func (e *expander) expand(md pref.MessageDescriptor, msg pref.Message, parts []string, path []pref.FieldDescriptor) {
+	if len(parts) == 0 {
+		e.emit(path)
+		return
+	}
+	if md == nil {
+		return
+	}
+	fields := md.Fields()
+	switch parts[0] {
+	case Wildcard:
+		for i := 0; i < fields.Len(); i++ {
+			e.next(fields.Get(i), msg, parts[1:], path)
+		}
+	case RecursiveWildcard:
+		if len(parts) != 1 {
+			// zero level
+			e.expand(md, msg, parts[1:], path)
+		}
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if len(parts) == 1 {
+				// a trailing recursive wildcard matches all the descendants
+				e.emit(append(path[:len(path):len(path)], fd))
+			}
+			m := descendable(fd)
+			if m == nil {
+				continue
+			}
+			if msg != nil {
+				for _, v := range setMessages(msg, fd) {
+					e.expand(m, v, parts, append(path[:len(path):len(path)], fd))
+				}
+				continue
+			}
+			if e.types[m.FullName()] {
+				continue
+			}
+			e.types[m.FullName()] = true
+			e.expand(m, nil, parts, append(path[:len(path):len(path)], fd))
+			delete(e.types, m.FullName())
+		}
+	default:
+		if fd := lookupField(md, parts[0], e.mode); fd != nil {
+			e.next(fd, msg, parts[1:], path)
+		}
+	}
+}
+
+func (e *expander) next(fd pref.FieldDescriptor, msg pref.Message, parts []string, path []pref.FieldDescriptor) {
+	path = append(path[:len(path):len(path)], fd)
+	if len(parts) == 0 {
+		e.emit(path)
+		return
+	}
+	m := descendable(fd)
+	if m == nil {
+		return
+	}
+	if msg == nil {
+		e.expand(m, nil, parts, path)
+		return
+	}
+	vs := setMessages(msg, fd)
+	if len(vs) == 0 {
+		// the paths through the unset fields are still matched
+		vs = append(vs, dynamicpb.NewMessage(m))
+	}
+	for _, v := range vs {
+		e.expand(m, v, parts, path)
+	}
+}
+
+// setMessages returns the set messages of the message field fd
+func setMessages(msg pref.Message, fd pref.FieldDescriptor) []pref.Message {
+	if !msg.Has(fd) {
+		return nil
+	}
+	if !fd.IsList() {
+		return []pref.Message{msg.Get(fd).Message()}
+	}
+	list := msg.Get(fd).List()
+	out := make([]pref.Message, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		out = append(out, list.Get(i).Message())
+	}
+	return out
+}
+
+func (e *expander) emit(path []pref.FieldDescriptor) {
+	if len(path) == 0 {
+		return
+	}
+	var names []string
+	for _, v := range path {
+		names = append(names, string(v.Name()))
+	}
+	k := strings.Join(names, ".")
+	if _, ok := e.seen[k]; ok {
+		return
+	}
+	e.seen[k] = struct{}{}
+	e.out = append(e.out, path)
+}
+
+// descendable returns the message of the field if a path may go through it
+func descendable(fd pref.FieldDescriptor) pref.MessageDescriptor {
+	if fd.Message() == nil || fd.IsMap() || strings.HasPrefix(string(fd.Message().FullName()), "google.protobuf.") {
+		return nil
+	}
+	return fd.Message()
+}
+
+// MatchPattern reports whether the dotted path matches the pattern,
+// comparing the other elements as is.
+func MatchPattern(pattern, path string) bool {
+	elems := strings.Split(path, ".")
+	return matchParts(strings.Split(pattern, "."), elems, func(i int, part string) bool {
+		return elems[i] == part
+	})
+}
+
+// MatchPatternNames reports whether the dotted path of the fields proto
+// names in the message described by md matches the pattern, whose other
+// elements are resolved with mode. The path elements which are not fields,
+// e.g. the google.protobuf.Struct keys, are compared as is.
+func MatchPatternNames(md pref.MessageDescriptor, pattern, path string, mode NameMode) bool {
+	if mode == ProtoNames || md == nil {
+		return MatchPattern(pattern, path)
+	}
+	elems := strings.Split(path, ".")
+	// mds are the messages holding the fields of the path
+	mds := make([]pref.MessageDescriptor, len(elems))
+	fds := make([]pref.FieldDescriptor, len(elems))
+	for i, v := range elems {
+		if md == nil {
+			break
+		}
+		mds[i], fds[i] = md, lookupProtoName(md, v)
+		if fds[i] == nil {
+			break
+		}
+		md = fds[i].Message()
+	}
+	return matchParts(strings.Split(pattern, "."), elems, func(i int, part string) bool {
+		if fds[i] == nil {
+			return elems[i] == part
+		}
+		return lookupField(mds[i], part, mode) == fds[i]
+	})
+}
+
+// matchParts reports whether the path elements match the pattern, eq
+// comparing the element i to a pattern element which is not a wildcard
+func matchParts(pattern, path []string, eq func(i int, part string) bool) bool {
+	return matchPartsFrom(pattern, path, 0, eq)
+}
+
+func matchPartsFrom(pattern, path []string, i int, eq func(i int, part string) bool) bool {
+	for len(pattern) != 0 {
+		switch pattern[0] {
+		case RecursiveWildcard:
+			if len(pattern) == 1 {
+				return i < len(path)
+			}
+			for j := i; j <= len(path); j++ {
+				if matchPartsFrom(pattern[1:], path, j, eq) {
+					return true
+				}
+			}
+			return false
+		case Wildcard:
+		default:
+			if i == len(path) || !eq(i, pattern[0]) {
+				return false
+			}
+		}
+		if i == len(path) {
+			return false
+		}
+		pattern, i = pattern[1:], i+1
+	}
+	return i == len(path)
+}
+
+// Accepts reports whether the filter can be applied to the field, so that
+// the wildcard paths only match the fields of the filter type.
+func Accepts(fd pref.FieldDescriptor, f *filters.Filter) bool {
+	if fd.IsMap() {
+		return false
+	}
+	var wk WKType
+	if fd.Kind() == pref.MessageKind {
+		wk = WKType(fd.Message().FullName())
+	}
+	switch f.GetMatch().(type) {
+	case *filters.Filter_String_:
+		return fd.Kind() == pref.StringKind || fd.Kind() == pref.EnumKind || wk == StringValue
+	case *filters.Filter_Number:
+		switch fd.Kind() {
+		case pref.Int32Kind, pref.Sint32Kind, pref.Int64Kind, pref.Sint64Kind,
+			pref.Sfixed32Kind, pref.Fixed32Kind, pref.Sfixed64Kind, pref.Fixed64Kind,
+			pref.Uint32Kind, pref.Uint64Kind, pref.FloatKind, pref.DoubleKind, pref.EnumKind:
+			return true
+		}
+		switch wk {
+		case DoubleValue, FloatValue, Int64Value, Int32Value, UInt64Value, UInt32Value:
+			return true
+		}
+	case *filters.Filter_Bool:
+		return fd.Kind() == pref.BoolKind || wk == BoolValue
+	case *filters.Filter_Null:
+		return !fd.IsList() && (fd.Kind() == pref.MessageKind || fd.HasOptionalKeyword())
+	case *filters.Filter_Time:
+		return wk == Timestamp
+	case *filters.Filter_Duration:
+		return wk == Duration
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package sql
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Postgres is the PostgreSQL dialect, using jsonb operators for the
+	// JSON columns.
+	Postgres Dialect = postgres{}
+	// MySQL is the MySQL 8 dialect.
+	MySQL Dialect = mysql{}
+	// SQLite is the SQLite dialect, using the JSON1 functions for the
+	// JSON columns. The times are stored as RFC 3339 text, and compared
+	// with unixepoch, available since SQLite 3.42.
+	// Regular expressions are not supported.
+	SQLite Dialect = sqlite{}
+)
+
+// Type is the type a JSON value is extracted as.
+type Type int
+
+const (
+	// Any is the raw JSON value, only tested for nullity.
+	Any Type = iota
+	// Text is the value as a string.
+	Text
+	// Number is the value as a number, protojson encoding the 64 bits
+	// integers as strings.
+	Number
+	Bool
+	// Time is the RFC 3339 value as a timestamp.
+	Time
+)
+
+// Dialect is the SQL syntax of a database.
+type Dialect interface {
+	// Placeholder returns the placeholder of the nth argument, starting at 1.
+	Placeholder(n int) string
+	// Quote quotes the identifier.
+	Quote(ident string) string
+	// JSON returns the expression extracting the value at path from the JSON
+	// column expression as t.
+	JSON(column string, path []string, t Type) string
+	// Time returns the expression of the time expr, comparable with the
+	// other times.
+	Time(expr string) string
+	// TimeArg returns the argument bound to the time t.
+	TimeArg(t time.Time) any
+	// Like returns the condition matching expr against the pattern, and the
+	// argument bound to placeholder.
+	Like(expr, placeholder string, p Pattern) (string, any)
+	// Regex returns the condition matching expr against the regular
+	// expression bound to placeholder, or false if not supported.
+	Regex(expr, placeholder string) (string, bool)
+}
+
+// Pattern is a string pattern.
+type Pattern struct {
+	Value string
+	// Start is set if the values must start with Value.
+	Start bool
+	// End is set if the values must end with Value.
+	End         bool
+	Insensitive bool
+}
+
+// Like returns the LIKE pattern, escaped with backslashes.
+func (p Pattern) Like() string {
+	return p.wrap(strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(p.Value), "%")
+}
+
+// Glob returns the GLOB pattern.
+func (p Pattern) Glob() string {
+	return p.wrap(strings.NewReplacer(`*`, `[*]`, `?`, `[?]`, `[`, `[[]`).Replace(p.Value), "*")
+}
+
+func (p Pattern) wrap(s, wildcard string) string {
+	if !p.Start {
+		s = wildcard + s
+	}
+	if !p.End {
+		s += wildcard
+	}
+	return s
+}
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgres) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (postgres) JSON(column string, path []string, t Type) string {
+	p := "'{" + strings.Join(path, ",") + "}'"
+	switch t {
+	case Any:
+		return column + " #> " + p
+	case Number:
+		return "(" + column + " #>> " + p + ")::numeric"
+	case Bool:
+		return "(" + column + " #>> " + p + ")::boolean"
+	case Time:
+		return "(" + column + " #>> " + p + ")::timestamptz"
+	}
+	return column + " #>> " + p
+}
+
+func (postgres) Time(expr string) string {
+	return expr
+}
+
+func (postgres) TimeArg(t time.Time) any {
+	return t
+}
+
+func (postgres) Like(expr, placeholder string, p Pattern) (string, any) {
+	if p.Insensitive {
+		return expr + " ILIKE " + placeholder + ` ESCAPE '\'`, p.Like()
+	}
+	return expr + " LIKE " + placeholder + ` ESCAPE '\'`, p.Like()
+}
+
+func (postgres) Regex(expr, placeholder string) (string, bool) {
+	return expr + " ~ " + placeholder, true
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(int) string {
+	return "?"
+}
+
+func (mysql) Quote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+func (mysql) JSON(column string, path []string, t Type) string {
+	e := "JSON_EXTRACT(" + column + ", '$." + strings.Join(path, ".") + "')"
+	switch t {
+	case Any:
+		return e
+	case Number:
+		return "CAST(JSON_UNQUOTE(" + e + ") AS DOUBLE)"
+	case Bool:
+		return "(JSON_UNQUOTE(" + e + ") = 'true')"
+	case Time:
+		return "CAST(REPLACE(REPLACE(JSON_UNQUOTE(" + e + "), 'T', ' '), 'Z', '') AS DATETIME(6))"
+	}
+	return "JSON_UNQUOTE(" + e + ")"
+}
+
+func (mysql) Time(expr string) string {
+	return expr
+}
+
+func (mysql) TimeArg(t time.Time) any {
+	return t
+}
+
+func (mysql) Like(expr, placeholder string, p Pattern) (string, any) {
+	// backslash is the default escape character
+	if p.Insensitive {
+		return "LOWER(" + expr + ") LIKE LOWER(" + placeholder + ")", p.Like()
+	}
+	return "CAST(" + expr + " AS BINARY) LIKE CAST(" + placeholder + " AS BINARY)", p.Like()
+}
+
+func (mysql) Regex(expr, placeholder string) (string, bool) {
+	return "REGEXP_LIKE(" + expr + ", " + placeholder + ", 'c')", true
+}
+
+type sqlite struct{}
+
+func (sqlite) Placeholder(int) string {
+	return "?"
+}
+
+func (sqlite) Quote(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+func (sqlite) JSON(column string, path []string, t Type) string {
+	e := "json_extract(" + column + ", '$." + strings.Join(path, ".") + "')"
+	if t == Number {
+		return "CAST(" + e + " AS REAL)"
+	}
+	return e
+}
+
+func (sqlite) Time(expr string) string {
+	return "unixepoch(" + expr + ", 'subsec')"
+}
+
+func (sqlite) TimeArg(t time.Time) any {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (sqlite) Like(expr, placeholder string, p Pattern) (string, any) {
+	// LIKE is case insensitive, GLOB is not
+	if p.Insensitive {
+		return expr + " LIKE " + placeholder + ` ESCAPE '\'`, p.Like()
+	}
+	return expr + " GLOB " + placeholder, p.Glob()
+}
+
+func (sqlite) Regex(string, string) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,323 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package sql translates the filters expressions to SQL WHERE clauses.
+package sql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// Column is the column holding a field value.
+type Column struct {
+	// Table optionally qualifies the column name.
+	Table string
+	Name  string
+	// Path is the path of the value in the JSON document held by the
+	// column, if any.
+	Path []string
+}
+
+// ColumnFunc returns the column holding the value of the field path.
+type ColumnFunc func(path string) (Column, error)
+
+// Flat maps the fields to the columns named after their path, with the
+// dots replaced by underscores, e.g. message_field_string_field.
+func Flat(path string) (Column, error) {
+	return Column{Name: strings.ReplaceAll(path, ".", "_")}, nil
+}
+
+// JSON returns a ColumnFunc selecting the fields in the JSON documents held
+// by column, e.g. marshaled with protojson.
+// The fields are selected by their JSON name, or by their name if
+// protoNames is set, as with protojson.MarshalOptions.UseProtoNames.
+// The repeated and map fields are not supported.
+func JSON(column string, md protoreflect.MessageDescriptor, protoNames bool) ColumnFunc {
+	return func(path string) (Column, error) {
+		c := Column{Name: column}
+		m := md
+		for _, v := range strings.Split(path, ".") {
+			if m == nil {
+				return Column{}, fmt.Errorf("sql: %s: not a message path", path)
+			}
+			fd := m.Fields().ByName(protoreflect.Name(v))
+			if fd == nil {
+				return Column{}, fmt.Errorf("sql: %w", &filters.UnknownFieldError{Message: string(m.FullName()), Path: v})
+			}
+			if fd.IsList() || fd.IsMap() {
+				return Column{}, fmt.Errorf("sql: %s: repeated and map fields are not supported", path)
+			}
+			if protoNames {
+				c.Path = append(c.Path, string(fd.Name()))
+			} else {
+				c.Path = append(c.Path, fd.JSONName())
+			}
+			m = fd.Message()
+		}
+		return c, nil
+	}
+}
+
+// Option configures the WHERE clause.
+type Option func(o *options)
+
+type options struct {
+	args []any
+}
+
+// WithArgs sets the arguments already used by the query: the clause
+// placeholders are numbered after them, and the returned arguments
+// start with them.
+func WithArgs(args ...any) Option {
+	return func(o *options) {
+		o.args = args
+	}
+}
+
+// Where returns the parameterized WHERE clause, without the WHERE keyword,
+// and its arguments.
+// An empty clause is returned for a nil expression.
+// The negated conditions match the NULL values, as the filters match the
+// unset optional fields.
+// The string filters on enums compare the enum names, and the durations
+// are compared as nanoseconds.
+func Where(d Dialect, f filters.FieldFilterer, columns ColumnFunc, opts ...Option) (string, []any, error) {
+	var o options
+	for _, v := range opts {
+		v(&o)
+	}
+	if f == nil || f.Expr() == nil {
+		return "", o.args, nil
+	}
+	b := &builder{d: d, columns: columns, args: o.args}
+	s, err := b.expr(f.Expr())
+	if err != nil {
+		return "", nil, err
+	}
+	return s, b.args, nil
+}
+
+type builder struct {
+	d       Dialect
+	columns ColumnFunc
+	args    []any
+}
+
+// arg adds the argument and returns its placeholder
+func (b *builder) arg(v any) string {
+	b.args = append(b.args, v)
+	return b.d.Placeholder(len(b.args))
+}
+
+func (b *builder) expr(e *filters.Expression) (string, error) {
+	var conj []string
+	if e.Condition != nil {
+		s, err := b.fieldFilter(e.Condition)
+		if err != nil {
+			return "", err
+		}
+		conj = append(conj, s)
+	}
+	for _, v := range e.AndExprs {
+		s, err := b.expr(v)
+		if err != nil {
+			return "", err
+		}
+		conj = append(conj, "("+s+")")
+	}
+	s := strings.Join(conj, " AND ")
+	if len(e.OrExprs) == 0 {
+		return s, nil
+	}
+	disj := []string{"(" + s + ")"}
+	for _, v := range e.OrExprs {
+		s, err := b.expr(v)
+		if err != nil {
+			return "", err
+		}
+		disj = append(disj, "("+s+")")
+	}
+	return strings.Join(disj, " OR "), nil
+}
+
+func (b *builder) fieldFilter(ff *filters.FieldFilter) (string, error) {
+	c, err := b.columns(ff.GetField())
+	if err != nil {
+		return "", err
+	}
+	f := ff.GetFilter()
+	if f.GetMatch() == nil {
+		return "", fmt.Errorf("sql: %s: empty filter", ff.GetField())
+	}
+	// column returns the column expression of the value extracted as t
+	column := func(t Type) string {
+		s := b.d.Quote(c.Name)
+		if c.Table != "" {
+			s = b.d.Quote(c.Table) + "." + s
+		}
+		if len(c.Path) != 0 {
+			s = b.d.JSON(s, c.Path, t)
+		}
+		return s
+	}
+	var cond string
+	switch m := f.GetMatch().(type) {
+	case *filters.Filter_Null:
+		if f.GetNot() {
+			return column(Any) + " IS NOT NULL", nil
+		}
+		return column(Any) + " IS NULL", nil
+	case *filters.Filter_String_:
+		cond, err = b.string(column(Text), m.String_)
+	case *filters.Filter_Number:
+		cond, err = b.number(column(Number), m.Number)
+	case *filters.Filter_Bool:
+		cond = column(Bool) + " = " + b.arg(m.Bool.GetEquals())
+	case *filters.Filter_Time:
+		cond, err = b.time(b.d.Time(column(Time)), m.Time)
+	case *filters.Filter_Duration:
+		if len(c.Path) != 0 {
+			return "", fmt.Errorf("sql: %s: durations are not supported in JSON documents", ff.GetField())
+		}
+		cond, err = b.duration(column(Number), m.Duration)
+	default:
+		return "", fmt.Errorf("sql: %s: unsupported filter", ff.GetField())
+	}
+	if err != nil {
+		return "", fmt.Errorf("sql: %s: %w", ff.GetField(), err)
+	}
+	if f.GetNot() {
+		return "(" + cond + ") IS NOT TRUE", nil
+	}
+	return cond, nil
+}
+
+func (b *builder) string(col string, f *filters.StringFilter) (string, error) {
+	ci := f.GetCaseInsensitive()
+	// value returns the placeholder of the argument, lowered if the filter
+	// is case insensitive
+	value := func(s string) string {
+		if ci {
+			return "LOWER(" + b.arg(s) + ")"
+		}
+		return b.arg(s)
+	}
+	like := func(p Pattern) string {
+		p.Insensitive = ci
+		s, v := b.d.Like(col, b.d.Placeholder(len(b.args)+1), p)
+		b.args = append(b.args, v)
+		return s
+	}
+	if ci {
+		switch f.GetCondition().(type) {
+		case *filters.StringFilter_Equals, *filters.StringFilter_In_, *filters.StringFilter_Inf, *filters.StringFilter_Sup:
+			col = "LOWER(" + col + ")"
+		}
+	}
+	switch c := f.GetCondition().(type) {
+	case *filters.StringFilter_Equals:
+		return col + " = " + value(c.Equals), nil
+	case *filters.StringFilter_HasPrefix:
+		return like(Pattern{Value: c.HasPrefix, Start: true}), nil
+	case *filters.StringFilter_HasSuffix:
+		return like(Pattern{Value: c.HasSuffix, End: true}), nil
+	case *filters.StringFilter_Contains:
+		return like(Pattern{Value: c.Contains}), nil
+	case *filters.StringFilter_Regex:
+		s, ok := b.d.Regex(col, b.d.Placeholder(len(b.args)+1))
+		if !ok {
+			return "", fmt.Errorf("regular expressions are not supported")
+		}
+		b.args = append(b.args, c.Regex)
+		return s, nil
+	case *filters.StringFilter_In_:
+		values := c.In.GetValues()
+		if len(values) == 0 {
+			return "1 = 0", nil
+		}
+		var ps []string
+		for _, v := range values {
+			ps = append(ps, value(v))
+		}
+		return col + " IN (" + strings.Join(ps, ", ") + ")", nil
+	case *filters.StringFilter_Inf:
+		return col + " < " + value(c.Inf), nil
+	case *filters.StringFilter_Sup:
+		return col + " > " + value(c.Sup), nil
+	}
+	return "", fmt.Errorf("unsupported string condition")
+}
+
+func (b *builder) number(col string, f *filters.NumberFilter) (string, error) {
+	switch c := f.GetCondition().(type) {
+	case *filters.NumberFilter_Equals:
+		return col + " = " + b.arg(number(c.Equals)), nil
+	case *filters.NumberFilter_Inf:
+		return col + " < " + b.arg(number(c.Inf)), nil
+	case *filters.NumberFilter_Sup:
+		return col + " > " + b.arg(number(c.Sup)), nil
+	case *filters.NumberFilter_In_:
+		values := c.In.GetValues()
+		if len(values) == 0 {
+			return "1 = 0", nil
+		}
+		var ps []string
+		for _, v := range values {
+			ps = append(ps, b.arg(number(v)))
+		}
+		return col + " IN (" + strings.Join(ps, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unsupported number condition")
+}
+
+// number returns the integral values as int64, so that they are bound to
+// integer columns without conversion
+func number(v float64) any {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return int64(v)
+	}
+	return v
+}
+
+func (b *builder) time(col string, f *filters.TimeFilter) (string, error) {
+	switch c := f.GetCondition().(type) {
+	case *filters.TimeFilter_Equals:
+		return col + " = " + b.d.Time(b.arg(b.d.TimeArg(c.Equals.AsTime()))), nil
+	case *filters.TimeFilter_Before:
+		return col + " < " + b.d.Time(b.arg(b.d.TimeArg(c.Before.AsTime()))), nil
+	case *filters.TimeFilter_After:
+		return col + " > " + b.d.Time(b.arg(b.d.TimeArg(c.After.AsTime()))), nil
+	}
+	return "", fmt.Errorf("unsupported time condition")
+}
+
+func (b *builder) duration(col string, f *filters.DurationFilter) (string, error) {
+	switch c := f.GetCondition().(type) {
+	case *filters.DurationFilter_Equals:
+		return col + " = " + b.arg(int64(c.Equals.AsDuration())), nil
+	case *filters.DurationFilter_Inf:
+		return col + " < " + b.arg(int64(c.Inf.AsDuration())), nil
+	case *filters.DurationFilter_Sup:
+		return col + " > " + b.arg(int64(c.Sup.AsDuration())), nil
+	}
+	return "", fmt.Errorf("unsupported duration condition")
+}
@@ -0,0 +1,220 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package sql_test
+
+import (
+	dbsql "database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	_ "modernc.org/sqlite"
+
+	"go.linka.cloud/protofilters"
+	"go.linka.cloud/protofilters/filters"
+	"go.linka.cloud/protofilters/sql"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+var md = (&test.Test{}).ProtoReflect().Descriptor()
+
+func TestWhere(t *testing.T) {
+	f := filters.Where("string_field").StringHasPrefix("a_%").
+		AndWhere("number_field").NumberIN(1, 2.5).
+		OrWhere("optional_string_field").StringNotEquals("b")
+	tests := []struct {
+		d       sql.Dialect
+		columns sql.ColumnFunc
+		want    string
+	}{
+		{
+			d:       sql.Postgres,
+			columns: sql.Flat,
+			want:    `("string_field" LIKE $2 ESCAPE '\' AND ("number_field" IN ($3, $4))) OR (("optional_string_field" = $5) IS NOT TRUE)`,
+		},
+		{
+			d:       sql.MySQL,
+			columns: sql.Flat,
+			want:    "(CAST(`string_field` AS BINARY) LIKE CAST(? AS BINARY) AND (`number_field` IN (?, ?))) OR ((`optional_string_field` = ?) IS NOT TRUE)",
+		},
+		{
+			d:       sql.SQLite,
+			columns: sql.Flat,
+			want:    `("string_field" GLOB ? AND ("number_field" IN (?, ?))) OR (("optional_string_field" = ?) IS NOT TRUE)`,
+		},
+		{
+			d:       sql.Postgres,
+			columns: sql.JSON("doc", md, false),
+			want:    `("doc" #>> '{stringField}' LIKE $2 ESCAPE '\' AND (("doc" #>> '{numberField}')::numeric IN ($3, $4))) OR (("doc" #>> '{optionalStringField}' = $5) IS NOT TRUE)`,
+		},
+		{
+			d:       sql.MySQL,
+			columns: sql.JSON("doc", md, true),
+			want:    "(CAST(JSON_UNQUOTE(JSON_EXTRACT(`doc`, '$.string_field')) AS BINARY) LIKE CAST(? AS BINARY) AND (CAST(JSON_UNQUOTE(JSON_EXTRACT(`doc`, '$.number_field')) AS DOUBLE) IN (?, ?))) OR ((JSON_UNQUOTE(JSON_EXTRACT(`doc`, '$.optional_string_field')) = ?) IS NOT TRUE)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			s, args, err := sql.Where(tt.d, f, tt.columns, sql.WithArgs("tenant"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s)
+			like := `a\_\%%`
+			if tt.d == sql.SQLite {
+				like = "a_%*"
+			}
+			assert.Equal(t, []any{"tenant", like, int64(1), 2.5, "b"}, args)
+		})
+	}
+	s, args, err := sql.Where(sql.Postgres, nil, sql.Flat)
+	require.NoError(t, err)
+	assert.Empty(t, s)
+	assert.Empty(t, args)
+	for _, f := range []filters.FieldFilterer{
+		filters.Where("string_field").StringRegex("a"),
+		filters.Where("unknown").StringEquals("a"),
+		filters.Where("repeated_string_field").StringEquals("a"),
+		filters.Where("duration_value_field").DurationEquals(time.Second),
+	} {
+		_, _, err := sql.Where(sql.SQLite, f, sql.JSON("doc", md, false))
+		assert.Error(t, err, f.Expr().Format())
+	}
+}
+
+func TestPattern(t *testing.T) {
+	p := sql.Pattern{Value: `a%_\*?[b`, Start: true}
+	assert.Equal(t, `a\%\_\\*?[b%`, p.Like())
+	assert.Equal(t, `a%_\[*][?][[]b*`, p.Glob())
+	p = sql.Pattern{Value: "a", Start: true, End: true}
+	assert.Equal(t, "a", p.Like())
+	assert.Equal(t, "a", p.Glob())
+}
+
+// TestSQLite checks that the clauses select the messages matched by the
+// filters, using both flat and JSON columns
+func TestSQLite(t *testing.T) {
+	msgs := []*test.Test{
+		{StringField: "abc", NumberField: 1, EnumField: test.Test_ONE},
+		{StringField: "ABD", NumberField: 2, BoolField: true, OptionalStringField: proto.String("")},
+		{StringField: "a_c", NumberField: -3, OptionalStringField: proto.String("x")},
+		{StringField: "x%z", NumberField: 4, EnumField: test.Test_TWO, MessageField: &test.Test{StringField: "abc"}},
+		{StringField: "a*b", NumberField: 5, BoolField: true, OptionalNumberField: proto.Int64(2)},
+	}
+	db, err := dbsql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE test (
+		id INTEGER PRIMARY KEY,
+		string_field TEXT,
+		number_field INTEGER,
+		bool_field BOOLEAN,
+		enum_field TEXT,
+		message_field_string_field TEXT,
+		optional_string_field TEXT,
+		optional_number_field INTEGER,
+		time_value_field DATETIME,
+		duration_value_field INTEGER,
+		doc TEXT
+	)`)
+	require.NoError(t, err)
+	for i, m := range msgs {
+		m.MessageField = &test.Test{StringField: m.GetMessageField().GetStringField()}
+		m.TimeValueField = timestamppb.New(time.Unix(int64(i*10), int64(i)*1e6).UTC())
+		m.DurationValueField = durationpb.New(time.Duration(i) * time.Second)
+		doc, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(m)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO test VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			i, m.StringField, m.NumberField, m.BoolField, m.EnumField.String(), m.MessageField.StringField,
+			m.OptionalStringField, m.OptionalNumberField, m.TimeValueField.AsTime().Format(time.RFC3339Nano), int64(m.DurationValueField.AsDuration()), string(doc),
+		)
+		require.NoError(t, err)
+	}
+	fs := []filters.FieldFilterer{
+		filters.Where("string_field").StringEquals("abc"),
+		filters.Where("string_field").StringIEquals("abd"),
+		filters.Where("string_field").StringHasPrefix("a_"),
+		filters.Where("string_field").StringHasPrefix("a*"),
+		filters.Where("string_field").StringIHasPrefix("AB"),
+		filters.Where("string_field").StringHasSuffix("%z"),
+		filters.Where("string_field").StringContains("B"),
+		filters.Where("string_field").StringNotContains("b"),
+		filters.Where("string_field").StringIN("abc", "x%z"),
+		filters.Where("string_field").StringSup("a_c"),
+		filters.Where("number_field").NumberInf(2),
+		filters.Where("number_field").NumberNotIN(1, 4),
+		filters.Where("number_field").NumberEquals(1.5),
+		filters.Where("bool_field").True(),
+		filters.Where("bool_field").False(),
+		filters.Where("enum_field").StringEquals("TWO"),
+		filters.Where("message_field.string_field").StringEquals("abc"),
+		filters.Where("optional_string_field").Null(),
+		filters.Where("optional_string_field").StringEquals(""),
+		filters.Where("optional_string_field").StringNotEquals(""),
+		filters.Where("optional_number_field").NumberSup(1),
+		filters.Where("time_value_field").TimeAfter(time.Unix(20, 0)),
+		filters.Where("time_value_field").TimeEquals(time.Unix(10, 1e6)),
+		filters.Where("string_field").StringHasPrefix("a").AndWhere("bool_field").True().OrWhere("number_field").NumberEquals(4),
+		filters.Not(filters.Where("string_field").StringHasPrefix("a").OrWhere("optional_string_field").NotNull()),
+	}
+	columns := map[string]sql.ColumnFunc{
+		"flat": sql.Flat,
+		"json": sql.JSON("doc", md, false),
+	}
+	for name, columns := range columns {
+		for _, f := range fs {
+			t.Run(name+": "+f.Expr().Format(), func(t *testing.T) {
+				var want []int
+				for i, m := range msgs {
+					ok, err := protofilters.Match(m, f)
+					require.NoError(t, err)
+					if ok {
+						want = append(want, i)
+					}
+				}
+				where, args, err := sql.Where(sql.SQLite, f, columns)
+				require.NoError(t, err)
+				rows, err := db.Query("SELECT id FROM test WHERE "+where+" ORDER BY id", args...)
+				require.NoError(t, err)
+				defer rows.Close()
+				var got []int
+				for rows.Next() {
+					var id int
+					require.NoError(t, rows.Scan(&id))
+					got = append(got, id)
+				}
+				require.NoError(t, rows.Err())
+				assert.Equal(t, want, got, where)
+			})
+		}
+	}
+	where, args, err := sql.Where(sql.SQLite, filters.Where("duration_value_field").DurationSup(2*time.Second), sql.Flat)
+	require.NoError(t, err)
+	var ids []int
+	rows, err := db.Query("SELECT id FROM test WHERE "+where+" ORDER BY id", args...)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.Equal(t, []int{3, 4}, ids)
+}
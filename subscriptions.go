@@ -0,0 +1,104 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"errors"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/protofilters/filters"
+)
+
+// Changes lists the subscriptions affected by a message change.
+type Changes struct {
+	// Entered are the subscriptions matched by the new message but not by the old one
+	Entered []string
+	// Left are the subscriptions matched by the old message but not by the new one
+	Left []string
+	// Stayed are the subscriptions matched by both messages
+	Stayed []string
+}
+
+// Subscriptions is a registry of filters notified of the messages changes.
+// It relies on a Percolator so that only the filters which may match the
+// changed messages are evaluated.
+type Subscriptions struct {
+	p *Percolator
+}
+
+// NewSubscriptions creates an empty Subscriptions registry.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{p: NewPercolator()}
+}
+
+// Register registers the filter for the messages of type t under the given id,
+// replacing any previous registration using the same id.
+// The filter paths are validated if t is registered in the global registry.
+func (s *Subscriptions) Register(id string, t pref.FullName, f filters.FieldFilterer) error {
+	return s.p.Add(id, t, f)
+}
+
+// Unregister removes the subscription registered with the given id.
+func (s *Subscriptions) Unregister(id string) {
+	s.p.Remove(id)
+}
+
+// Change returns the subscriptions entered, left or stayed in when the old
+// message is replaced by the new one.
+// old is nil when the message is created, and new is nil when it is deleted.
+func (s *Subscriptions) Change(old, new proto.Message) (Changes, error) {
+	var c Changes
+	if old == nil && new == nil {
+		return c, errors.New("both messages are null")
+	}
+	if old != nil && new != nil && old.ProtoReflect().Descriptor().FullName() != new.ProtoReflect().Descriptor().FullName() {
+		return c, errors.New("messages types differ")
+	}
+	s.p.mu.RLock()
+	defer s.p.mu.RUnlock()
+	var was, is map[string]struct{}
+	var err error
+	if old != nil {
+		if was, err = s.p.percolate(old); err != nil {
+			return c, err
+		}
+	}
+	if new != nil {
+		if is, err = s.p.percolate(new); err != nil {
+			return c, err
+		}
+	}
+	for id := range was {
+		if _, ok := is[id]; ok {
+			c.Stayed = append(c.Stayed, id)
+		} else {
+			c.Left = append(c.Left, id)
+		}
+	}
+	for id := range is {
+		if _, ok := was[id]; !ok {
+			c.Entered = append(c.Entered, id)
+		}
+	}
+	sort.Strings(c.Entered)
+	sort.Strings(c.Left)
+	sort.Strings(c.Stayed)
+	return c, nil
+}
@@ -0,0 +1,160 @@
+/*
+ Copyright 2021 Linka Cloud  All rights reserved.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package protofilters
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go.linka.cloud/protofilters/filters"
+	test "go.linka.cloud/protofilters/tests/pb"
+)
+
+const testType = "linka.cloud.test.Test"
+
+func TestSubscriptions(t *testing.T) {
+	s := NewSubscriptions()
+	require.NoError(t, s.Register("name", testType, filters.Where("string_field").StringEquals("a")))
+	require.NoError(t, s.Register("number", testType, filters.Where("number_field").NumberIN(1, 2)))
+	require.NoError(t, s.Register("both", testType, filters.Where("string_field").StringEquals("a").AndWhere("number_field").NumberEquals(1)))
+	require.NoError(t, s.Register("prefix", testType, filters.Where("string_field").StringHasPrefix("b")))
+	require.NoError(t, s.Register("all", testType, nil))
+	assert.Error(t, s.Register("invalid", testType, filters.Where("no_field").StringEquals("a")))
+
+	c, err := s.Change(nil, &test.Test{StringField: "a", NumberField: 1})
+	require.NoError(t, err)
+	assert.Equal(t, Changes{Entered: []string{"all", "both", "name", "number"}}, c)
+
+	c, err = s.Change(&test.Test{StringField: "a", NumberField: 1}, &test.Test{StringField: "b", NumberField: 2})
+	require.NoError(t, err)
+	assert.Equal(t, Changes{Entered: []string{"prefix"}, Left: []string{"both", "name"}, Stayed: []string{"all", "number"}}, c)
+
+	s.Unregister("all")
+	s.Unregister("number")
+	c, err = s.Change(&test.Test{StringField: "b", NumberField: 2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Changes{Left: []string{"prefix"}}, c)
+
+	// the prefix rule is indexed by the string_field trie
+	x := s.p.types[testType]
+	assert.Equal(t, map[string]struct{}{}, x.always)
+	assert.Equal(t, map[string]int{"string_field": 3}, x.paths)
+
+	_, err = s.Change(nil, nil)
+	assert.Error(t, err)
+	_, err = s.Change(&test.Test{}, &wrapperspb.StringValue{})
+	assert.Error(t, err)
+}
+
+func TestSubscriptionsCandidates(t *testing.T) {
+	s := NewSubscriptions()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.Register(fmt.Sprint(i), testType, filters.Where("number_field").NumberEquals(float64(i)).OrWhere("string_field").StringIN(fmt.Sprint(i))))
+	}
+	require.NoError(t, s.Register("not", testType, filters.Where("number_field").NumberNotEquals(1)))
+	cs, err := s.p.candidates(s.p.types[testType], &test.Test{NumberField: 1, StringField: "2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"1": {}, "2": {}, "not": {}}, cs)
+}
+
+func TestSubscriptionsMatcher(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	strs := []string{"", "a", "b", "ONE"}
+	nums := []float64{0, 1, 2}
+	randMsg := func(depth int) *test.Test {
+		m := &test.Test{
+			StringField:         strs[r.Intn(len(strs))],
+			NumberField:         int64(nums[r.Intn(len(nums))]),
+			BoolField:           r.Intn(2) == 0,
+			EnumField:           test.Test_Type(r.Intn(3)),
+			RepeatedStringField: strs[:r.Intn(len(strs))],
+		}
+		if r.Intn(2) == 0 {
+			m.StringValueField = wrapperspb.String(strs[r.Intn(len(strs))])
+		}
+		if r.Intn(2) == 0 {
+			m.OptionalNumberField = proto.Int64(int64(nums[r.Intn(len(nums))]))
+		}
+		if r.Intn(2) == 0 {
+			m.Choice = &test.Test_OneofStringField{OneofStringField: strs[r.Intn(len(strs))]}
+		}
+		if depth > 0 && r.Intn(2) == 0 {
+			m.MessageField = &test.Test{StringField: strs[r.Intn(len(strs))]}
+		}
+		return m
+	}
+	fields := []string{"string_field", "repeated_string_field", "string_value_field", "oneof_string_field", "message_field.string_field", "enum_field"}
+	randFilter := func() filters.Builder {
+		switch r.Intn(5) {
+		case 0:
+			return filters.Where(fields[r.Intn(len(fields))]).StringEquals(strs[r.Intn(len(strs))])
+		case 1:
+			return filters.Where(fields[r.Intn(len(fields))]).StringIN(strs[r.Intn(len(strs))], strs[r.Intn(len(strs))])
+		case 2:
+			return filters.Where([]string{"number_field", "optional_number_field", "enum_field"}[r.Intn(3)]).NumberEquals(nums[r.Intn(len(nums))])
+		case 3:
+			return filters.Where("bool_field").True()
+		default:
+			return filters.Where("string_field").StringNotEquals(strs[r.Intn(len(strs))])
+		}
+	}
+	s := NewSubscriptions()
+	subs := make(map[string]filters.FieldFilterer)
+	for i := 0; i < 200; i++ {
+		f := randFilter()
+		switch r.Intn(3) {
+		case 0:
+			f = f.And(randFilter())
+		case 1:
+			f = f.Or(randFilter())
+		}
+		id := fmt.Sprint(i)
+		subs[id] = f
+		require.NoError(t, s.Register(id, testType, f))
+	}
+	for i := 0; i < 200; i++ {
+		old, new := randMsg(1), randMsg(1)
+		var want Changes
+		for id, f := range subs {
+			was, err := Match(old, f)
+			require.NoError(t, err)
+			is, err := Match(new, f)
+			require.NoError(t, err)
+			switch {
+			case was && is:
+				want.Stayed = append(want.Stayed, id)
+			case was:
+				want.Left = append(want.Left, id)
+			case is:
+				want.Entered = append(want.Entered, id)
+			}
+		}
+		sort.Strings(want.Entered)
+		sort.Strings(want.Left)
+		sort.Strings(want.Stayed)
+		got, err := s.Change(old, new)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}